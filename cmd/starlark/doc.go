@@ -0,0 +1,55 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"go.starlark.net/docgen"
+)
+
+// runDoc implements the "starlark doc" subcommand.
+func runDoc(args []string) int {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	root := fs.String("root", ".", "root `directory` of the workspace")
+	html := fs.Bool("html", false, "emit HTML instead of Markdown")
+	title := fs.String("title", "", "`title` of the document")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: starlark [flags] doc [-root=dir] [-html] [-title=title] file.star...\n\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nThe documentation of the named files and all the modules they load\n"+
+			"is printed as a single document, in Markdown by default.\n")
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	ix, err := indexConfig(*root).Build(fs.Args()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starlark doc: %v\n", err)
+		return 1
+	}
+	status := 0
+	for _, m := range ix.Modules {
+		for _, err := range m.Errors {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+		}
+	}
+
+	render := docgen.Markdown
+	if *html {
+		render = docgen.HTML
+	}
+	if err := render(os.Stdout, *title, docgen.FromIndex(ix)); err != nil {
+		fmt.Fprintf(os.Stderr, "starlark doc: %v\n", err)
+		return 1
+	}
+	return status
+}
@@ -0,0 +1,113 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.starlark.net/index"
+	"go.starlark.net/modresolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// runIndex implements the "starlark index" subcommand.
+func runIndex(args []string) int {
+	fs := flag.NewFlagSet("index", flag.ExitOnError)
+	root := fs.String("root", ".", "root `directory` of the workspace")
+	callers := fs.String("callers", "", "print the calls of `module:function` instead of the index")
+	loaders := fs.String("loaders", "", "print the loads of `module[:name]` instead of the index")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: starlark [flags] index [-root=dir] [-callers=m:f | -loaders=m[:name]] file.star...\n\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nThe index of the named files and all the modules they load is printed as JSON.\n"+
+			"Module names are file paths relative to the loading module or to the root,\n"+
+			"or labels such as //pkg:file.star, whose main repository is the root.\n")
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 || *callers != "" && *loaders != "" {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := indexConfig(*root)
+	ix, err := cfg.Build(fs.Args()...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starlark index: %v\n", err)
+		return 1
+	}
+
+	// module returns the indexed module of the given name.
+	module := func(name string) *index.Module {
+		if key, err := cfg.Resolver.Resolve("", name); err == nil {
+			return ix.Module(key)
+		}
+		return nil
+	}
+	// symbol returns the symbol denoted by "module:name".
+	// (A module name may itself contain a colon.)
+	symbol := func(spec string) *index.Symbol {
+		if i := strings.LastIndexByte(spec, ':'); i >= 0 {
+			if m := module(spec[:i]); m != nil {
+				return ix.Lookup(m.Key, spec[i+1:])
+			}
+		}
+		return nil
+	}
+
+	var result any = ix
+	switch {
+	case *callers != "":
+		sym := symbol(*callers)
+		if sym == nil || sym.Func == nil {
+			fmt.Fprintf(os.Stderr, "starlark index: no function %s\n", *callers)
+			return 1
+		}
+		result = orEmpty(ix.Callers(sym.Func))
+	case *loaders != "":
+		if m := module(*loaders); m != nil {
+			result = orEmpty(ix.Loaders(m))
+		} else if sym := symbol(*loaders); sym != nil {
+			result = orEmpty(ix.LoadersOf(sym))
+		} else {
+			fmt.Fprintf(os.Stderr, "starlark index: no module or symbol %s\n", *loaders)
+			return 1
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(result); err != nil {
+		fmt.Fprintf(os.Stderr, "starlark index: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// indexConfig returns the configuration for indexing the
+// workspace whose root is the specified directory.
+func indexConfig(root string) *index.Config {
+	fsys := os.DirFS(root)
+	return &index.Config{
+		Resolver: modresolve.NewChain(
+			modresolve.Labels{Main: fsys},
+			modresolve.Relative{FS: fsys},
+		),
+		Options:     syntax.LegacyFileOptions(),
+		IsUniversal: starlark.Universe.Has,
+	}
+}
+
+// orEmpty returns a non-nil slice, which is encoded as [] not null.
+func orEmpty[T any](list []T) []T {
+	if list == nil {
+		list = []T{}
+	}
+	return list
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.starlark.net/lint"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// runLint implements the "starlark lint" subcommand.
+func runLint(args []string) int {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	checks := fs.String("checks", "", "comma-separated list of `analyzers` to apply (default all)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: starlark [flags] lint [-checks=name,...] file.star...\n\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nAnalyzers:\n")
+		for _, a := range lint.All {
+			summary, _, _ := strings.Cut(a.Doc, "\n")
+			fmt.Fprintf(fs.Output(), "  %-16s %s\n", a.Name, summary)
+		}
+		fmt.Fprintf(fs.Output(), "\nA comment \"# lint:ignore name,...\" at the end of a line suppresses the\n"+
+			"named analyzers on that line; on a line by itself, it applies to the next line.\n")
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	cfg := &lint.Config{IsUniversal: starlark.Universe.Has}
+	if *checks != "" {
+		for _, name := range strings.Split(*checks, ",") {
+			a := lint.Lookup(name)
+			if a == nil {
+				fmt.Fprintf(os.Stderr, "starlark lint: unknown analyzer %q\n", name)
+				return 2
+			}
+			cfg.Analyzers = append(cfg.Analyzers, a)
+		}
+	}
+
+	status := 0
+	opts := syntax.LegacyFileOptions()
+	for _, filename := range fs.Args() {
+		diags, err := cfg.CheckFile(opts, filename, nil)
+		if err != nil {
+			if errs, ok := err.(resolve.ErrorList); ok {
+				for _, err := range errs {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			} else {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			status = 1
+			continue
+		}
+		for _, d := range diags {
+			fmt.Println(d)
+			status = 1
+		}
+	}
+	return status
+}
@@ -4,6 +4,16 @@
 
 // The starlark command interprets a Starlark file.
 // With no arguments, it starts a read-eval-print loop (REPL).
+//
+// The command also provides these subcommands:
+//
+//	starlark doc file.star...     print documentation (see package docgen)
+//	starlark index file.star...   print a cross-file index as JSON (see package index)
+//	starlark lint file.star...    report likely mistakes (see package lint)
+//	starlark test [./...]         run the test_* functions of *_test.star files
+//
+// A file whose name is that of a subcommand may be executed by
+// giving its name as a path, such as ./lint.
 package main // import "go.starlark.net/cmd/starlark"
 
 import (
@@ -30,6 +40,8 @@ var (
 	cpuprofile = flag.String("cpuprofile", "", "gather Go CPU profile in this file")
 	memprofile = flag.String("memprofile", "", "gather Go memory profile in this file")
 	profile    = flag.String("profile", "", "gather Starlark time profile in this file")
+	profkind   = flag.String("profilekind", "wall", "kind of Starlark profile: wall, steps, or allocs")
+	trace      = flag.String("trace", "", "write a Chrome trace of Starlark execution to this file")
 	showenv    = flag.Bool("showenv", false, "on success, print final global environment")
 	execprog   = flag.String("c", "", "execute program `prog`")
 )
@@ -47,6 +59,16 @@ func init() {
 	flag.BoolVar(&resolve.AllowLambda, "lambda", true, "obsolete; no effect")
 }
 
+// subcommands maps the name of each subcommand to its implementation,
+// which is passed the remaining command-line arguments and returns
+// the exit status.
+var subcommands = map[string]func(args []string) int{
+	"doc":   runDoc,
+	"index": runIndex,
+	"lint":  runLint,
+	"test":  runTest,
+}
+
 func main() {
 	os.Exit(doMain())
 }
@@ -79,18 +101,45 @@ func doMain() int {
 		}()
 	}
 
+	var profiler *starlark.Profiler
 	if *profile != "" {
+		profiler = new(starlark.Profiler)
+		switch *profkind {
+		case "wall":
+			profiler.Kind = starlark.WallTimeProfile
+		case "steps":
+			profiler.Kind = starlark.StepProfile
+		case "allocs":
+			profiler.Kind = starlark.AllocProfile
+		default:
+			log.Printf("invalid -profilekind %q", *profkind)
+			return 1
+		}
 		f, err := os.Create(*profile)
 		check(err)
-		err = starlark.StartProfile(f)
+		err = profiler.Start(f)
 		check(err)
 		defer func() {
-			err := starlark.StopProfile()
+			err := profiler.Stop()
 			check(err)
 		}()
 	}
 
 	thread := &starlark.Thread{Load: repl.MakeLoad()}
+	thread.SetProfiler(profiler)
+
+	if *trace != "" {
+		f, err := os.Create(*trace)
+		check(err)
+		tracer := starlark.NewChromeTracer(f)
+		thread.Tracer = tracer
+		defer func() {
+			err := tracer.Close()
+			check(err)
+			err = f.Close()
+			check(err)
+		}()
+	}
 	globals := make(starlark.StringDict)
 
 	// Ideally this statement would update the predeclared environment.
@@ -99,6 +148,10 @@ func doMain() int {
 	starlark.Universe["time"] = time.Module
 	starlark.Universe["math"] = math.Module
 
+	if cmd, ok := subcommands[flag.Arg(0)]; ok && *execprog == "" {
+		return cmd(flag.Args()[1:])
+	}
+
 	switch {
 	case flag.NArg() == 1 || *execprog != "":
 		var (
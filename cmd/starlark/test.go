@@ -0,0 +1,457 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarktest"
+	"go.starlark.net/syntax"
+)
+
+// runTest implements the "starlark test" subcommand.
+func runTest(args []string) int {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	run := fs.String("run", "", "run only the tests whose names match the `regexp`")
+	verbose := fs.Bool("v", false, "report each test, and its output, even if it passes")
+	parallel := fs.Int("parallel", runtime.GOMAXPROCS(0), "run at most `n` tests in parallel")
+	timeout := fs.Duration("timeout", 10*time.Second, "cancel each test after duration `d` (0 means no limit)")
+	steps := fs.Uint64("steps", 0, "cancel each test after `n` execution steps (0 means no limit)")
+	junit := fs.String("junit", "", "write a JUnit XML report to `file`")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: starlark [flags] test [flags] [file.star | dir | dir/...]...\n\n"+
+			"Test runs the functions named test_* of the files named *_test.star,\n"+
+			"each in its own thread and its own execution of the file. A pattern\n"+
+			"dir/... denotes the test files of dir and its subdirectories; the\n"+
+			"default is ./... . The module assert is predeclared, and may also be\n"+
+			"loaded from \"assert.star\". Other modules are loaded relative to the\n"+
+			"directory of the file that loads them, and executed once.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *parallel < 1 {
+		*parallel = 1
+	}
+	r := &testRunner{
+		timeout: *timeout,
+		steps:   *steps,
+		sema:    make(chan struct{}, *parallel),
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "starlark test: invalid -run: %v\n", err)
+			return 2
+		}
+		r.run = re
+	}
+	assert, err := starlarktest.LoadAssertModule()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starlark test: %v\n", err)
+		return 1
+	}
+	assert.Freeze()
+	r.assert = assert
+	r.loader = &starlark.Loader{
+		Resolve: resolveTestModule,
+		Virtual: func(module string) starlark.StringDict {
+			if module == "assert.star" {
+				return assert
+			}
+			return nil
+		},
+		Read:    os.ReadFile,
+		Options: syntax.LegacyFileOptions(),
+	}
+
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	filenames, err := findTestFiles(patterns)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "starlark test: %v\n", err)
+		return 1
+	}
+	if len(filenames) == 0 {
+		fmt.Fprintf(os.Stderr, "starlark test: no test files match %s\n", strings.Join(patterns, " "))
+		return 1
+	}
+
+	// Start all files, then report them in order as each completes.
+	files := make([]*fileResult, len(filenames))
+	for i, filename := range filenames {
+		files[i] = r.start(filename)
+	}
+	status := 0
+	for _, f := range files {
+		<-f.done
+		if !f.report(*verbose) {
+			status = 1
+		}
+	}
+	if status != 0 {
+		fmt.Println("FAIL")
+	}
+	if *junit != "" {
+		if err := writeJUnit(*junit, files); err != nil {
+			fmt.Fprintf(os.Stderr, "starlark test: %v\n", err)
+			return 1
+		}
+	}
+	return status
+}
+
+// findTestFiles returns the names of the test files denoted by the
+// patterns, without duplicates, in order.
+func findTestFiles(patterns []string) ([]string, error) {
+	var filenames []string
+	seen := make(map[string]bool)
+	add := func(filename string) {
+		if !seen[filename] {
+			seen[filename] = true
+			filenames = append(filenames, filename)
+		}
+	}
+	isTestFile := func(name string) bool { return strings.HasSuffix(name, "_test.star") }
+	for _, pattern := range patterns {
+		if dir, ok := strings.CutSuffix(pattern, "/..."); ok || pattern == "..." {
+			if dir == "..." || dir == "" {
+				dir = "."
+			}
+			err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() && path != dir && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				if !d.IsDir() && isTestFile(d.Name()) {
+					add(path)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info, err := os.Stat(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(pattern)
+			continue
+		}
+		entries, err := os.ReadDir(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() && isTestFile(e.Name()) {
+				add(filepath.Join(pattern, e.Name()))
+			}
+		}
+	}
+	return filenames, nil
+}
+
+// A testRunner runs the tests of files.
+type testRunner struct {
+	run     *regexp.Regexp // selects tests by name; nil means all
+	timeout time.Duration
+	steps   uint64
+	sema    chan struct{} // limits the number of parallel tests
+	assert  starlark.StringDict
+	loader  *starlark.Loader // loads the modules of all test files
+}
+
+// A fileResult holds the results of the tests of a file.
+type fileResult struct {
+	filename string
+	done     chan struct{} // closed when the file's tests are complete
+	err      error         // error of compilation or discovery, if any
+	tests    []*testResult
+	elapsed  time.Duration
+}
+
+// A testResult holds the result of a test.
+type testResult struct {
+	name    string
+	status  string // "PASS", "FAIL", or "SKIP"
+	output  []string
+	elapsed time.Duration
+}
+
+// Error reports an error from the assert module. It implements starlarktest.Reporter.
+func (t *testResult) Error(args ...any) {
+	t.status = "FAIL"
+	t.output = append(t.output, fmt.Sprint(args...))
+}
+
+// start starts running the tests of a file, and returns its result,
+// whose done channel is closed when they have finished.
+func (r *testRunner) start(filename string) *fileResult {
+	f := &fileResult{filename: filename, done: make(chan struct{})}
+	go func() {
+		defer close(f.done)
+		start := time.Now()
+		defer func() { f.elapsed = time.Since(start) }()
+
+		predeclared := starlark.StringDict{"assert": r.assert["assert"]}
+		_, prog, err := starlark.SourceProgramOptions(syntax.LegacyFileOptions(), filename, nil, predeclared.Has)
+		if err != nil {
+			f.err = err
+			return
+		}
+
+		// Execute the file once to discover its tests.
+		discovery := new(testResult)
+		globals, err := prog.Init(r.newThread(filename, filename, discovery), predeclared)
+		if err != nil {
+			f.err = err
+			return
+		} else if discovery.status == "FAIL" {
+			f.err = errors.New(strings.Join(discovery.output, "\n"))
+			return
+		}
+		var tests []*starlark.Function
+		for name, v := range globals {
+			if fn, ok := v.(*starlark.Function); ok && strings.HasPrefix(name, "test_") && fn.Name() == name &&
+				(r.run == nil || r.run.MatchString(name)) {
+				tests = append(tests, fn)
+			}
+		}
+		sort.Slice(tests, func(i, j int) bool {
+			x, y := tests[i].Position(), tests[j].Position()
+			return x.Line < y.Line || x.Line == y.Line && x.Col < y.Col
+		})
+
+		var wg sync.WaitGroup
+		for _, test := range tests {
+			result := &testResult{name: test.Name(), status: "PASS"}
+			f.tests = append(f.tests, result)
+			wg.Add(1)
+			r.sema <- struct{}{}
+			go func() {
+				defer func() { <-r.sema; wg.Done() }()
+				r.runTest(prog, filename, result, predeclared)
+			}()
+		}
+		wg.Wait()
+	}()
+	return f
+}
+
+// runTest runs a single test in its own thread and its own execution
+// of the file, with the file's setup and teardown functions, if any.
+func (r *testRunner) runTest(prog *starlark.Program, filename string, result *testResult, predeclared starlark.StringDict) {
+	start := time.Now()
+	defer func() { result.elapsed = time.Since(start) }()
+
+	thread := r.newThread(filename, result.name, result)
+	if r.steps > 0 {
+		thread.SetMaxExecutionSteps(r.steps)
+	}
+	if r.timeout > 0 {
+		timer := time.AfterFunc(r.timeout, func() {
+			thread.Cancel(fmt.Sprintf("test timed out after %v", r.timeout))
+		})
+		defer timer.Stop()
+	}
+
+	fail := func(err error) {
+		var evalErr *starlark.EvalError
+		if errors.As(err, &evalErr) {
+			result.Error(evalErr.Backtrace())
+		} else {
+			result.Error(err)
+		}
+	}
+	globals, err := prog.Init(thread, predeclared)
+	if err != nil {
+		fail(err)
+		return
+	}
+	call := func(name string) bool {
+		fn, ok := globals[name].(starlark.Callable)
+		if !ok {
+			return true
+		}
+		if _, err := starlark.Call(thread, fn, nil, nil); err != nil {
+			if reason, ok := starlarktest.SkipReason(err); ok {
+				if result.status != "FAIL" {
+					result.status = "SKIP"
+				}
+				result.output = append(result.output, reason)
+			} else {
+				fail(err)
+			}
+			return false
+		}
+		return true
+	}
+	if _, ok := globals["teardown"]; ok {
+		defer call("teardown")
+	}
+	if call("setup") {
+		call(result.name)
+	}
+}
+
+// newThread returns a new thread for the named test of a file, which
+// reports its output and assertion failures to result.
+func (r *testRunner) newThread(filename, name string, result *testResult) *starlark.Thread {
+	dir := filepath.Dir(filename)
+	thread := &starlark.Thread{
+		Name:  name,
+		Print: func(_ *starlark.Thread, msg string) { result.output = append(result.output, msg) },
+		Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+			if module != "assert.star" {
+				module = filepath.Join(dir, module)
+			}
+			return r.loader.Load(thread, module)
+		},
+	}
+	starlarktest.SetReporter(thread, result)
+	starlarktest.SetGoldenDir(thread, dir)
+	return thread
+}
+
+// resolveTestModule returns the file name of a module loaded by the
+// module with file name from, relative to its directory, or by a test
+// file if from is "", in which case the name is already a file name.
+// The name "assert.star" denotes the assert module.
+func resolveTestModule(from, module string) (string, error) {
+	if from == "" || module == "assert.star" {
+		return module, nil
+	}
+	return filepath.Join(filepath.Dir(from), module), nil
+}
+
+// report prints the results of a file in the style of "go test",
+// and reports whether all its tests passed.
+func (f *fileResult) report(verbose bool) bool {
+	if f.err != nil {
+		var evalErr *starlark.EvalError
+		if errors.As(f.err, &evalErr) {
+			fmt.Println(evalErr.Backtrace())
+		} else {
+			fmt.Println(f.err)
+		}
+		fmt.Printf("FAIL\t%s [setup failed]\n", f.filename)
+		return false
+	}
+	ok := true
+	for _, t := range f.tests {
+		if t.status == "FAIL" {
+			ok = false
+		}
+		if verbose || t.status == "FAIL" {
+			if verbose {
+				fmt.Printf("=== RUN   %s\n", t.name)
+			}
+			fmt.Printf("--- %s: %s (%.2fs)\n", t.status, t.name, t.elapsed.Seconds())
+			for _, msg := range t.output {
+				fmt.Printf("    %s\n", strings.ReplaceAll(msg, "\n", "\n    "))
+			}
+		}
+	}
+	switch {
+	case !ok:
+		fmt.Printf("FAIL\t%s\t%.3fs\n", f.filename, f.elapsed.Seconds())
+	case len(f.tests) == 0:
+		fmt.Printf("ok  \t%s\t%.3fs [no tests to run]\n", f.filename, f.elapsed.Seconds())
+	default:
+		fmt.Printf("ok  \t%s\t%.3fs\n", f.filename, f.elapsed.Seconds())
+	}
+	return ok
+}
+
+// JUnit XML report format.
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Errors   int          `xml:"errors,attr"`
+		Skipped  int          `xml:"skipped,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Errors   int         `xml:"errors,attr"`
+		Skipped  int         `xml:"skipped,attr"`
+		Time     string      `xml:"time,attr"`
+		Error    *junitError `xml:"error,omitempty"`
+		Cases    []junitCase `xml:"testcase"`
+	}
+	junitCase struct {
+		Name      string      `xml:"name,attr"`
+		Classname string      `xml:"classname,attr"`
+		Time      string      `xml:"time,attr"`
+		Failure   *junitError `xml:"failure,omitempty"`
+		Skipped   *junitError `xml:"skipped,omitempty"`
+		SystemOut string      `xml:"system-out,omitempty"`
+	}
+	junitError struct {
+		Message string `xml:"message,attr"`
+		Body    string `xml:",chardata"`
+	}
+)
+
+// writeJUnit writes the results of the files as a JUnit XML report.
+func writeJUnit(filename string, files []*fileResult) error {
+	var report junitSuites
+	for _, f := range files {
+		suite := junitSuite{Name: f.filename, Time: fmt.Sprintf("%.3f", f.elapsed.Seconds())}
+		if f.err != nil {
+			suite.Errors = 1
+			suite.Error = &junitError{Message: "setup failed", Body: f.err.Error()}
+		}
+		for _, t := range f.tests {
+			c := junitCase{Name: t.name, Classname: f.filename, Time: fmt.Sprintf("%.3f", t.elapsed.Seconds())}
+			output := strings.Join(t.output, "\n")
+			switch t.status {
+			case "FAIL":
+				suite.Failures++
+				c.Failure = &junitError{Message: "test failed", Body: output}
+			case "SKIP":
+				suite.Skipped++
+				c.Skipped = &junitError{Message: output}
+			default:
+				c.SystemOut = output
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, c)
+		}
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, suite)
+	}
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append([]byte(xml.Header+string(data)), '\n'), 0666)
+}
@@ -0,0 +1,334 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package docgen generates reference documentation for Starlark
+// modules from their doc strings and function signatures.
+//
+// The documentation of a module is a Module, which may be obtained
+// from the source of a tree of modules, through an index.Index, by
+// FromIndex; from the values of a function defined in Starlark by
+// FromFunction; or, for built-ins defined in Go, which have no doc
+// strings, from documentation supplied by the embedder, by
+// BuiltinModule. Markdown and HTML render a list of modules as a
+// single document in which each module, and each function, has an
+// anchor, and each load statement links to the module it loads.
+package docgen // import "go.starlark.net/docgen"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.starlark.net/index"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// A Module is the documentation of a module.
+type Module struct {
+	Name      string      // the module's key, or the name of a built-in module
+	Doc       string      // doc string, with indentation removed
+	Builtin   bool        // defined in Go
+	Loads     []Load      // load statements, in order
+	Functions []*Function // exported functions, in order of position (or name)
+	Values    []string    // names of other exported globals, in the same order
+}
+
+// A Load is a load statement of a documented module.
+type Load struct {
+	Module string   // the key of the loaded module, or its name if unresolved
+	Names  []string // the names loaded (their names in the loaded module)
+}
+
+// A Function is the documentation of a function.
+type Function struct {
+	Name   string
+	Params []Param
+	Result string // source text of the result type annotation, if any
+	Doc    string // doc string, with indentation removed
+}
+
+// A Param is a parameter of a function.
+type Param struct {
+	Name    string // the name, with a * or ** prefix for *args or **kwargs; or "*" alone
+	Type    string // source text of the type annotation, if any
+	Default string // source text or string representation of the default value, if any
+}
+
+func (p Param) String() string {
+	s := p.Name
+	if p.Type != "" {
+		s += ": " + p.Type
+	}
+	if p.Default != "" {
+		if p.Type != "" {
+			s += " = " + p.Default
+		} else {
+			s += "=" + p.Default
+		}
+	}
+	return s
+}
+
+// Signature returns the signature of the function, such as
+// "f(x, y=1, *args, **kwargs)".
+func (fn *Function) Signature() string {
+	params := make([]string, len(fn.Params))
+	for i, p := range fn.Params {
+		params[i] = p.String()
+	}
+	sig := fn.Name + "(" + strings.Join(params, ", ") + ")"
+	if fn.Result != "" {
+		sig += " -> " + fn.Result
+	}
+	return sig
+}
+
+// FromIndex returns the documentation of the modules of the index,
+// in order of key. The documentation of a Virtual module is the
+// element of builtins whose Name is the module's key, if any;
+// otherwise it lists only the names of the module's exported members.
+//
+// The documented functions of a module are its exported globals that
+// denote functions defined by def statements, including those assigned
+// from the names of functions of other modules, which are documented
+// under their exported names.
+func FromIndex(ix *index.Index, builtins ...*Module) []*Module {
+	byName := make(map[string]*Module)
+	for _, b := range builtins {
+		byName[b.Name] = b
+	}
+	defs := make(map[*index.Module]map[syntax.Position]*syntax.DefStmt)
+	function := func(fn *index.Function, name string) *Function {
+		m := fn.Module
+		if defs[m] == nil {
+			defs[m] = make(map[syntax.Position]*syntax.DefStmt)
+			syntax.Walk(m.Syntax(), func(n syntax.Node) bool {
+				if def, ok := n.(*syntax.DefStmt); ok {
+					defs[m][def.Name.NamePos] = def
+				}
+				return true
+			})
+		}
+		doc := fromDef(m.Source(), defs[m][fn.Pos])
+		doc.Name = name
+		return doc
+	}
+
+	var modules []*Module
+	for _, m := range ix.Modules {
+		if m.Virtual {
+			doc := byName[m.Key]
+			if doc == nil {
+				doc = &Module{Name: m.Key, Builtin: true}
+				for _, sym := range m.Symbols {
+					doc.Values = append(doc.Values, sym.Name)
+				}
+			}
+			modules = append(modules, doc)
+			continue
+		}
+		if m.Syntax() == nil {
+			continue
+		}
+		doc := &Module{Name: m.Key, Doc: trimDoc(m.Doc)}
+		for _, load := range m.Loads {
+			l := Load{Module: load.Name}
+			if load.Target != nil {
+				l.Module = load.Target.Key
+			}
+			for _, name := range load.Names {
+				l.Names = append(l.Names, name.Remote)
+			}
+			doc.Loads = append(doc.Loads, l)
+		}
+		for _, sym := range m.Symbols {
+			if sym.Func != nil {
+				doc.Functions = append(doc.Functions, function(sym.Func, sym.Name))
+			} else {
+				doc.Values = append(doc.Values, sym.Name)
+			}
+		}
+		modules = append(modules, doc)
+	}
+	return modules
+}
+
+// fromDef returns the documentation of the function defined by def,
+// whose source text is src.
+func fromDef(src []byte, def *syntax.DefStmt) *Function {
+	text := func(e syntax.Expr) string {
+		if e == nil {
+			return ""
+		}
+		start, end := e.Span()
+		return sourceText(src, start, end)
+	}
+	fn := &Function{Name: def.Name.Name, Doc: trimDoc(docString(def.Body)), Result: text(def.ResultType)}
+	for i, param := range def.Params {
+		var p Param
+		switch param := param.(type) {
+		case *syntax.Ident:
+			p.Name = param.Name
+		case *syntax.BinaryExpr: // x=default
+			p.Name = param.X.(*syntax.Ident).Name
+			p.Default = text(param.Y)
+		case *syntax.UnaryExpr: // *, *args, **kwargs
+			p.Name = param.Op.String()
+			if param.X != nil {
+				p.Name += param.X.(*syntax.Ident).Name
+			}
+		}
+		if def.ParamTypes != nil {
+			p.Type = text(def.ParamTypes[i])
+		}
+		fn.Params = append(fn.Params, p)
+	}
+	return fn
+}
+
+// FromFunction returns the documentation of a function defined in
+// Starlark, such as a global of a module executed by the embedder.
+// The default value of each optional parameter is represented by its
+// string representation, which may differ from the source text.
+func FromFunction(fn *starlark.Function) *Function {
+	doc := &Function{Name: fn.Name(), Doc: trimDoc(fn.Doc())}
+	param := func(i int, prefix string) Param {
+		name, _ := fn.Param(i)
+		p := Param{Name: prefix + name}
+		if dflt := fn.ParamDefault(i); dflt != nil {
+			p.Default = dflt.String()
+		}
+		return p
+	}
+
+	// Parameters appear in the order: positional, keyword-only,
+	// *args, **kwargs.
+	n, kwonly := fn.NumParams(), fn.NumKwonlyParams()
+	if fn.HasVarargs() {
+		n--
+	}
+	if fn.HasKwargs() {
+		n--
+	}
+	for i := 0; i < n-kwonly; i++ {
+		doc.Params = append(doc.Params, param(i, ""))
+	}
+	if fn.HasVarargs() {
+		doc.Params = append(doc.Params, param(n, "*"))
+	} else if kwonly > 0 {
+		doc.Params = append(doc.Params, Param{Name: "*"})
+	}
+	for i := n - kwonly; i < n; i++ {
+		doc.Params = append(doc.Params, param(i, ""))
+	}
+	if fn.HasKwargs() {
+		i := n
+		if fn.HasVarargs() {
+			i++
+		}
+		doc.Params = append(doc.Params, param(i, "**"))
+	}
+	return doc
+}
+
+// BuiltinModule returns the documentation of a module of built-ins
+// defined in Go, such as a starlarkstruct.Module or the predeclared
+// names of an application. Built-in functions have no doc strings, so
+// the embedder supplies the documentation of each member as a map
+// from its signature, such as "glob(include, exclude=[])", to its doc
+// string. A signature without parentheses documents a value that is
+// not a function. Members are documented in order of name.
+func BuiltinModule(name, doc string, members map[string]string) (*Module, error) {
+	m := &Module{Name: name, Doc: trimDoc(doc), Builtin: true}
+	for sig, doc := range members {
+		if !strings.Contains(sig, "(") {
+			m.Values = append(m.Values, sig)
+			continue
+		}
+		src := "def " + sig + ": pass\n"
+		f, err := new(syntax.FileOptions).Parse(name, src, 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature %q: %v", sig, err)
+		}
+		def, ok := f.Stmts[0].(*syntax.DefStmt)
+		if !ok || len(f.Stmts) != 1 {
+			return nil, fmt.Errorf("invalid signature %q", sig)
+		}
+		fn := fromDef([]byte(src), def)
+		fn.Doc = trimDoc(doc)
+		m.Functions = append(m.Functions, fn)
+	}
+	sort.Slice(m.Functions, func(i, j int) bool { return m.Functions[i].Name < m.Functions[j].Name })
+	sort.Strings(m.Values)
+	return m, nil
+}
+
+// -- helpers --
+
+// docString returns the doc string of a file or function body:
+// the value of a string literal that is the first statement.
+func docString(stmts []syntax.Stmt) string {
+	if len(stmts) > 0 {
+		if expr, ok := stmts[0].(*syntax.ExprStmt); ok {
+			if lit, ok := expr.X.(*syntax.Literal); ok && lit.Token == syntax.STRING {
+				return lit.Value.(string)
+			}
+		}
+	}
+	return ""
+}
+
+// trimDoc removes the indentation of the second and subsequent lines
+// of a doc string, and leading and trailing blank lines, in the manner
+// of Python's inspect.cleandoc.
+func trimDoc(doc string) string {
+	lines := strings.Split(strings.ReplaceAll(doc, "\t", "        "), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" {
+			if n := len(line) - len(trimmed); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	for len(lines) > 0 && lines[0] == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sourceText returns the text of src between the start and end
+// positions, whose columns are measured in runes.
+func sourceText(src []byte, start, end syntax.Position) string {
+	offset := func(pos syntax.Position) int {
+		i := 0
+		for line := int32(1); line < pos.Line && i < len(src); i++ {
+			if src[i] == '\n' {
+				line++
+			}
+		}
+		for col := pos.Col; col > 1 && i < len(src); col-- {
+			_, size := utf8.DecodeRune(src[i:])
+			i += size
+		}
+		return i
+	}
+	i, j := offset(start), offset(end)
+	if i > j {
+		return ""
+	}
+	return string(src[i:j])
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docgen_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.starlark.net/docgen"
+	"go.starlark.net/index"
+	"go.starlark.net/modresolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+func buildDocs(t *testing.T) []*docgen.Module {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"rules.star": {Data: []byte(`"""Rules for building things.
+
+    Load them from rules.star.
+    """
+
+load("util.star", "join")
+load("sys", "glob")
+
+def my_rule(name, srcs = [], *args, visibility = None, **kwargs):
+    """Defines a rule.
+
+    Args:
+      name: the name of the rule.
+    """
+    return join(name, glob(srcs))
+
+def typed(x: int, y: str = "a") -> str:
+    return y * x
+
+joiner = join
+VERSION = "1.0"
+`)},
+		"util.star": {Data: []byte(`def join(*parts):
+    """Joins the parts."""
+    return "/".join(parts)
+`)},
+	}
+	var virtual modresolve.Virtual
+	virtual.Register(&starlarkstruct.Module{
+		Name:    "sys",
+		Members: starlark.StringDict{"glob": starlark.None, "env": starlark.None},
+	})
+	cfg := &index.Config{
+		Resolver:    modresolve.NewChain(&virtual, modresolve.Relative{FS: fsys}),
+		Options:     &syntax.FileOptions{TypeAnnotations: true},
+		IsUniversal: starlark.Universe.Has,
+	}
+	ix, err := cfg.Build("rules.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sys, err := docgen.BuiltinModule("sys", "System functions.", map[string]string{
+		"glob(include, exclude=[])": "Returns the files matching the patterns.",
+		"env":                       "The environment.",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docgen.FromIndex(ix, sys)
+}
+
+func TestMarkdown(t *testing.T) {
+	var buf strings.Builder
+	if err := docgen.Markdown(&buf, "Reference", buildDocs(t)); err != nil {
+		t.Fatal(err)
+	}
+	want := "# Reference\n\n" +
+		"- [`rules.star`](#module-rules-star)\n" +
+		"- [`sys`](#module-sys)\n" +
+		"- [`util.star`](#module-util-star)\n\n" +
+		"<a id=\"module-rules-star\"></a>\n\n" +
+		"## `rules.star`\n\n" +
+		"Rules for building things.\n\nLoad them from rules.star.\n\n" +
+		"Loads:\n\n" +
+		"- [`util.star`](#module-util-star): [`join`](#module-util-star.join)\n" +
+		"- [`sys`](#module-sys): [`glob`](#module-sys.glob)\n\n" +
+		"<a id=\"module-rules-star.my_rule\"></a>\n\n" +
+		"### `my_rule`\n\n" +
+		"```python\nmy_rule(name, srcs=[], *args, visibility=None, **kwargs)\n```\n\n" +
+		"Defines a rule.\n\nArgs:\n  name: the name of the rule.\n\n" +
+		"<a id=\"module-rules-star.typed\"></a>\n\n" +
+		"### `typed`\n\n" +
+		"```python\ntyped(x: int, y: str = \"a\") -> str\n```\n\n" +
+		"<a id=\"module-rules-star.joiner\"></a>\n\n" +
+		"### `joiner`\n\n" +
+		"```python\njoiner(*parts)\n```\n\n" +
+		"Joins the parts.\n\n" +
+		"Other values: `VERSION`\n\n" +
+		"<a id=\"module-sys\"></a>\n\n" +
+		"## `sys` (built-in)\n\n" +
+		"System functions.\n\n" +
+		"<a id=\"module-sys.glob\"></a>\n\n" +
+		"### `glob`\n\n" +
+		"```python\nglob(include, exclude=[])\n```\n\n" +
+		"Returns the files matching the patterns.\n\n" +
+		"Other values: `env`\n\n" +
+		"<a id=\"module-util-star\"></a>\n\n" +
+		"## `util.star`\n\n" +
+		"<a id=\"module-util-star.join\"></a>\n\n" +
+		"### `join`\n\n" +
+		"```python\njoin(*parts)\n```\n\n" +
+		"Joins the parts.\n\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf strings.Builder
+	if err := docgen.HTML(&buf, "Reference <lib>", buildDocs(t)); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		`<title>Reference &lt;lib&gt;</title>`,
+		`<section id="module-rules-star">`,
+		`<li><a href="#module-util-star"><code>util.star</code></a>: <a href="#module-util-star.join"><code>join</code></a></li>`,
+		`<pre class="sig">typed(x: int, y: str = &#34;a&#34;) -&gt; str</pre>`,
+		`<h2><code>sys</code> (built-in)</h2>`,
+		`<p>Other values: <code>VERSION</code></p>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("HTML output does not contain %s:\n%s", want, got)
+		}
+	}
+}
+
+// TestAnchorCollision checks that modules whose names map to the same
+// anchor have distinct anchors, and that links refer to the right one.
+func TestAnchorCollision(t *testing.T) {
+	modules := []*docgen.Module{
+		{Name: "a-b.star", Functions: []*docgen.Function{{Name: "f"}}},
+		{Name: "a/b.star", Functions: []*docgen.Function{{Name: "f"}}},
+		{Name: "main.star", Loads: []docgen.Load{
+			{Module: "a-b.star", Names: []string{"f"}},
+			{Module: "a/b.star", Names: []string{"f"}},
+		}},
+	}
+	var buf strings.Builder
+	if err := docgen.Markdown(&buf, "", modules); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<a id=\"module-a-b-star\"></a>\n\n## `a-b.star`",
+		"<a id=\"module-a-b-star.f\"></a>",
+		"<a id=\"module-a-b-star-2\"></a>\n\n## `a/b.star`",
+		"<a id=\"module-a-b-star-2.f\"></a>",
+		"- [`a-b.star`](#module-a-b-star): [`f`](#module-a-b-star.f)\n",
+		"- [`a/b.star`](#module-a-b-star-2): [`f`](#module-a-b-star-2.f)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Markdown output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestFromFunction(t *testing.T) {
+	thread := new(starlark.Thread)
+	globals, err := starlark.ExecFile(thread, "f.star", `
+def f(a, b=1+1, *args, c, d=[], **kwargs):
+    """  Doc of f.
+        Indented.
+      Less indented.
+    """
+
+def g(x, *, y="y"): pass
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct{ name, sig, doc string }{
+		{"f", "f(a, b=2, *args, c, d=[], **kwargs)", "Doc of f.\n  Indented.\nLess indented."},
+		{"g", `g(x, *, y="y")`, ""},
+	} {
+		fn := docgen.FromFunction(globals[test.name].(*starlark.Function))
+		if got := fn.Signature(); got != test.sig {
+			t.Errorf("signature of %s: got %s, want %s", test.name, got, test.sig)
+		}
+		if fn.Doc != test.doc {
+			t.Errorf("doc of %s: got %q, want %q", test.name, fn.Doc, test.doc)
+		}
+	}
+}
+
+func TestBuiltinModuleError(t *testing.T) {
+	_, err := docgen.BuiltinModule("m", "", map[string]string{"f(x": ""})
+	if err == nil || !strings.Contains(err.Error(), `invalid signature "f(x"`) {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package docgen
+
+// This file defines the Markdown and HTML renderers.
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// A page is the form of the documentation used by the renderers,
+// with the anchors of modules and functions resolved.
+type page struct {
+	Title   string
+	Modules []*modulePage
+}
+
+type modulePage struct {
+	*Module
+	Anchor string
+	Loads  []loadPage
+	Funcs  []funcPage
+}
+
+type loadPage struct {
+	Module, Anchor string // Anchor is empty if the module is not documented
+	Names          []link
+}
+
+type funcPage struct {
+	*Function
+	Anchor string
+}
+
+type link struct {
+	Text, Anchor string
+}
+
+func newPage(title string, modules []*Module) *page {
+	// The anchor of a function is that of its module, a dot, and its
+	// name, so it is unique if the module's anchor is unique.
+	type funcKey struct{ module, name string }
+	anchors := make(map[string]string) // anchors of modules
+	funcAnchors := make(map[funcKey]string)
+	used := make(map[string]bool)
+	for _, m := range modules {
+		a := anchor(m.Name)
+		for i := 2; used[a]; i++ {
+			a = fmt.Sprintf("%s-%d", anchor(m.Name), i)
+		}
+		used[a] = true
+		anchors[m.Name] = a
+		for _, fn := range m.Functions {
+			funcAnchors[funcKey{m.Name, fn.Name}] = a + "." + fn.Name
+		}
+	}
+	p := &page{Title: title}
+	for _, m := range modules {
+		mp := &modulePage{Module: m, Anchor: anchors[m.Name]}
+		for _, load := range m.Loads {
+			lp := loadPage{Module: load.Module, Anchor: anchors[load.Module]}
+			for _, name := range load.Names {
+				lp.Names = append(lp.Names, link{name, funcAnchors[funcKey{load.Module, name}]})
+			}
+			mp.Loads = append(mp.Loads, lp)
+		}
+		for _, fn := range m.Functions {
+			mp.Funcs = append(mp.Funcs, funcPage{fn, funcAnchors[funcKey{m.Name, fn.Name}]})
+		}
+		p.Modules = append(p.Modules, mp)
+	}
+	return p
+}
+
+// anchor returns the anchor of a module: its name, with each character
+// other than a letter, digit, hyphen, or underscore replaced by a
+// hyphen, and a "module-" prefix. Distinct names may have the same
+// anchor, so newPage adds a numeric suffix to all but the first.
+func anchor(name string) string {
+	return "module-" + strings.Map(func(r rune) rune {
+		if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '-'
+	}, name)
+}
+
+// Markdown writes the documentation of the modules to w as a single
+// Markdown document with the specified title. Doc strings are copied
+// verbatim, so they may use Markdown syntax.
+func Markdown(w io.Writer, title string, modules []*Module) error {
+	p := newPage(title, modules)
+	out := bufio.NewWriter(w)
+	if title != "" {
+		fmt.Fprintf(out, "# %s\n\n", title)
+	}
+	if len(p.Modules) > 1 {
+		for _, m := range p.Modules {
+			fmt.Fprintf(out, "- [`%s`](#%s)\n", m.Name, m.Anchor)
+		}
+		fmt.Fprintln(out)
+	}
+	for _, m := range p.Modules {
+		fmt.Fprintf(out, "<a id=\"%s\"></a>\n\n", m.Anchor)
+		if m.Builtin {
+			fmt.Fprintf(out, "## `%s` (built-in)\n\n", m.Name)
+		} else {
+			fmt.Fprintf(out, "## `%s`\n\n", m.Name)
+		}
+		if m.Doc != "" {
+			fmt.Fprintf(out, "%s\n\n", m.Doc)
+		}
+		if len(m.Loads) > 0 {
+			fmt.Fprintf(out, "Loads:\n\n")
+			for _, load := range m.Loads {
+				fmt.Fprintf(out, "- %s:", mdLink(load.Module, load.Anchor))
+				for i, name := range load.Names {
+					if i > 0 {
+						out.WriteString(",")
+					}
+					fmt.Fprintf(out, " %s", mdLink(name.Text, name.Anchor))
+				}
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintln(out)
+		}
+		for _, fn := range m.Funcs {
+			fmt.Fprintf(out, "<a id=\"%s\"></a>\n\n", fn.Anchor)
+			fmt.Fprintf(out, "### `%s`\n\n", fn.Name)
+			fmt.Fprintf(out, "```python\n%s\n```\n\n", fn.Signature())
+			if fn.Doc != "" {
+				fmt.Fprintf(out, "%s\n\n", fn.Doc)
+			}
+		}
+		if len(m.Values) > 0 {
+			fmt.Fprintf(out, "Other values:")
+			for i, v := range m.Values {
+				if i > 0 {
+					out.WriteString(",")
+				}
+				fmt.Fprintf(out, " `%s`", v)
+			}
+			fmt.Fprintf(out, "\n\n")
+		}
+	}
+	return out.Flush()
+}
+
+func mdLink(text, anchor string) string {
+	if anchor == "" {
+		return "`" + text + "`"
+	}
+	return fmt.Sprintf("[`%s`](#%s)", text, anchor)
+}
+
+// HTML writes the documentation of the modules to w as a single
+// self-contained HTML page with the specified title. Doc strings are
+// presented as preformatted text.
+func HTML(w io.Writer, title string, modules []*Module) error {
+	return htmlTemplate.Execute(w, newPage(title, modules))
+}
+
+var htmlTemplate = template.Must(template.New("doc").Funcs(template.FuncMap{
+	"link": func(text, anchor string) link { return link{text, anchor} },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: auto; padding: 1em; }
+pre, code { font-family: monospace; }
+pre.sig { background: #f4f4f4; padding: 0.5em; }
+.doc { white-space: pre-wrap; }
+</style>
+</head>
+<body>
+{{- with .Title}}
+<h1>{{.}}</h1>
+{{- end}}
+{{- if gt (len .Modules) 1}}
+<ul>
+{{- range .Modules}}
+<li><a href="#{{.Anchor}}"><code>{{.Name}}</code></a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Modules}}
+<section id="{{.Anchor}}">
+<h2><code>{{.Name}}</code>{{if .Builtin}} (built-in){{end}}</h2>
+{{- with .Doc}}
+<p class="doc">{{.}}</p>
+{{- end}}
+{{- with .Loads}}
+<p>Loads:</p>
+<ul>
+{{- range .}}
+<li>{{template "link" (link .Module .Anchor)}}:{{range $i, $n := .Names}}{{if $i}},{{end}} {{template "link" $n}}{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- range .Funcs}}
+<section id="{{.Anchor}}">
+<h3><code>{{.Name}}</code></h3>
+<pre class="sig">{{.Signature}}</pre>
+{{- with .Doc}}
+<p class="doc">{{.}}</p>
+{{- end}}
+</section>
+{{- end}}
+{{- with .Values}}
+<p>Other values:{{range $i, $v := .}}{{if $i}},{{end}} <code>{{$v}}</code>{{end}}</p>
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+{{define "link"}}{{if .Anchor}}<a href="#{{.Anchor}}"><code>{{.Text}}</code></a>{{else}}<code>{{.Text}}</code>{{end}}{{end}}
+`))
@@ -0,0 +1,563 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package index builds a cross-file index of a tree of Starlark
+// modules connected by load statements.
+//
+// Starting from a set of root modules, Config.Build parses and
+// resolves each module and, transitively, every module it loads,
+// using a modresolve.Resolver to interpret module names. The
+// resulting Index records, for each module, its load statements and
+// its exported globals (Symbols) with their doc strings; and a static
+// call graph of the functions defined by def statements. It answers
+// queries such as "which modules load this one?" and "which calls
+// may invoke this function?".
+//
+// The call graph is static and conservative in the usual ways: a call
+// has a known callee only if its operand is a name that denotes a
+// function defined by a def statement, directly, through a load
+// statement, or through a global variable assigned from such a name.
+// Calls of built-ins, of attributes such as native.glob, of lambdas,
+// and of computed values have no known callee.
+//
+// All types in this package may be encoded as JSON.
+package index // import "go.starlark.net/index"
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go.starlark.net/modresolve"
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// A Config specifies how to build an Index.
+type Config struct {
+	// Resolver resolves the module names of load statements
+	// and opens modules. It must not be nil.
+	Resolver modresolve.Resolver
+
+	// Options specifies the dialect of the modules.
+	// If nil, the default dialect is used.
+	Options *syntax.FileOptions
+
+	// IsPredeclared and IsUniversal report whether a name is
+	// predeclared in each module, or universal, as for
+	// resolve.File. A nil function reports false for all names.
+	IsPredeclared func(name string) bool
+	IsUniversal   func(name string) bool
+}
+
+// An Index is a cross-file index of a tree of modules.
+type Index struct {
+	Modules   []*Module   // all modules, in order of key
+	Functions []*Function // all functions, in order of module and position
+
+	resolver modresolve.Resolver
+	modules  map[string]*Module
+	callers  map[*Function][]*Call
+	loaders  map[*Module][]*Load
+}
+
+// A Module is a module in the index.
+type Module struct {
+	Key     string    // canonical key, from the Resolver
+	Doc     string    // doc string of the file, if any
+	Virtual bool      // defined in Go (see modresolve.Virtual); has no source
+	Loads   []*Load   // load statements, in order
+	Symbols []*Symbol // exported global names, in order of position (or name, if Virtual)
+	Errors  []string  // syntax, resolution, and load errors
+
+	// Toplevel is a pseudo-function, named "<toplevel>", whose
+	// Calls are the calls made by the module's top-level statements.
+	// It is nil if the module is Virtual.
+	Toplevel *Function
+
+	// information about a non-virtual module
+	src     []byte
+	lines   []int // offset of the start of each line of src
+	file    *syntax.File
+	info    *resolve.Info
+	globals map[string]*resolve.Binding     // exported and unexported global variables
+	defs    map[*resolve.Binding]*Function  // function bound by each def statement
+	loaded  map[*resolve.Binding]loadedName // variable bound by each load
+	aliases map[*resolve.Binding]*syntax.Ident
+}
+
+// Source returns the source text of the module,
+// or nil if the module is Virtual or could not be opened.
+func (m *Module) Source() []byte { return m.src }
+
+// Syntax returns the syntax tree of the module, which has been
+// resolved, or nil if the module is Virtual or could not be parsed.
+// If m.Errors is not empty, the tree may be incomplete.
+func (m *Module) Syntax() *syntax.File { return m.file }
+
+// Info returns the resolver's information about the module,
+// or nil if Syntax returns nil.
+func (m *Module) Info() *resolve.Info { return m.info }
+
+type loadedName struct {
+	load   *Load
+	remote string
+}
+
+// A Load is a load statement.
+type Load struct {
+	From   *Module         // the loading module
+	Pos    syntax.Position // position of the load statement
+	Name   string          // module name, as it appears in the statement
+	Target *Module         // the loaded module, or nil if it could not be resolved
+	Names  []LoadedName    // the names bound by the statement
+	Err    string          // the error resolving the module name, if any
+
+	stmt *syntax.LoadStmt
+}
+
+// Stmt returns the syntax of the load statement.
+func (load *Load) Stmt() *syntax.LoadStmt { return load.stmt }
+
+// A LoadedName is a name bound by a load statement:
+// load(module, Local=Remote).
+type LoadedName struct {
+	Local, Remote string
+}
+
+// A Symbol is an exported global name of a module:
+// a name not beginning with an underscore.
+type Symbol struct {
+	Module *Module
+	Name   string
+	Kind   string          // "function", "variable", or, in a Virtual module, "value"
+	Pos    syntax.Position // position of the first binding (invalid if Virtual)
+
+	// Func is the function denoted by the name: the function defined
+	// by a def statement, or, for a variable, the function denoted by
+	// the name from which it is assigned, if any. It is nil if the
+	// name does not denote a known function.
+	Func *Function
+}
+
+// Doc returns the doc string of the symbol's function, if any.
+func (sym *Symbol) Doc() string {
+	if sym.Func != nil {
+		return sym.Func.Doc
+	}
+	return ""
+}
+
+// A Function is a function defined by a def statement, or the
+// pseudo-function of a module's top-level statements.
+type Function struct {
+	Module *Module
+	Name   string          // "f", or "f.g" for a def g nested within f, or "<toplevel>"
+	Pos    syntax.Position // position of the function name
+	Doc    string          // doc string, if any
+	Params []string        // source text of each parameter, such as "x", "y=1", or "*args"
+	Calls  []*Call         // calls within the function body, excluding nested defs
+}
+
+// A Call is a call expression.
+type Call struct {
+	Caller *Function
+	Pos    syntax.Position // position of the call's operand
+	Name   string          // source text of the operand, such as "f" or "native.glob"
+	Callee *Function       // the function called, if known statically
+
+	ref *syntax.Ident // the operand, if an identifier
+}
+
+// Build builds an index of the modules with the specified names,
+// which are resolved as for root modules (with from == ""), and of
+// all the modules they load, transitively.
+//
+// Build reports an error only if a root module cannot be resolved or
+// opened. Errors in other modules are recorded in Module.Errors and
+// Load.Err, and the index describes as much of each module as could
+// be parsed and resolved.
+func (cfg *Config) Build(roots ...string) (*Index, error) {
+	ix := &Index{
+		resolver: cfg.Resolver,
+		modules:  make(map[string]*Module),
+		callers:  make(map[*Function][]*Call),
+		loaders:  make(map[*Module][]*Load),
+	}
+
+	var level []*Module
+	for _, name := range roots {
+		key, err := cfg.Resolver.Resolve("", name)
+		if err != nil {
+			return nil, err
+		}
+		if ix.modules[key] == nil {
+			m := &Module{Key: key}
+			ix.modules[key] = m
+			level = append(level, m)
+		}
+	}
+
+	// Load modules breadth-first, each level in parallel.
+	for first := true; len(level) > 0; first = false {
+		if err := cfg.loadAll(level); err != nil && first {
+			return nil, err
+		}
+		var next []*Module
+		for _, m := range level {
+			for _, load := range m.Loads {
+				if load.Err != "" {
+					continue
+				}
+				key := load.Target.Key
+				if prev := ix.modules[key]; prev != nil {
+					load.Target = prev
+				} else {
+					ix.modules[key] = load.Target
+					next = append(next, load.Target)
+				}
+			}
+		}
+		level = next
+	}
+
+	for _, m := range ix.modules {
+		ix.Modules = append(ix.Modules, m)
+	}
+	sort.Slice(ix.Modules, func(i, j int) bool { return ix.Modules[i].Key < ix.Modules[j].Key })
+	ix.link()
+	return ix, nil
+}
+
+// loadAll loads the modules in parallel. If any module cannot be
+// opened, it records the error in the module and returns it.
+func (cfg *Config) loadAll(modules []*Module) error {
+	errs := make([]error, len(modules))
+	sema := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, m := range modules {
+		wg.Add(1)
+		sema <- struct{}{}
+		go func() {
+			defer func() { <-sema; wg.Done() }()
+			if err := cfg.load(m); err != nil {
+				m.Errors = append(m.Errors, err.Error())
+				errs[i] = err
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// load opens, parses, and resolves the module m, whose Key is set.
+// The Target of each of its loads is a new Module with only its Key set.
+func (cfg *Config) load(m *Module) error {
+	content, err := cfg.Resolver.Open(m.Key)
+	if err != nil {
+		return err
+	}
+	if content.Source == nil {
+		m.Virtual = true
+		for _, name := range content.Members.Keys() {
+			if !strings.HasPrefix(name, "_") {
+				m.Symbols = append(m.Symbols, &Symbol{Module: m, Name: name, Kind: "value"})
+			}
+		}
+		return nil
+	}
+	m.src = content.Source
+	m.lines = []int{0}
+	for i, b := range m.src {
+		if b == '\n' {
+			m.lines = append(m.lines, i+1)
+		}
+	}
+
+	opts := cfg.Options
+	if opts == nil {
+		opts = new(syntax.FileOptions)
+	}
+	f, err := opts.Parse(m.Key, m.src, syntax.RecoverErrors)
+	m.addErrors(err)
+	if f == nil {
+		return nil
+	}
+	m.addErrors(resolve.File(f, orFalse(cfg.IsPredeclared), orFalse(cfg.IsUniversal)))
+	m.file = f
+	m.info = resolve.NewInfo(f)
+	m.Doc = docString(f.Stmts)
+
+	// Loads.
+	m.loaded = make(map[*resolve.Binding]loadedName)
+	syntax.Walk(f, func(n syntax.Node) bool {
+		stmt, ok := n.(*syntax.LoadStmt)
+		if !ok {
+			return true
+		}
+		load := &Load{From: m, Pos: stmt.Load, Name: stmt.ModuleName(), stmt: stmt}
+		for i, to := range stmt.To {
+			load.Names = append(load.Names, LoadedName{to.Name, stmt.From[i].Name})
+			if bind := m.info.Binding(to); bind != nil {
+				m.loaded[m.info.Origin(bind)] = loadedName{load, stmt.From[i].Name}
+			}
+		}
+		if key, err := cfg.Resolver.Resolve(m.Key, load.Name); err != nil {
+			load.Err = err.Error()
+			m.Errors = append(m.Errors, fmt.Sprintf("%s: %v", load.Pos, err))
+		} else {
+			load.Target = &Module{Key: key}
+		}
+		m.Loads = append(m.Loads, load)
+		return false
+	})
+
+	// Functions and calls.
+	m.Toplevel = &Function{Module: m, Name: "<toplevel>", Pos: syntax.MakePosition(&f.Path, 1, 1)}
+	m.defs = make(map[*resolve.Binding]*Function)
+	m.aliases = make(map[*resolve.Binding]*syntax.Ident)
+	var stack []syntax.Node // nodes on the path from the root
+	fns := []*Function{m.Toplevel}
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if n == nil {
+			if _, ok := stack[len(stack)-1].(*syntax.DefStmt); ok {
+				fns = fns[:len(fns)-1]
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+
+		fn := fns[len(fns)-1]
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			name := n.Name.Name
+			if fn != m.Toplevel {
+				name = fn.Name + "." + name
+			}
+			def := &Function{
+				Module: m,
+				Name:   name,
+				Pos:    n.Name.NamePos,
+				Doc:    docString(n.Body),
+			}
+			for _, param := range n.Params {
+				start, end := param.Span()
+				def.Params = append(def.Params, m.text(start, end))
+			}
+			if bind := m.info.Binding(n.Name); bind != nil {
+				if _, ok := m.defs[bind]; !ok {
+					m.defs[bind] = def
+				}
+			}
+			fns = append(fns, def)
+
+		case *syntax.AssignStmt:
+			// Record global aliases such as x = f.
+			lhs, ok1 := n.LHS.(*syntax.Ident)
+			rhs, ok2 := n.RHS.(*syntax.Ident)
+			if ok1 && ok2 && n.Op == syntax.EQ {
+				if bind := m.info.Binding(lhs); bind != nil && len(m.info.Defs(bind)) == 1 {
+					m.aliases[bind] = rhs
+				}
+			}
+
+		case *syntax.CallExpr:
+			start, end := n.Fn.Span()
+			call := &Call{Caller: fn, Pos: start, Name: m.text(start, end)}
+			call.ref, _ = n.Fn.(*syntax.Ident)
+			fn.Calls = append(fn.Calls, call)
+		}
+		return true
+	})
+
+	// Exported symbols.
+	m.globals = make(map[string]*resolve.Binding)
+	for _, bind := range m.info.Root.Bindings {
+		if bind.Scope != resolve.Global {
+			continue
+		}
+		id := bind.First
+		m.globals[id.Name] = bind
+		if strings.HasPrefix(id.Name, "_") {
+			continue
+		}
+		kind := "variable"
+		if _, ok := m.defs[bind]; ok {
+			kind = "function"
+		}
+		m.Symbols = append(m.Symbols, &Symbol{Module: m, Name: id.Name, Kind: kind, Pos: id.NamePos})
+	}
+	return nil
+}
+
+func (m *Module) addErrors(err error) {
+	switch err := err.(type) {
+	case nil:
+	case syntax.ErrorList:
+		for _, e := range err {
+			m.Errors = append(m.Errors, e.Error())
+		}
+	case resolve.ErrorList:
+		for _, e := range err {
+			m.Errors = append(m.Errors, e.Error())
+		}
+	default:
+		m.Errors = append(m.Errors, err.Error())
+	}
+}
+
+// link computes the functions denoted by symbols and called by calls,
+// and the inverse relations.
+func (ix *Index) link() {
+	for _, m := range ix.Modules {
+		if m.Virtual {
+			continue
+		}
+		for _, sym := range m.Symbols {
+			sym.Func = ix.funcOf(m, m.globals[sym.Name], 0)
+		}
+		ix.Functions = append(ix.Functions, m.Toplevel)
+		for _, fn := range m.defs {
+			ix.Functions = append(ix.Functions, fn)
+		}
+		for _, load := range m.Loads {
+			if load.Target != nil {
+				ix.loaders[load.Target] = append(ix.loaders[load.Target], load)
+			}
+		}
+	}
+	sort.SliceStable(ix.Functions, func(i, j int) bool {
+		x, y := ix.Functions[i], ix.Functions[j]
+		if x.Module != y.Module {
+			return x.Module.Key < y.Module.Key
+		}
+		return x.Pos.Line < y.Pos.Line || x.Pos.Line == y.Pos.Line && x.Pos.Col < y.Pos.Col
+	})
+	for _, fn := range ix.Functions {
+		for _, call := range fn.Calls {
+			if call.ref != nil {
+				call.Callee = ix.funcOf(fn.Module, fn.Module.info.Binding(call.ref), 0)
+			}
+			if call.Callee != nil {
+				ix.callers[call.Callee] = append(ix.callers[call.Callee], call)
+			}
+		}
+	}
+}
+
+// funcOf returns the function denoted by a variable of module m,
+// following load statements and aliases, or nil if unknown.
+func (ix *Index) funcOf(m *Module, bind *resolve.Binding, depth int) *Function {
+	const maxDepth = 100 // guards against alias cycles across modules
+	if bind == nil || depth > maxDepth {
+		return nil
+	}
+	bind = m.info.Origin(bind)
+	if fn, ok := m.defs[bind]; ok {
+		return fn
+	}
+	if l, ok := m.loaded[bind]; ok {
+		if t := l.load.Target; t != nil && !t.Virtual && t.info != nil {
+			return ix.funcOf(t, t.globals[l.remote], depth+1)
+		}
+		return nil
+	}
+	if rhs, ok := m.aliases[bind]; ok {
+		return ix.funcOf(m, m.info.Binding(rhs), depth+1)
+	}
+	return nil
+}
+
+// Resolve resolves a module name as if it appeared in a load
+// statement of the module with key from, using the Config's Resolver.
+func (ix *Index) Resolve(from, name string) (string, error) {
+	return ix.resolver.Resolve(from, name)
+}
+
+// Module returns the module with the specified key, or nil.
+func (ix *Index) Module(key string) *Module { return ix.modules[key] }
+
+// Lookup returns the exported symbol name of the module with the
+// specified key, or nil.
+func (ix *Index) Lookup(key, name string) *Symbol {
+	if m := ix.modules[key]; m != nil {
+		for _, sym := range m.Symbols {
+			if sym.Name == name {
+				return sym
+			}
+		}
+	}
+	return nil
+}
+
+// Loaders returns the load statements that load module m,
+// in order of loading module and position.
+func (ix *Index) Loaders(m *Module) []*Load { return ix.loaders[m] }
+
+// LoadersOf returns the load statements that load the symbol sym,
+// in order of loading module and position.
+func (ix *Index) LoadersOf(sym *Symbol) []*Load {
+	var loads []*Load
+	for _, load := range ix.loaders[sym.Module] {
+		for _, name := range load.Names {
+			if name.Remote == sym.Name {
+				loads = append(loads, load)
+				break
+			}
+		}
+	}
+	return loads
+}
+
+// Callers returns the calls whose callee is statically known to be
+// fn, in order of calling function and position.
+func (ix *Index) Callers(fn *Function) []*Call { return ix.callers[fn] }
+
+// -- helpers --
+
+// docString returns the doc string of a file or function body:
+// the value of a string literal that is the first statement.
+func docString(stmts []syntax.Stmt) string {
+	if len(stmts) > 0 {
+		if expr, ok := stmts[0].(*syntax.ExprStmt); ok {
+			if lit, ok := expr.X.(*syntax.Literal); ok && lit.Token == syntax.STRING {
+				return lit.Value.(string)
+			}
+		}
+	}
+	return ""
+}
+
+// text returns the source text of m between the start and end
+// positions, whose columns are measured in runes.
+func (m *Module) text(start, end syntax.Position) string {
+	offset := func(pos syntax.Position) int {
+		if pos.Line < 1 || int(pos.Line) > len(m.lines) {
+			return len(m.src)
+		}
+		i := m.lines[pos.Line-1]
+		for col := pos.Col; col > 1 && i < len(m.src); col-- {
+			_, size := utf8.DecodeRune(m.src[i:])
+			i += size
+		}
+		return i
+	}
+	i, j := offset(start), offset(end)
+	if i > j {
+		return ""
+	}
+	return string(m.src[i:j])
+}
+
+func orFalse(f func(string) bool) func(string) bool {
+	if f == nil {
+		return func(string) bool { return false }
+	}
+	return f
+}
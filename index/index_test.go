@@ -0,0 +1,213 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.starlark.net/index"
+	"go.starlark.net/modresolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func file(s string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(s)} }
+
+func buildIndex(t *testing.T) *index.Index {
+	t.Helper()
+	fsys := fstest.MapFS{
+		"main.star": file(`"""The main module."""
+
+load("lib/rules.star", "my_rule", "helper")
+load("math", "pi")
+load("missing.star", "m")
+
+def build():
+    """Builds everything."""
+    my_rule(name = "a")
+    helper(pi)
+    native.glob(["*"])
+
+build()
+`),
+		"lib/rules.star": file(`load("util.star", _util = "util")
+
+def my_rule(name, deps = [], *args, **kwargs):
+    """Defines a rule.
+
+    More details."""
+    _util(name)
+    def inner():
+        return _util(name)
+    return inner()
+
+helper = _util
+_private = 1
+`),
+		"lib/util.star": file(`def util(x):
+    return len(x)
+`),
+	}
+	var virtual modresolve.Virtual
+	virtual.Register(&starlarkstruct.Module{
+		Name:    "math",
+		Members: starlark.StringDict{"pi": starlark.MakeInt(3), "_e": starlark.MakeInt(2)},
+	})
+	cfg := &index.Config{
+		Resolver:      modresolve.NewChain(&virtual, modresolve.Relative{FS: fsys}),
+		IsPredeclared: func(name string) bool { return name == "native" },
+		IsUniversal:   starlark.Universe.Has,
+	}
+	ix, err := cfg.Build("main.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ix
+}
+
+func TestIndex(t *testing.T) {
+	ix := buildIndex(t)
+
+	// Modules and symbols.
+	var buf strings.Builder
+	for _, m := range ix.Modules {
+		fmt.Fprintf(&buf, "%s virtual=%t doc=%q errors=%d\n", m.Key, m.Virtual, m.Doc, len(m.Errors))
+		for _, sym := range m.Symbols {
+			fmt.Fprintf(&buf, "\t%s %s %s", sym.Kind, sym.Name, sym.Pos)
+			if sym.Func != nil {
+				fmt.Fprintf(&buf, " -> %s:%s", sym.Func.Module.Key, sym.Func.Name)
+			}
+			buf.WriteByte('\n')
+		}
+	}
+	want := `lib/rules.star virtual=false doc="" errors=0
+	function my_rule lib/rules.star:3:5 -> lib/rules.star:my_rule
+	variable helper lib/rules.star:12:1 -> lib/util.star:util
+lib/util.star virtual=false doc="" errors=0
+	function util lib/util.star:1:5 -> lib/util.star:util
+main.star virtual=false doc="The main module." errors=1
+	function build main.star:7:5 -> main.star:build
+math virtual=true doc="" errors=0
+	value pi <invalid>
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got modules:\n%s\nwant:\n%s", got, want)
+	}
+
+	main := ix.Module("main.star")
+	if err := main.Errors[0]; !strings.Contains(err, "missing.star") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if load := main.Loads[2]; load.Target != nil || load.Err == "" {
+		t.Errorf("load of missing.star: got target %v, error %q", load.Target, load.Err)
+	}
+
+	myRule := ix.Lookup("lib/rules.star", "my_rule")
+	if got, want := myRule.Doc(), "Defines a rule.\n\n    More details."; got != want {
+		t.Errorf("my_rule doc = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(myRule.Func.Params, ", "), "name, deps = [], *args, **kwargs"; got != want {
+		t.Errorf("my_rule params = %s, want %s", got, want)
+	}
+	if ix.Lookup("lib/rules.star", "_private") != nil || ix.Lookup("math", "_e") != nil {
+		t.Errorf("Lookup returned unexported symbol")
+	}
+
+	// Call graph.
+	callers := func(key, name string) string {
+		var names []string
+		for _, call := range ix.Callers(ix.Lookup(key, name).Func) {
+			names = append(names, fmt.Sprintf("%s:%s@%d", call.Caller.Module.Key, call.Caller.Name, call.Pos.Line))
+		}
+		return strings.Join(names, " ")
+	}
+	for _, test := range []struct{ key, name, want string }{
+		{"lib/util.star", "util", "lib/rules.star:my_rule@7 lib/rules.star:my_rule.inner@9 main.star:build@10"},
+		{"lib/rules.star", "my_rule", "main.star:build@9"},
+		{"main.star", "build", "main.star:<toplevel>@13"},
+	} {
+		if got := callers(test.key, test.name); got != test.want {
+			t.Errorf("callers of %s:%s = %s, want %s", test.key, test.name, got, test.want)
+		}
+	}
+	var calls []string
+	for _, call := range ix.Lookup("main.star", "build").Func.Calls {
+		callee := "?"
+		if call.Callee != nil {
+			callee = call.Callee.Name
+		}
+		calls = append(calls, call.Name+"->"+callee)
+	}
+	if got, want := strings.Join(calls, " "), "my_rule->my_rule helper->util native.glob->?"; got != want {
+		t.Errorf("calls of build = %s, want %s", got, want)
+	}
+
+	// Loads.
+	loaders := func(loads []*index.Load) string {
+		var keys []string
+		for _, load := range loads {
+			keys = append(keys, fmt.Sprintf("%s", load.Pos))
+		}
+		return strings.Join(keys, " ")
+	}
+	if got, want := loaders(ix.Loaders(ix.Module("lib/util.star"))), "lib/rules.star:1:1"; got != want {
+		t.Errorf("loaders of util.star = %s, want %s", got, want)
+	}
+	if got, want := loaders(ix.LoadersOf(myRule)), "main.star:3:1"; got != want {
+		t.Errorf("loaders of my_rule = %s, want %s", got, want)
+	}
+	if got := loaders(ix.LoadersOf(ix.Lookup("lib/rules.star", "helper"))); got != "main.star:3:1" {
+		t.Errorf("loaders of helper = %s", got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	ix := buildIndex(t)
+	data, err := json.Marshal(ix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Modules []struct {
+			Key     string
+			Loads   []struct{ From, Module, Key, Error string }
+			Symbols []struct {
+				Name     string
+				Function *struct{ Module, Name string }
+			}
+		}
+		Functions []struct {
+			Module, Name string
+			Calls        []struct {
+				Name   string
+				Callee *struct{ Module, Name string }
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Modules) != 4 || len(got.Functions) != 7 {
+		t.Fatalf("got %d modules and %d functions, want 4 and 7:\n%s", len(got.Modules), len(got.Functions), data)
+	}
+	rules := got.Modules[0]
+	if rules.Key != "lib/rules.star" || rules.Loads[0].Key != "lib/util.star" || rules.Loads[0].From != "lib/rules.star" {
+		t.Errorf("wrong loads of %s: %+v", rules.Key, rules.Loads)
+	}
+	if fn := rules.Symbols[1].Function; fn == nil || fn.Module != "lib/util.star" || fn.Name != "util" {
+		t.Errorf("wrong function for symbol %s: %+v", rules.Symbols[1].Name, fn)
+	}
+	for _, fn := range got.Functions {
+		if fn.Module == "main.star" && fn.Name == "build" {
+			if callee := fn.Calls[0].Callee; callee == nil || callee.Name != "my_rule" {
+				t.Errorf("wrong callee of %s: %+v", fn.Calls[0].Name, callee)
+			}
+		}
+	}
+}
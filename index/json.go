@@ -0,0 +1,107 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package index
+
+// This file defines the JSON encoding of the index.
+// References between objects, such as from a call to its callee,
+// are encoded as the module key and name of the referent.
+
+import (
+	"encoding/json"
+
+	"go.starlark.net/syntax"
+)
+
+// A funcRef is the JSON encoding of a reference to a Function.
+type funcRef struct {
+	Module string `json:"module"`
+	Name   string `json:"name"`
+}
+
+func refTo(fn *Function) *funcRef {
+	if fn == nil {
+		return nil
+	}
+	return &funcRef{fn.Module.Key, fn.Name}
+}
+
+func posString(pos syntax.Position) string {
+	if !pos.IsValid() {
+		return ""
+	}
+	return pos.String()
+}
+
+func (ix *Index) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Modules   []*Module   `json:"modules"`
+		Functions []*Function `json:"functions"`
+	}{ix.Modules, ix.Functions})
+}
+
+func (m *Module) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Key     string    `json:"key"`
+		Doc     string    `json:"doc,omitempty"`
+		Virtual bool      `json:"virtual,omitempty"`
+		Loads   []*Load   `json:"loads,omitempty"`
+		Symbols []*Symbol `json:"symbols,omitempty"`
+		Errors  []string  `json:"errors,omitempty"`
+	}{m.Key, m.Doc, m.Virtual, m.Loads, m.Symbols, m.Errors})
+}
+
+func (load *Load) MarshalJSON() ([]byte, error) {
+	type name struct {
+		Local  string `json:"local"`
+		Remote string `json:"remote"`
+	}
+	names := make([]name, len(load.Names))
+	for i, n := range load.Names {
+		names[i] = name{n.Local, n.Remote}
+	}
+	var target string
+	if load.Target != nil {
+		target = load.Target.Key
+	}
+	return json.Marshal(struct {
+		From   string `json:"from"`
+		Pos    string `json:"pos"`
+		Module string `json:"module"`
+		Key    string `json:"key,omitempty"`
+		Names  []name `json:"names"`
+		Err    string `json:"error,omitempty"`
+	}{load.From.Key, posString(load.Pos), load.Name, target, names, load.Err})
+}
+
+func (sym *Symbol) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Module string   `json:"module"`
+		Name   string   `json:"name"`
+		Kind   string   `json:"kind"`
+		Pos    string   `json:"pos,omitempty"`
+		Doc    string   `json:"doc,omitempty"`
+		Func   *funcRef `json:"function,omitempty"`
+	}{sym.Module.Key, sym.Name, sym.Kind, posString(sym.Pos), sym.Doc(), refTo(sym.Func)})
+}
+
+func (fn *Function) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Module string   `json:"module"`
+		Name   string   `json:"name"`
+		Pos    string   `json:"pos"`
+		Doc    string   `json:"doc,omitempty"`
+		Params []string `json:"params,omitempty"`
+		Calls  []*Call  `json:"calls,omitempty"`
+	}{fn.Module.Key, fn.Name, posString(fn.Pos), fn.Doc, fn.Params, fn.Calls})
+}
+
+func (call *Call) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Caller *funcRef `json:"caller"`
+		Pos    string   `json:"pos"`
+		Name   string   `json:"name"`
+		Callee *funcRef `json:"callee,omitempty"`
+	}{refTo(call.Caller), posString(call.Pos), call.Name, refTo(call.Callee)})
+}
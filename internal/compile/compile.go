@@ -35,6 +35,7 @@ import (
 	"strings"
 	"sync"
 
+	"go.starlark.net/internal/types"
 	"go.starlark.net/resolve"
 	"go.starlark.net/syntax"
 )
@@ -46,7 +47,7 @@ var Disassemble = false
 const debug = false // make code generation verbose, for debugging the compiler
 
 // Increment this to force recompilation of saved bytecode files.
-const Version = 14
+const Version = 20
 
 type Opcode uint8
 
@@ -112,6 +113,7 @@ const (
 	INPLACE_ADD  //            x y INPLACE_ADD  z      where z is x+y or x.extend(y)
 	INPLACE_PIPE //            x y INPLACE_PIPE z      where z is x|y
 	MAKEDICT     //              - MAKEDICT     dict
+	RERAISE      //          error RERAISE      -    [re-raises an error caught by a finally clause]
 
 	// --- opcodes with an argument must go below this line ---
 
@@ -139,6 +141,8 @@ const (
 	ATTR         //                 x ATTR<name>          y           y = x.name
 	SETFIELD     //               x y SETFIELD<name>      -           x.name = y
 	UNPACK       //          iterable UNPACK<n>           vn ... v1
+	FORMAT       //                 x FORMAT<constant>    string      (f-string field; constant is "!conv:spec")
+	CONCAT       //         s1 ... sn CONCAT<n>           string      (f-string)
 
 	// n>>8 is #positional args and n&0xff is #named args (pairs).
 	CALL        // fn positional named                CALL<n>        result
@@ -162,12 +166,14 @@ var opcodeNames = [...]string{
 	CALL_VAR_KW:  "call_var_kw",
 	CIRCUMFLEX:   "circumflex",
 	CJMP:         "cjmp",
+	CONCAT:       "concat",
 	CONSTANT:     "constant",
 	DUP2:         "dup2",
 	DUP:          "dup",
 	EQL:          "eql",
 	EXCH:         "exch",
 	FALSE:        "false",
+	FORMAT:       "format",
 	FREE:         "free",
 	FREECELL:     "freecell",
 	GE:           "ge",
@@ -202,6 +208,7 @@ var opcodeNames = [...]string{
 	PIPE:         "pipe",
 	PLUS:         "plus",
 	POP:          "pop",
+	RERAISE:      "reraise",
 	PREDECLARED:  "predeclared",
 	RETURN:       "return",
 	SETDICT:      "setdict",
@@ -237,11 +244,13 @@ var stackEffect = [...]int8{
 	CALL_VAR_KW:  variableStackEffect,
 	CIRCUMFLEX:   -1,
 	CJMP:         -1,
+	CONCAT:       variableStackEffect,
 	CONSTANT:     +1,
 	DUP2:         +2,
 	DUP:          +1,
 	EQL:          -1,
 	FALSE:        +1,
+	FORMAT:       0,
 	FREE:         +1,
 	FREECELL:     +1,
 	GE:           -1,
@@ -277,6 +286,7 @@ var stackEffect = [...]int8{
 	PLUS:         -1,
 	POP:          -1,
 	PREDECLARED:  +1,
+	RERAISE:      -1,
 	RETURN:       -1,
 	SETLOCALCELL: -1,
 	SETDICT:      -3,
@@ -317,6 +327,9 @@ type Program struct {
 	Globals   []Binding // for error messages and tracing
 	Toplevel  *Funcode  // module initialization function
 	Recursion bool      // disable recursion check for functions in this file
+
+	Options      syntax.FileOptions // options used to compile this file
+	SourceDigest []byte             // digest of the source file, if known
 }
 
 // The type of a bytes literal value, to distinguish from text string.
@@ -341,12 +354,52 @@ type Funcode struct {
 	NumKwonlyParams       int
 	HasVarargs, HasKwargs bool
 
+	// Annotated types, checked at run time if Options.CheckTypes.
+	// ParamTypes holds the type of each parameter, in the order of
+	// Locals, or nil if no parameter is annotated. For *args and
+	// **kwargs, it holds the type of each element, or value.
+	ParamTypes []types.Type // (elements may be nil)
+	ResultType types.Type   // may be nil
+
+	// Handlers is the exception handler table, used only if
+	// Options.Exceptions. It is sorted by PC0, and the ranges
+	// of its entries are disjoint.
+	Handlers []Handler
+
+	// StmtLines holds the first and last lines of each statement
+	// of the toplevel function of a program compiled from source,
+	// in order, for use by tracers.
+	StmtLines [][2]int32
+
 	// -- transient state --
 
 	lntOnce sync.Once
 	lnt     []pclinecol // decoded line number table
 }
 
+// A Handler is an entry in the exception handler table of a Funcode.
+// If an error occurs during the instruction at pc, and PC0 <= pc < PC1,
+// the interpreter truncates the operand stack to Depth values and the
+// iterator stack to IterDepth iterators, pushes the error value,
+// and continues execution at Target.
+type Handler struct {
+	PC0, PC1         uint32 // range of protected instructions
+	Target           uint32 // address of handler code
+	Depth, IterDepth uint32
+}
+
+// Handler returns the handler for an error that occurs during the
+// instruction at pc, or nil if the error is not handled.
+func (fn *Funcode) Handler(pc uint32) *Handler {
+	for i := range fn.Handlers {
+		h := &fn.Handlers[i]
+		if h.PC0 <= pc && pc < h.PC1 {
+			return h
+		}
+	}
+	return nil
+}
+
 type pclinecol struct {
 	pc        uint32
 	line, col int32
@@ -371,26 +424,47 @@ type pcomp struct {
 type fcomp struct {
 	fn *Funcode // what we're building
 
-	pcomp *pcomp
-	pos   syntax.Position // current position of generated code
-	loops []loop
-	block *block
+	pcomp    *pcomp
+	pos      syntax.Position // current position of generated code
+	loops    []loop
+	block    *block
+	try      *tryContext // innermost enclosing try statement, or nil
+	iters    int         // number of iterators pushed by enclosing for loops
+	handlers []*handler  // all exception handlers of this function
 }
 
 type loop struct {
 	break_, continue_ *block
+	try               *tryContext // try context of loop statement
+	depth             int         // operand stack depth of loop statement
+}
+
+// A tryContext records the state of the code within a try statement.
+type tryContext struct {
+	handler *handler      // handler of errors in this context, or nil
+	finally []syntax.Stmt // finally clause to execute on leaving this context early
+	depth   int           // number of values on the operand stack at the start of each statement
+	outer   *tryContext
+}
+
+// A handler is an exception handler.
+// Its code starts with the error value atop depth other values.
+type handler struct {
+	target           *block
+	depth, iterDepth int
 }
 
 type block struct {
 	insns []insn
 
-	// If the last insn is a RETURN, jmp and cjmp are nil.
+	// If the last insn is a RETURN or RERAISE, jmp and cjmp are nil.
 	// If the last insn is a CJMP or ITERJMP,
 	//  cjmp and jmp are the "true" and "false" successors.
 	// Otherwise, jmp is the sole successor.
 	jmp, cjmp *block
 
-	initialstack int // for stack depth computation
+	initialstack int      // for stack depth computation
+	handler      *handler // handler of errors in this block, or nil
 
 	// Used during encoding
 	index int // -1 => not encoded yet
@@ -490,7 +564,9 @@ func bindings(bindings []*resolve.Binding) []Binding {
 func Expr(opts *syntax.FileOptions, expr syntax.Expr, name string, locals []*resolve.Binding) *Program {
 	pos := syntax.Start(expr)
 	stmts := []syntax.Stmt{&syntax.ReturnStmt{Result: expr}}
-	return File(opts, stmts, pos, name, locals, nil)
+	prog := File(opts, stmts, pos, name, locals, nil)
+	prog.Toplevel.StmtLines = nil // an expression has no statements
+	return prog
 }
 
 // File compiles the statements of a file into a program.
@@ -500,12 +576,17 @@ func File(opts *syntax.FileOptions, stmts []syntax.Stmt, pos syntax.Position, na
 		prog: &Program{
 			Globals:   bindings(globals),
 			Recursion: opts.Recursion,
+			Options:   *opts,
 		},
 		names:     make(map[string]uint32),
 		constants: make(map[any]uint32),
 		functions: make(map[*Funcode]uint32),
 	}
 	pcomp.prog.Toplevel = pcomp.function(name, pos, stmts, locals, nil)
+	for _, stmt := range stmts {
+		start, end := stmt.Span()
+		pcomp.prog.Toplevel.StmtLines = append(pcomp.prog.Toplevel.StmtLines, [2]int32{start.Line, end.Line})
+	}
 
 	return pcomp.prog
 }
@@ -657,6 +738,10 @@ func (pcomp *pcomp) function(name string, pos syntax.Position, stmts []syntax.St
 	}
 	setinitialstack(entry, 0)
 	visit(entry)
+	for _, h := range fcomp.handlers {
+		setinitialstack(h.target, h.depth+1)
+		visit(h.target)
+	}
 
 	fn := fcomp.fn
 	fn.MaxStack = maxstack
@@ -720,7 +805,7 @@ func (insn *insn) stackeffect() int {
 			//  0 for cjmp/true/exhausted
 			// Handled specially in caller.
 			se = 0
-		case MAKELIST, MAKETUPLE:
+		case MAKELIST, MAKETUPLE, CONCAT:
 			se = 1 - arg
 		case UNPACK:
 			se = arg - 1
@@ -819,6 +904,32 @@ func (fcomp *fcomp) generate(blocks []*block, codelen uint32) {
 
 	fcomp.fn.pclinetab = pclinetab
 	fcomp.fn.Code = code
+
+	// Build the exception handler table from the
+	// handlers of the blocks, merging adjacent ranges.
+	for i, b := range blocks {
+		if b.handler == nil {
+			continue
+		}
+		end := codelen
+		if i+1 < len(blocks) {
+			end = blocks[i+1].addr
+		}
+		h := Handler{
+			PC0:       b.addr,
+			PC1:       end,
+			Target:    b.handler.target.addr,
+			Depth:     uint32(b.handler.depth),
+			IterDepth: uint32(b.handler.iterDepth),
+		}
+		if n := len(fcomp.fn.Handlers); n > 0 {
+			if last := &fcomp.fn.Handlers[n-1]; last.PC1 == h.PC0 && last.Target == h.Target {
+				last.PC1 = h.PC1
+				continue
+			}
+		}
+		fcomp.fn.Handlers = append(fcomp.fn.Handlers, h)
+	}
 }
 
 // clip returns the value nearest x in the range [min...max],
@@ -869,7 +980,7 @@ func PrintOp(fn *Funcode, pc uint32, op Opcode, arg uint32) {
 
 	var comment string
 	switch op {
-	case CONSTANT:
+	case CONSTANT, FORMAT:
 		switch x := fn.Prog.Constants[arg].(type) {
 		case string:
 			comment = strconv.Quote(x)
@@ -891,7 +1002,7 @@ func PrintOp(fn *Funcode, pc uint32, op Opcode, arg uint32) {
 	case CALL, CALL_VAR, CALL_KW, CALL_VAR_KW:
 		comment = fmt.Sprintf("%d pos, %d named", arg>>8, arg&0xff)
 	default:
-		// JMP, CJMP, ITERJMP, MAKETUPLE, MAKELIST, LOAD, UNPACK:
+		// JMP, CJMP, ITERJMP, MAKETUPLE, MAKELIST, LOAD, UNPACK, CONCAT:
 		// arg is just a number
 	}
 	var buf bytes.Buffer
@@ -903,9 +1014,35 @@ func PrintOp(fn *Funcode, pc uint32, op Opcode, arg uint32) {
 	os.Stderr.Write(buf.Bytes())
 }
 
-// newBlock returns a new block.
-func (fcomp) newBlock() *block {
-	return &block{index: -1, initialstack: -1}
+// newBlock returns a new block, whose errors are handled
+// by the handler of the current try context, if any.
+func (fcomp *fcomp) newBlock() *block {
+	b := &block{index: -1, initialstack: -1}
+	if fcomp.try != nil {
+		b.handler = fcomp.try.handler
+	}
+	return b
+}
+
+// newHandler returns a new exception handler for
+// a try statement in the current try context.
+func (fcomp *fcomp) newHandler() *handler {
+	h := &handler{
+		target:    fcomp.newBlock(),
+		depth:     fcomp.depth(),
+		iterDepth: fcomp.iters,
+	}
+	fcomp.handlers = append(fcomp.handlers, h)
+	return h
+}
+
+// depth returns the number of values on the operand
+// stack at the start of a statement in the current context.
+func (fcomp *fcomp) depth() int {
+	if fcomp.try == nil {
+		return 0
+	}
+	return fcomp.try.depth
 }
 
 // emit emits an instruction to the current block.
@@ -1062,13 +1199,17 @@ func (fcomp *fcomp) stmt(stmt syntax.Stmt) {
 		switch stmt.Token {
 		case syntax.PASS:
 			// no-op
-		case syntax.BREAK:
-			b := fcomp.loops[len(fcomp.loops)-1].break_
-			fcomp.jump(b)
-			fcomp.block = fcomp.newBlock() // dead code
-		case syntax.CONTINUE:
-			b := fcomp.loops[len(fcomp.loops)-1].continue_
-			fcomp.jump(b)
+		case syntax.BREAK, syntax.CONTINUE:
+			loop := fcomp.loops[len(fcomp.loops)-1]
+			fcomp.unwind(loop.try, 0)
+			for range fcomp.depth() - loop.depth {
+				fcomp.emit(POP) // discard caught errors
+			}
+			if stmt.Token == syntax.BREAK {
+				fcomp.jump(loop.break_)
+			} else {
+				fcomp.jump(loop.continue_)
+			}
 			fcomp.block = fcomp.newBlock() // dead code
 		}
 
@@ -1186,8 +1327,10 @@ func (fcomp *fcomp) stmt(stmt syntax.Stmt) {
 
 		fcomp.block = body
 		fcomp.assign(stmt.For, stmt.Vars)
-		fcomp.loops = append(fcomp.loops, loop{break_: tail, continue_: head})
+		fcomp.loops = append(fcomp.loops, loop{break_: tail, continue_: head, try: fcomp.try, depth: fcomp.depth()})
+		fcomp.iters++
 		fcomp.stmts(stmt.Body)
+		fcomp.iters--
 		fcomp.loops = fcomp.loops[:len(fcomp.loops)-1]
 		fcomp.jump(head)
 
@@ -1204,7 +1347,7 @@ func (fcomp *fcomp) stmt(stmt syntax.Stmt) {
 		fcomp.ifelse(stmt.Cond, body, done)
 
 		fcomp.block = body
-		fcomp.loops = append(fcomp.loops, loop{break_: done, continue_: head})
+		fcomp.loops = append(fcomp.loops, loop{break_: done, continue_: head, try: fcomp.try, depth: fcomp.depth()})
 		fcomp.stmts(stmt.Body)
 		fcomp.loops = fcomp.loops[:len(fcomp.loops)-1]
 		fcomp.jump(head)
@@ -1217,9 +1360,13 @@ func (fcomp *fcomp) stmt(stmt syntax.Stmt) {
 		} else {
 			fcomp.emit(NONE)
 		}
+		fcomp.unwind(nil, 1)
 		fcomp.emit(RETURN)
 		fcomp.block = fcomp.newBlock() // dead code
 
+	case *syntax.TryStmt:
+		fcomp.tryStmt(stmt)
+
 	case *syntax.LoadStmt:
 		for i := range stmt.From {
 			fcomp.string(stmt.From[i].Name)
@@ -1242,6 +1389,98 @@ func (fcomp *fcomp) stmt(stmt syntax.Stmt) {
 	}
 }
 
+// tryStmt compiles a try statement.
+//
+// Errors in the try clause are handled by the except clause, if any,
+// which stores the error value in a variable. Errors in both clauses
+// are handled by a copy of the finally clause, if any, which re-raises
+// the error. The finally clause is also compiled inline on each path
+// that leaves the statement: the normal path, and those of return,
+// break, and continue statements (see unwind).
+func (fcomp *fcomp) tryStmt(stmt *syntax.TryStmt) {
+	outer := fcomp.try
+	depth := fcomp.depth()
+	done := fcomp.newBlock()
+	fin := fcomp.newBlock()
+
+	// The context of the try and except clauses.
+	ctx := outer
+	var finally *handler
+	if stmt.Finally != nil {
+		finally = fcomp.newHandler()
+		ctx = &tryContext{handler: finally, finally: stmt.Finally, depth: depth, outer: outer}
+	}
+
+	// try clause
+	body := ctx
+	var except *handler
+	if stmt.Except != nil {
+		fcomp.try = ctx
+		except = fcomp.newHandler()
+		body = &tryContext{handler: except, depth: depth, outer: ctx}
+	}
+	fcomp.try = body
+	b := fcomp.newBlock()
+	fcomp.jump(b)
+	fcomp.block = b
+	fcomp.stmts(stmt.Body)
+	fcomp.jump(fin)
+
+	// except clause
+	if except != nil {
+		fcomp.try = ctx
+		fcomp.block = except.target
+		if stmt.Name != nil {
+			fcomp.set(stmt.Name)
+		} else {
+			fcomp.emit(POP)
+		}
+		fcomp.stmts(stmt.Except)
+		fcomp.jump(fin)
+	}
+
+	// finally clause, after normal completion
+	fcomp.try = outer
+	fcomp.block = fin
+	fcomp.stmts(stmt.Finally)
+	fcomp.jump(done)
+
+	// finally clause, after an error, which remains on the stack
+	if finally != nil {
+		fcomp.try = &tryContext{handler: finally.target.handler, depth: depth + 1, outer: outer}
+		fcomp.block = finally.target
+		fcomp.stmts(stmt.Finally)
+		fcomp.emit(RERAISE)
+	}
+
+	fcomp.try = outer
+	fcomp.block = done
+}
+
+// unwind compiles inline the finally clauses of the try statements
+// enclosing the current point, innermost first, up to but excluding
+// the context until, as control leaves them by a return, break, or
+// continue statement. extra is the number of values, such as a return
+// value, on the operand stack above the depth of the current context.
+func (fcomp *fcomp) unwind(until *tryContext, extra int) {
+	saved := fcomp.try
+	depth := fcomp.depth() + extra
+	for ctx := saved; ctx != until; ctx = ctx.outer {
+		if ctx.finally != nil {
+			var h *handler
+			if ctx.outer != nil {
+				h = ctx.outer.handler
+			}
+			fcomp.try = &tryContext{handler: h, depth: depth, outer: ctx.outer}
+			b := fcomp.newBlock()
+			fcomp.jump(b)
+			fcomp.block = b
+			fcomp.stmts(ctx.finally)
+		}
+	}
+	fcomp.try = saved
+}
+
 // assign implements lhs = rhs for arbitrary expressions lhs.
 // RHS is on top of stack, consumed.
 func (fcomp *fcomp) assign(pos syntax.Position, lhs syntax.Expr) {
@@ -1307,6 +1546,39 @@ func (fcomp *fcomp) expr(e syntax.Expr) {
 		}
 		fcomp.emit1(CONSTANT, fcomp.pcomp.constantIndex(v))
 
+	case *syntax.FStringExpr:
+		// Push each non-empty literal part, and the formatted
+		// value of each field, then concatenate them.
+		n := 0
+		for i, lit := range e.Literals {
+			if lit != "" {
+				fcomp.emit1(CONSTANT, fcomp.pcomp.constantIndex(lit))
+				n++
+			}
+			if i < len(e.Fields) {
+				field := e.Fields[i]
+				fcomp.expr(field.X)
+				var directive string
+				if field.Conv != "" {
+					directive = "!" + field.Conv
+				}
+				if field.Spec != "" {
+					directive += ":" + field.Spec
+				}
+				fcomp.setPos(field.Lbrace)
+				fcomp.emit1(FORMAT, fcomp.pcomp.constantIndex(directive))
+				n++
+			}
+		}
+		switch n {
+		case 0:
+			fcomp.emit1(CONSTANT, fcomp.pcomp.constantIndex(""))
+		case 1:
+			// A lone literal or field is already a string.
+		default:
+			fcomp.emit1(CONCAT, uint32(n))
+		}
+
 	case *syntax.ListExpr:
 		for _, x := range e.List {
 			fcomp.expr(x)
@@ -1868,9 +2140,53 @@ func (fcomp *fcomp) function(f *resolve.Function) {
 	funcode.NumKwonlyParams = f.NumKwonlyParams
 	funcode.HasVarargs = f.HasVarargs
 	funcode.HasKwargs = f.HasKwargs
+	if fcomp.pcomp.prog.Options.CheckTypes {
+		funcode.ParamTypes, funcode.ResultType = annotations(f)
+	}
 	fcomp.emit1(MAKEFUNC, fcomp.pcomp.functionIndex(funcode))
 }
 
+// annotations returns the types of the annotated parameters of f,
+// in the order of its locals (see Funcode.ParamTypes), and of its result.
+// The annotations must be valid (see types.CheckAnnotations).
+func annotations(f *resolve.Function) (params []types.Type, result types.Type) {
+	parse := func(e syntax.Expr) types.Type {
+		if e == nil {
+			return nil
+		}
+		t, err := types.ParseType(e)
+		if err != nil {
+			log.Panicf("%s", err)
+		}
+		return t
+	}
+
+	if f.ParamTypes != nil {
+		// *args and **kwargs follow the other parameters.
+		var varargs, kwargs types.Type
+		for i, param := range f.Params {
+			t := parse(f.ParamTypes[i])
+			switch param := param.(type) {
+			case *syntax.UnaryExpr:
+				if param.Op == syntax.STARSTAR {
+					kwargs = t
+				} else if param.X != nil {
+					varargs = t
+				}
+			default:
+				params = append(params, t)
+			}
+		}
+		if f.HasVarargs {
+			params = append(params, varargs)
+		}
+		if f.HasKwargs {
+			params = append(params, kwargs)
+		}
+	}
+	return params, parse(f.ResultType)
+}
+
 // ifelse emits a Boolean control flow decision.
 // On return, the current block is unset.
 func (fcomp *fcomp) ifelse(cond syntax.Expr, t, f *block) {
@@ -2,10 +2,13 @@ package compile_test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"reflect"
 	"strings"
 	"testing"
 
 	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
 )
 
 // TestSerialization verifies that a serialized program can be loaded,
@@ -62,6 +65,95 @@ Error: unknown binary op: string * NoneType`
 	}
 }
 
+// TestSerializationCheckTypes verifies that the run-time type checks
+// of a program survive serialization.
+func TestSerializationCheckTypes(t *testing.T) {
+	const src = `
+def f(x: int | None, *args: list[str], **kwargs: struct(a=int)) -> dict[str, float]:
+    return x
+`
+	opts := &syntax.FileOptions{TypeAnnotations: true, CheckTypes: true}
+	_, oldProg, err := starlark.SourceProgramOptions(opts, "f.star", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := oldProg.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	newProg, err := starlark.CompiledProgram(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	globals, err := newProg.Init(new(starlark.Thread), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := globals["f"]
+	for _, test := range []struct {
+		args starlark.Tuple
+		want string
+	}{
+		{starlark.Tuple{starlark.String("one")}, "f: for parameter x: got string, want int | NoneType"},
+		{starlark.Tuple{starlark.None, starlark.None}, "f: for parameter *args: got NoneType, want list[string]"},
+		{starlark.Tuple{starlark.MakeInt(1)}, "f: for return value: got int, want dict[string, float]"},
+	} {
+		_, err := starlark.Call(new(starlark.Thread), f, test.args, nil)
+		if err == nil || err.(*starlark.EvalError).Msg != test.want {
+			t.Errorf("f%v: got error %v, want %q", test.args, err, test.want)
+		}
+	}
+}
+
+func TestSerializationHandlers(t *testing.T) {
+	const src = `
+def f(x):
+    for i in [0, 1]:
+        try:
+            x = 1 // x
+        except e:
+            return e.message
+        finally:
+            x += 1
+    return x
+`
+	opts := &syntax.FileOptions{Exceptions: true}
+	_, oldProg, err := starlark.SourceProgramOptions(opts, "f.star", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := oldProg.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	newProg, err := starlark.CompiledProgram(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	globals, err := newProg.Init(new(starlark.Thread), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := globals["f"]
+	for _, test := range []struct {
+		x    int
+		want string
+	}{
+		{0, `"floored division by zero"`},
+		{1, "1"},
+		{-1, `"floored division by zero"`}, // fails on the second iteration
+	} {
+		got, err := starlark.Call(new(starlark.Thread), f, starlark.Tuple{starlark.MakeInt(test.x)}, nil)
+		if err != nil {
+			t.Errorf("f(%d): %v", test.x, err)
+		} else if got.String() != test.want {
+			t.Errorf("f(%d) = %s, want %s", test.x, got, test.want)
+		}
+	}
+}
+
 func TestGarbage(t *testing.T) {
 	const garbage = "This is not a compiled Starlark program."
 	_, err := starlark.CompiledProgram(strings.NewReader(garbage))
@@ -72,3 +164,88 @@ func TestGarbage(t *testing.T) {
 		t.Fatalf("CompiledProgram reported the wrong error when decoding garbage: %v", err)
 	}
 }
+
+// TestProgramHeader verifies that the header of a serialized program
+// can be read without decoding the program.
+func TestProgramHeader(t *testing.T) {
+	const src = `
+load("a.star", "a")
+load("b.star", "b")
+
+x = a + b
+`
+	opts := &syntax.FileOptions{While: true, Recursion: true}
+	_, prog, err := starlark.SourceProgramOptions(opts, "x.star", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := prog.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	// ReadProgramHeader must not consume the program body.
+	r := bytes.NewReader(data)
+	hdr, err := starlark.ReadProgramHeader(r)
+	if err != nil {
+		t.Fatalf("ReadProgramHeader: %v", err)
+	}
+	if r.Len() == 0 {
+		t.Errorf("ReadProgramHeader consumed the entire program")
+	}
+
+	digest := sha256.Sum256([]byte(src))
+	want := &starlark.ProgramHeader{
+		CompilerVersion: starlark.CompilerVersion,
+		Options:         *opts,
+		SourceDigest:    digest[:],
+		Filename:        "x.star",
+		Loads:           []string{"a.star", "b.star"},
+	}
+	if !reflect.DeepEqual(hdr, want) {
+		t.Errorf("ReadProgramHeader = %+v, want %+v", hdr, want)
+	}
+	if !hdr.Compatible() {
+		t.Errorf("Compatible() = false for current compiler version")
+	}
+	if got := prog.SourceDigest(); !bytes.Equal(got, digest[:]) {
+		t.Errorf("SourceDigest() = %x, want %x", got, digest)
+	}
+
+	// The options survive a round trip through the decoder.
+	newProg, err := starlark.CompiledProgram(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("CompiledProgram: %v", err)
+	}
+	if !bytes.Equal(newProg.SourceDigest(), digest[:]) {
+		t.Errorf("decoded SourceDigest() = %x, want %x", newProg.SourceDigest(), digest)
+	}
+}
+
+// TestVersionMismatch verifies that the header of a program produced
+// by another compiler version can still be read.
+func TestVersionMismatch(t *testing.T) {
+	_, prog, err := starlark.SourceProgram("x.star", "x = 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := prog.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	data[8] = byte(2 * (starlark.CompilerVersion + 1)) // zig-zag varint of a future version
+
+	hdr, err := starlark.ReadProgramHeader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadProgramHeader: %v", err)
+	}
+	if hdr.Compatible() || hdr.Filename != "x.star" {
+		t.Errorf("ReadProgramHeader = %+v, want incompatible header for x.star", hdr)
+	}
+	if _, err := starlark.CompiledProgram(bytes.NewReader(data)); err == nil ||
+		!strings.Contains(err.Error(), "version mismatch") {
+		t.Errorf("CompiledProgram returned error %v, want version mismatch", err)
+	}
+}
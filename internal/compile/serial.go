@@ -2,19 +2,27 @@ package compile
 
 // This file defines functions to read and write a compile.Program to a file.
 //
-// It is the client's responsibility to avoid version skew between the
-// compiler used to produce a file and the interpreter that consumes it.
-// The version number is provided as a constant.
-// Incompatible protocol changes should also increment the version number.
+// A file begins with a header that describes the program: the
+// compiler version, the FileOptions used to compile it, a digest of its
+// source, and the names of the modules it loads. The header can be read
+// by ReadHeader without decoding (or even reading) the rest of the file,
+// allowing a cache to validate or evict entries cheaply.
+//
+// The layout of the fixed part of the header (the magic number, the
+// header length, and the version) never changes, so the header of a
+// file produced by any compiler version can be read by any other.
+// Later versions may append fields to the header; decoders skip
+// fields they do not recognize. Incompatible changes to the body
+// must increment the version number, and DecodeProgram rejects
+// bodies whose version differs from Version.
 //
 // Encoding
 //
 // Program:
-//	"sky!"		[4]byte		# magic number
+//	"!sky"		[4]byte		# magic number
+//	hdrlen		uint32le	# length of <header> section
+//	<header>	Header
 //	str		uint32le	# offset of <strings> section
-//	version		varint		# must match Version
-//	filename	string
-//	numloads	varint
 //	loads		[]Ident
 //	numnames	varint
 //	names		[]string
@@ -29,6 +37,15 @@ package compile
 //	<strings>	[]byte		# concatenation of all referenced strings
 //	EOF
 //
+// Header:
+//	version		varint		# compiler version (see Version)
+//	options		uvarint		# bit set of FileOptions (see optionBits)
+//	digest		inline string	# digest of source, or empty
+//	filename	inline string
+//	numloads	varint
+//	loads		[]inline string	# module names of load statements
+//	...				# fields added by later versions
+//
 // Funcode:
 //	id		Ident
 //	code		[]byte
@@ -45,6 +62,18 @@ package compile
 //	numkwonlyparams	varint
 //	hasvarargs	varint (0 or 1)
 //	haskwargs	varint (0 or 1)
+//	numparamtypes	varint
+//	paramtypes	[]string	# type annotations, or empty
+//	resulttype	string		# type annotation, or empty
+//	numhandlers	varint
+//	handlers	[]Handler
+//	numstmtlines	varint
+//	stmtlines	[]varint	# first and last line of each statement (toplevel only)
+//
+// Handler:
+//	pc0, pc1	varint
+//	target		varint
+//	depth, iterdepth varint
 //
 // Ident:
 //	filename	string
@@ -57,12 +86,14 @@ package compile
 //                                      # 3=float   varint (bits as uint64)
 //                                      # 4=bigint  string (decimal ASCII text)
 //
-// The encoding starts with a four-byte magic number.
+// The encoding starts with a four-byte magic number,
+// followed by the length-prefixed header, whose strings
+// are stored inline so that it is self-contained.
 // The next four bytes are a little-endian uint32
 // that provides the offset of the string section
 // at the end of the file, which contains the ordered
 // concatenation of all strings referenced by the
-// program. This design permits the decoder to read
+// program body. This design permits the decoder to read
 // the first and second parts of the file into different
 // memory allocations: the first (the encoded program)
 // is transient, but the second (the strings) persists
@@ -78,29 +109,85 @@ package compile
 // are represented as strings. They all (unsafely) share the
 // same backing byte slice.
 //
-// Aside from the str field, all integers are encoded as varints.
+// Aside from the hdrlen and str fields, all integers are encoded as varints.
 
 import (
 	"encoding/binary"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	debugpkg "runtime/debug"
 	"slices"
 	"unsafe"
 
+	"go.starlark.net/internal/types"
 	"go.starlark.net/syntax"
 )
 
 const magic = "!sky"
 
+// headerVersion is the first value of Version whose encoding
+// includes a Header. Files produced by earlier versions record
+// only their version number in the corresponding position.
+const headerVersion = 15
+
+// maxHeaderLen bounds the size of a header read by ReadHeader,
+// to avoid large allocations when reading garbage.
+const maxHeaderLen = 1 << 24
+
+// A Header describes a compiled program without decoding its code.
+type Header struct {
+	Version      int                // compiler version that produced the file
+	Options      syntax.FileOptions // options used to compile the program
+	SourceDigest []byte             // digest of the source file, if known
+	Filename     string             // name of the source file
+	Loads        []string           // module names of load statements, unresolved
+}
+
+// Header returns the header of the compiled program.
+func (prog *Program) Header() *Header {
+	loads := make([]string, len(prog.Loads))
+	for i, load := range prog.Loads {
+		loads[i] = load.Name
+	}
+	return &Header{
+		Version:      Version,
+		Options:      prog.Options,
+		SourceDigest: prog.SourceDigest,
+		Filename:     prog.Toplevel.Pos.Filename(),
+		Loads:        loads,
+	}
+}
+
+// optionFields returns pointers to the boolean fields of opts, in the
+// order of their bits in the encoded header. Fields may be appended to
+// this list but never removed or reordered.
+func optionFields(opts *syntax.FileOptions) []*bool {
+	return []*bool{
+		&opts.Set,
+		&opts.While,
+		&opts.TopLevelControl,
+		&opts.GlobalReassign,
+		&opts.LoadBindsGlobally,
+		&opts.Recursion,
+		&opts.TypeAnnotations,
+		&opts.CheckTypes,
+		&opts.FStrings,
+		&opts.Exceptions,
+	}
+}
+
 // Encode encodes a compiled Starlark program.
 func (prog *Program) Encode() []byte {
 	var e encoder
 	e.p = append(e.p, magic...)
+	e.p = append(e.p, "????"...) // header length; filled in later
+	e.header(prog.Header())
+	binary.LittleEndian.PutUint32(e.p[4:8], uint32(len(e.p)-8))
+
+	str := len(e.p)
 	e.p = append(e.p, "????"...) // string data offset; filled in later
-	e.int(Version)
-	e.string(prog.Toplevel.Pos.Filename())
 	e.bindings(prog.Loads)
 	e.int(len(prog.Names))
 	for _, name := range prog.Names {
@@ -135,7 +222,7 @@ func (prog *Program) Encode() []byte {
 	e.int(b2i(prog.Recursion))
 
 	// Patch in the offset of the string data section.
-	binary.LittleEndian.PutUint32(e.p[4:8], uint32(len(e.p)))
+	binary.LittleEndian.PutUint32(e.p[str:str+4], uint32(len(e.p)))
 
 	return append(e.p, e.s...)
 }
@@ -170,6 +257,30 @@ func (e *encoder) bytes(b []byte) {
 	e.s = append(e.s, b...)
 }
 
+// inline encodes a string within the program section.
+// It is used by the header, which must be self-contained.
+func (e *encoder) inline(s string) {
+	e.int(len(s))
+	e.p = append(e.p, s...)
+}
+
+func (e *encoder) header(hdr *Header) {
+	e.int(hdr.Version)
+	var bits uint64
+	for i, field := range optionFields(&hdr.Options) {
+		if *field {
+			bits |= 1 << i
+		}
+	}
+	e.uint64(bits)
+	e.inline(string(hdr.SourceDigest))
+	e.inline(hdr.Filename)
+	e.int(len(hdr.Loads))
+	for _, load := range hdr.Loads {
+		e.inline(load)
+	}
+}
+
 func (e *encoder) binding(bind Binding) {
 	e.string(bind.Name)
 	e.int(int(bind.Pos.Line))
@@ -202,6 +313,67 @@ func (e *encoder) function(fn *Funcode) {
 	e.int(fn.NumKwonlyParams)
 	e.int(b2i(fn.HasVarargs))
 	e.int(b2i(fn.HasKwargs))
+	e.int(len(fn.ParamTypes))
+	for _, t := range fn.ParamTypes {
+		e.typ(t)
+	}
+	e.typ(fn.ResultType)
+	e.int(len(fn.Handlers))
+	for _, h := range fn.Handlers {
+		e.int(int(h.PC0))
+		e.int(int(h.PC1))
+		e.int(int(h.Target))
+		e.int(int(h.Depth))
+		e.int(int(h.IterDepth))
+	}
+	e.int(len(fn.StmtLines))
+	for _, lines := range fn.StmtLines {
+		e.int(int(lines[0]))
+		e.int(int(lines[1]))
+	}
+}
+
+// Tags of encoded types.
+const (
+	typeNil = iota
+	typeNamed
+	typeList
+	typeDict
+	typeUnion
+	typeRecord
+)
+
+// typ encodes a type denoted by an annotation, or nil.
+func (e *encoder) typ(t types.Type) {
+	switch t := t.(type) {
+	case nil:
+		e.int(typeNil)
+	case types.Named:
+		e.int(typeNamed)
+		e.string(string(t))
+	case *types.List:
+		e.int(typeList)
+		e.typ(t.Elem)
+	case *types.Dict:
+		e.int(typeDict)
+		e.typ(t.Key)
+		e.typ(t.Value)
+	case *types.Union:
+		e.int(typeUnion)
+		e.int(len(t.Types))
+		for _, t := range t.Types {
+			e.typ(t)
+		}
+	case *types.Record:
+		e.int(typeRecord)
+		e.int(len(t.Fields))
+		for _, f := range t.Fields {
+			e.string(f.Name)
+			e.typ(f.Type)
+		}
+	default:
+		panic(fmt.Sprintf("unexpected type %T", t)) // not denoted by an annotation
+	}
 }
 
 func b2i(b bool) int {
@@ -212,15 +384,133 @@ func b2i(b bool) int {
 	}
 }
 
-// DecodeProgram decodes a compiled Starlark program from data.
-func DecodeProgram(data []byte) (_ *Program, err error) {
+// ReadHeader reads the header of a compiled Starlark program from r.
+// It reads no further than the end of the header, so the remainder
+// of the program need not be read. The header of a file produced by
+// any version of the compiler may be read, though the header of a
+// file older than version 15 records only its Version.
+func ReadHeader(r io.Reader) (*Header, error) {
+	var fixed [8]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("not a compiled module: no magic number")
+		}
+		return nil, err
+	}
+	if got := string(fixed[:4]); got != magic {
+		return nil, fmt.Errorf("not a compiled module: got magic number %q, want %q",
+			got, magic)
+	}
+	n := binary.LittleEndian.Uint32(fixed[4:8])
+	if n > maxHeaderLen {
+		return nil, fmt.Errorf("invalid compiled module: header too large (%d bytes)", n)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("invalid compiled module: truncated header")
+		}
+		return nil, err
+	}
+	return decodeHeader(data)
+}
+
+// splitHeader returns the header and body sections of an encoded program.
+func splitHeader(data []byte) (header, body []byte, err error) {
 	if len(data) < len(magic) {
-		return nil, fmt.Errorf("not a compiled module: no magic number")
+		return nil, nil, fmt.Errorf("not a compiled module: no magic number")
 	}
 	if got := string(data[:4]); got != magic {
-		return nil, fmt.Errorf("not a compiled module: got magic number %q, want %q",
+		return nil, nil, fmt.Errorf("not a compiled module: got magic number %q, want %q",
 			got, magic)
 	}
+	if len(data) < 8 {
+		return nil, nil, fmt.Errorf("invalid compiled module: truncated header")
+	}
+	n := binary.LittleEndian.Uint32(data[4:8])
+	if uint64(n) > uint64(len(data)-8) {
+		return nil, nil, fmt.Errorf("invalid compiled module: truncated header")
+	}
+	return data[8 : 8+n], data[8+n:], nil
+}
+
+// decodeHeader decodes the header section of an encoded program.
+// Unlike the decoder of the program body, it does not trust its input.
+func decodeHeader(data []byte) (*Header, error) {
+	errTruncated := fmt.Errorf("invalid compiled module: truncated header")
+	varint := func() (int, bool) {
+		x, n := binary.Varint(data)
+		if n <= 0 {
+			return 0, false
+		}
+		data = data[n:]
+		return int(x), true
+	}
+	inline := func() (string, bool) {
+		n, ok := varint()
+		if !ok || n < 0 || n > len(data) {
+			return "", false
+		}
+		s := string(data[:n])
+		data = data[n:]
+		return s, true
+	}
+
+	var hdr Header
+	version, ok := varint()
+	if !ok {
+		return nil, errTruncated
+	}
+	hdr.Version = version
+	if version < headerVersion {
+		return &hdr, nil // legacy file: no further header fields
+	}
+
+	bits, n := binary.Uvarint(data)
+	if n <= 0 {
+		return nil, errTruncated
+	}
+	data = data[n:]
+	for i, field := range optionFields(&hdr.Options) {
+		*field = bits&(1<<i) != 0
+	}
+	digest, ok := inline()
+	if !ok {
+		return nil, errTruncated
+	}
+	if digest != "" {
+		hdr.SourceDigest = []byte(digest)
+	}
+	if hdr.Filename, ok = inline(); !ok {
+		return nil, errTruncated
+	}
+	numloads, ok := varint()
+	if !ok || numloads < 0 || numloads > len(data) {
+		return nil, errTruncated
+	}
+	hdr.Loads = make([]string, numloads)
+	for i := range hdr.Loads {
+		if hdr.Loads[i], ok = inline(); !ok {
+			return nil, errTruncated
+		}
+	}
+	// Any remaining data holds fields added by later versions.
+	return &hdr, nil
+}
+
+// DecodeProgram decodes a compiled Starlark program from data.
+func DecodeProgram(data []byte) (_ *Program, err error) {
+	header, body, err := splitHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	hdr, err := decodeHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Version != Version {
+		return nil, fmt.Errorf("version mismatch: read %d, want %d", hdr.Version, Version)
+	}
 	defer func() {
 		if x := recover(); x != nil {
 			debugpkg.PrintStack()
@@ -228,17 +518,14 @@ func DecodeProgram(data []byte) (_ *Program, err error) {
 		}
 	}()
 
-	offset := binary.LittleEndian.Uint32(data[4:8])
+	base := len(data) - len(body)
+	offset := binary.LittleEndian.Uint32(body[:4])
 	d := decoder{
-		p: data[8:offset],
+		p: data[base+4 : offset],
 		s: slices.Clone(data[offset:]), // allocate a copy, which will persist
 	}
 
-	if v := d.int(); v != Version {
-		return nil, fmt.Errorf("version mismatch: read %d, want %d", v, Version)
-	}
-
-	filename := d.string()
+	filename := hdr.Filename
 	d.filename = &filename
 
 	loads := d.bindings()
@@ -276,13 +563,15 @@ func DecodeProgram(data []byte) (_ *Program, err error) {
 	recursion := d.int() != 0
 
 	prog := &Program{
-		Loads:     loads,
-		Names:     names,
-		Constants: constants,
-		Globals:   globals,
-		Functions: funcs,
-		Toplevel:  toplevel,
-		Recursion: recursion,
+		Loads:        loads,
+		Names:        names,
+		Constants:    constants,
+		Functions:    funcs,
+		Globals:      globals,
+		Toplevel:     toplevel,
+		Recursion:    recursion,
+		Options:      hdr.Options,
+		SourceDigest: hdr.SourceDigest,
 	}
 	toplevel.Prog = prog
 	for _, f := range funcs {
@@ -373,6 +662,34 @@ func (d *decoder) function() *Funcode {
 	numKwonlyParams := d.int()
 	hasVarargs := d.int() != 0
 	hasKwargs := d.int() != 0
+	var paramTypes []types.Type
+	if n := d.int(); n > 0 {
+		paramTypes = make([]types.Type, n)
+		for i := range paramTypes {
+			paramTypes[i] = d.typ()
+		}
+	}
+	resultType := d.typ()
+	var handlers []Handler
+	if n := d.int(); n > 0 {
+		handlers = make([]Handler, n)
+		for i := range handlers {
+			handlers[i] = Handler{
+				PC0:       uint32(d.int()),
+				PC1:       uint32(d.int()),
+				Target:    uint32(d.int()),
+				Depth:     uint32(d.int()),
+				IterDepth: uint32(d.int()),
+			}
+		}
+	}
+	var stmtLines [][2]int32
+	if n := d.int(); n > 0 {
+		stmtLines = make([][2]int32, n)
+		for i := range stmtLines {
+			stmtLines[i] = [2]int32{int32(d.int()), int32(d.int())}
+		}
+	}
 	return &Funcode{
 		// Prog is filled in later.
 		Pos:             id.Pos,
@@ -388,5 +705,37 @@ func (d *decoder) function() *Funcode {
 		NumKwonlyParams: numKwonlyParams,
 		HasVarargs:      hasVarargs,
 		HasKwargs:       hasKwargs,
+		ParamTypes:      paramTypes,
+		ResultType:      resultType,
+		Handlers:        handlers,
+		StmtLines:       stmtLines,
+	}
+}
+
+// typ decodes a type encoded by encoder.typ.
+func (d *decoder) typ() types.Type {
+	switch tag := d.int(); tag {
+	case typeNil:
+		return nil
+	case typeNamed:
+		return types.Named(d.string())
+	case typeList:
+		return &types.List{Elem: d.typ()}
+	case typeDict:
+		return &types.Dict{Key: d.typ(), Value: d.typ()}
+	case typeUnion:
+		u := &types.Union{Types: make([]types.Type, d.int())}
+		for i := range u.Types {
+			u.Types[i] = d.typ()
+		}
+		return u
+	case typeRecord:
+		r := &types.Record{Fields: make([]types.Field, d.int())}
+		for i := range r.Fields {
+			r.Fields[i] = types.Field{Name: d.string(), Type: d.typ()}
+		}
+		return r
+	default:
+		panic(fmt.Sprintf("invalid type tag %d", tag))
 	}
 }
@@ -0,0 +1,303 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package types defines the representation of the types of Starlark
+// values denoted by type annotations, and their parser. It is shared
+// by the static checker (go.starlark.net/typecheck) and the
+// interpreter, which checks annotated types at run time.
+package types // import "go.starlark.net/internal/types"
+
+import (
+	"fmt"
+	"strings"
+
+	"go.starlark.net/syntax"
+)
+
+// A Type is the type of a Starlark value. It is used both by the
+// static checker and by the interpreter's run-time checks.
+//
+// A type annotation such as "list[str] | None" denotes a Type.
+// The type language consists of:
+//
+//	Any                   any value
+//	T                     values whose Value.Type() is T, e.g. int, string, struct
+//	str                   same as string
+//	None                  same as NoneType
+//	list[T]               lists whose elements are of type T
+//	dict[K, V]            dicts whose keys and values are of type K and V
+//	T | U                 values of type T or U
+//	struct(x=T, y=U)      values with fields x and y of type T and U (a record type)
+//
+// Any other name T denotes the values whose Type method returns T,
+// such as those of an application-defined type. Types of the forms
+// list and dict are equivalent to list[Any] and dict[Any, Any].
+// The names in a type annotation are not subject to name resolution.
+//
+// The concrete types are Named, *List, *Dict, *Union, *Record, and *Func.
+// (The type of a function is not expressible in an annotation.)
+type Type interface {
+	// String returns the type in the syntax of annotations,
+	// but with the names used by Value.Type, e.g. "list[string]".
+	String() string
+	isType()
+}
+
+// Any is the type of a value about which nothing is known statically.
+// A value of any type may be used where Any is wanted, and vice versa.
+var Any Type = Named("Any")
+
+// A Named type is the set of values whose Value.Type() is the specified name.
+type Named string
+
+// A List is the type of a list whose elements are of type Elem.
+type List struct{ Elem Type }
+
+// A Dict is the type of a dict whose keys and values are of type Key and Value.
+type Dict struct{ Key, Value Type }
+
+// A Union is the type of a value that is of one of the specified types.
+// The types are distinct, and are not themselves unions.
+type Union struct{ Types []Type }
+
+// A Record is the type of a value with the specified fields,
+// such as a struct, in the order they appear in the annotation.
+type Record struct{ Fields []Field }
+
+// A Field is a named field of a Record.
+type Field struct {
+	Name string
+	Type Type
+}
+
+// A Func is the type of a function, as declared by a def statement
+// or by a signature (see typecheck.ParseSignature).
+type Func struct {
+	Name     string
+	Params   []Param // named parameters, in order
+	Variadic Type    // type of each *args argument, or nil if no *args
+	Kwargs   Type    // type of each **kwargs argument, or nil if no **kwargs
+	Result   Type    // type of the result
+}
+
+// A Param is a named parameter of a Func.
+type Param struct {
+	Name     string
+	Type     Type
+	Optional bool // parameter has a default value
+	KwOnly   bool // parameter follows *args or *, and must be passed by keyword
+}
+
+func (Named) isType()   {}
+func (*List) isType()   {}
+func (*Dict) isType()   {}
+func (*Union) isType()  {}
+func (*Record) isType() {}
+func (*Func) isType()   {}
+
+func (t Named) String() string { return string(t) }
+func (t *List) String() string { return "list[" + t.Elem.String() + "]" }
+func (t *Dict) String() string { return "dict[" + t.Key.String() + ", " + t.Value.String() + "]" }
+func (t *Func) String() string { return "function" }
+
+func (t *Union) String() string {
+	var buf strings.Builder
+	for i, t := range t.Types {
+		if i > 0 {
+			buf.WriteString(" | ")
+		}
+		buf.WriteString(t.String())
+	}
+	return buf.String()
+}
+
+func (t *Record) String() string {
+	var buf strings.Builder
+	buf.WriteString("struct(")
+	for i, f := range t.Fields {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(f.Name)
+		buf.WriteByte('=')
+		buf.WriteString(f.Type.String())
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+// Common named types.
+const (
+	Int      Named = "int"
+	Float    Named = "float"
+	String   Named = "string"
+	Bytes    Named = "bytes"
+	Bool     Named = "bool"
+	NoneType Named = "NoneType"
+	Tuple    Named = "tuple"
+	Function Named = "function"
+)
+
+// aliases maps Python type names to the names used by Value.Type.
+var aliases = map[string]Named{
+	"str":  String,
+	"None": NoneType,
+}
+
+// An Error describes an invalid type annotation, or a type error.
+type Error struct {
+	Pos syntax.Position
+	Msg string
+}
+
+func (e Error) Error() string { return e.Pos.String() + ": " + e.Msg }
+
+// An ErrorList is a non-empty list of errors, in order of position.
+type ErrorList []Error // len > 0
+
+func (e ErrorList) Error() string { return e[0].Error() }
+
+// CheckAnnotations reports an error, as an ErrorList, for each type
+// annotation in f that does not denote a type.
+// It does not require that f has been resolved.
+func CheckAnnotations(f *syntax.File) error {
+	var errors ErrorList
+	check := func(e syntax.Expr) {
+		if e != nil {
+			if _, err := ParseType(e); err != nil {
+				errors = append(errors, err.(Error))
+			}
+		}
+	}
+	syntax.Walk(f, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			for _, t := range n.ParamTypes {
+				check(t)
+			}
+			check(n.ResultType)
+		case *syntax.AssignStmt:
+			check(n.Type)
+		}
+		return true
+	})
+	if errors == nil {
+		return nil
+	}
+	return errors
+}
+
+// ParseType returns the type denoted by a type annotation.
+func ParseType(e syntax.Expr) (Type, error) {
+	switch e := e.(type) {
+	case *syntax.Ident:
+		switch e.Name {
+		case "list":
+			return &List{Any}, nil
+		case "dict":
+			return &Dict{Any, Any}, nil
+		}
+		if t, ok := aliases[e.Name]; ok {
+			return t, nil
+		}
+		return Named(e.Name), nil
+
+	case *syntax.ParenExpr:
+		return ParseType(e.X)
+
+	case *syntax.IndexExpr:
+		if id, ok := e.X.(*syntax.Ident); ok {
+			var args []syntax.Expr
+			if tuple, ok := e.Y.(*syntax.TupleExpr); ok {
+				args = tuple.List
+			} else {
+				args = []syntax.Expr{e.Y}
+			}
+			types := make([]Type, len(args))
+			for i, arg := range args {
+				t, err := ParseType(arg)
+				if err != nil {
+					return nil, err
+				}
+				types[i] = t
+			}
+			switch {
+			case id.Name == "list" && len(types) == 1:
+				return &List{types[0]}, nil
+			case id.Name == "dict" && len(types) == 2:
+				return &Dict{types[0], types[1]}, nil
+			}
+			return nil, Error{id.NamePos, fmt.Sprintf("invalid type %s with %d type arguments", id.Name, len(types))}
+		}
+
+	case *syntax.BinaryExpr:
+		if e.Op == syntax.PIPE {
+			x, err := ParseType(e.X)
+			if err != nil {
+				return nil, err
+			}
+			y, err := ParseType(e.Y)
+			if err != nil {
+				return nil, err
+			}
+			return union(x, y), nil
+		}
+
+	case *syntax.CallExpr:
+		if id, ok := e.Fn.(*syntax.Ident); ok && id.Name == "struct" {
+			rec := new(Record)
+			for _, arg := range e.Args {
+				bin, ok := arg.(*syntax.BinaryExpr)
+				if !ok || bin.Op != syntax.EQ {
+					start, _ := arg.Span()
+					return nil, Error{start, "record type field must have form name=type"}
+				}
+				t, err := ParseType(bin.Y)
+				if err != nil {
+					return nil, err
+				}
+				rec.Fields = append(rec.Fields, Field{bin.X.(*syntax.Ident).Name, t})
+			}
+			return rec, nil
+		}
+	}
+	start, _ := e.Span()
+	return nil, Error{start, "invalid type annotation"}
+}
+
+// union returns the union of types x and y.
+func union(x, y Type) Type {
+	var types []Type
+	for _, t := range [2]Type{x, y} {
+		if u, ok := t.(*Union); ok {
+			for _, t := range u.Types {
+				types = addType(types, t)
+			}
+		} else {
+			types = addType(types, t)
+		}
+	}
+	if len(types) == 1 {
+		return types[0]
+	}
+	return &Union{types}
+}
+
+// addType adds t to types, if it is not already present.
+func addType(types []Type, t Type) []Type {
+	for _, u := range types {
+		if Identical(t, u) {
+			return types
+		}
+	}
+	return append(types, t)
+}
+
+// Identical reports whether x and y are the same type.
+func Identical(x, y Type) bool {
+	if x, ok := x.(*Func); ok {
+		return x == y
+	}
+	return x.String() == y.String()
+}
@@ -364,6 +364,44 @@ func decode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, k
 		panic("unreachable")
 	}
 
+	// parseString returns the next JSON string from the input.
+	// Precondition: s[i] == '"'.
+	parseString := func() string {
+		// Find end of quotation.
+		// Also, record whether trivial unquoting is safe.
+		// Non-trivial unquoting is handled by Go's encoding/json.
+		safe := true
+		closed := false
+		j := i + 1
+		for ; j < len(s); j++ {
+			b := s[j]
+			if b == '\\' {
+				safe = false
+				j++ // skip x in \x
+			} else if b == '"' {
+				closed = true
+				j++ // skip '"'
+				break
+			} else if b >= utf8.RuneSelf {
+				safe = false
+			}
+		}
+		if !closed {
+			fail("unclosed string literal")
+		}
+
+		r := s[i:j]
+		i = j
+
+		// unquote
+		if safe {
+			r = r[1 : len(r)-1]
+		} else if err := json.Unmarshal([]byte(r), &r); err != nil {
+			fail("%s", err)
+		}
+		return r
+	}
+
 	// parse returns the next JSON value from the input.
 	// It consumes leading but not trailing whitespace.
 	// It panics on error.
@@ -372,41 +410,7 @@ func decode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, k
 		b := next()
 		switch b {
 		case '"':
-			// string
-
-			// Find end of quotation.
-			// Also, record whether trivial unquoting is safe.
-			// Non-trivial unquoting is handled by Go's encoding/json.
-			safe := true
-			closed := false
-			j := i + 1
-			for ; j < len(s); j++ {
-				b := s[j]
-				if b == '\\' {
-					safe = false
-					j++ // skip x in \x
-				} else if b == '"' {
-					closed = true
-					j++ // skip '"'
-					break
-				} else if b >= utf8.RuneSelf {
-					safe = false
-				}
-			}
-			if !closed {
-				fail("unclosed string literal")
-			}
-
-			r := s[i:j]
-			i = j
-
-			// unquote
-			if safe {
-				r = r[1 : len(r)-1]
-			} else if err := json.Unmarshal([]byte(r), &r); err != nil {
-				fail("%s", err)
-			}
-			return starlark.String(r)
+			return starlark.String(parseString())
 
 		case 'n':
 			if strings.HasPrefix(s[i:], "null") {
@@ -457,10 +461,11 @@ func decode(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, k
 			b = next()
 			if b != '}' {
 				for {
-					key := parse()
-					if _, ok := key.(starlark.String); !ok {
-						fail("got %s for object key, want string", key.Type())
+					if next() != '"' {
+						fail("got %s for object key, want string", parse().Type())
 					}
+					// Object keys are often repeated; intern them.
+					key := thread.Interner.Intern(parseString())
 					b = next()
 					if b != ':' {
 						fail("after object key, got %q, want ':' ", b)
@@ -0,0 +1,26 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package json
+
+import (
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+func TestDecodeInternsKeys(t *testing.T) {
+	thread := &starlark.Thread{Interner: new(starlark.Interner)}
+	const src = `[{"name": 1, "value": 2}, {"name": 3, "value": 4}]`
+	res, err := starlark.Call(thread, Module.Members["decode"], starlark.Tuple{starlark.String(src)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := res.String(), `[{"name": 1, "value": 2}, {"name": 3, "value": 4}]`; got != want {
+		t.Errorf("decode = %s, want %s", got, want)
+	}
+	if n := thread.Interner.Len(); n != 2 {
+		t.Errorf("Interner.Len = %d, want 2 (name, value)", n)
+	}
+}
@@ -0,0 +1,414 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lint
+
+// This file defines the built-in analyzers.
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// All is the list of built-in analyzers.
+var All = []*Analyzer{
+	DuplicateKey,
+	LoadOrder,
+	MutableDefault,
+	Redefined,
+	ShadowBuiltin,
+	Unreachable,
+	UnusedLoad,
+	UnusedLocal,
+}
+
+var DuplicateKey = &Analyzer{
+	Name: "dupkey",
+	Doc: `report duplicate constant keys in dict literals
+
+A dict literal such as {"a": 1, "a": 2} fails when it is evaluated.`,
+	Run: runDuplicateKey,
+}
+
+var LoadOrder = &Analyzer{
+	Name: "loadorder",
+	Doc: `report load statements that follow other statements
+
+By convention, all load statements appear at the start of a file,
+after its doc string, if any. A try statement whose body consists
+only of load statements is treated as a load statement.`,
+	Run: runLoadOrder,
+}
+
+var MutableDefault = &Analyzer{
+	Name: "mutabledefault",
+	Doc: `report parameters whose default value is mutable
+
+The default value of a parameter is evaluated once, when the def
+statement is executed, and is shared by all calls to the function.
+Once the module that defines the function has been frozen, the value
+is frozen too, so an attempt to update it fails.`,
+	Run: runMutableDefault,
+}
+
+var Redefined = &Analyzer{
+	Name: "redefined",
+	Doc: `report functions that are defined more than once
+
+A second def statement for the same name in the same block replaces
+the first function, which is usually a mistake. (At top level, this
+is possible only if the dialect permits global reassignment.)`,
+	Run: runRedefined,
+}
+
+var ShadowBuiltin = &Analyzer{
+	Name: "shadowbuiltin",
+	Doc: `report variables that shadow built-in names
+
+A global, local, or parameter named after a universal built-in,
+such as len or str, makes the built-in inaccessible within its scope.`,
+	Run: runShadowBuiltin,
+}
+
+var Unreachable = &Analyzer{
+	Name: "unreachable",
+	Doc: `report unreachable statements
+
+A statement that follows a return, break, or continue statement,
+or a call to fail, in the same block is never executed.`,
+	Run: runUnreachable,
+}
+
+var UnusedLoad = &Analyzer{
+	Name: "unusedload",
+	Doc: `report names bound by load statements that are never used
+
+Names whose local name begins with an underscore are exempt, as are
+load statements that bind global names (see
+syntax.FileOptions.LoadBindsGlobally), as they may be used by
+other modules.`,
+	Run: runUnusedLoad,
+}
+
+var UnusedLocal = &Analyzer{
+	Name: "unusedlocal",
+	Doc: `report local variables that are assigned but never used
+
+The check applies to variables assigned by assignment, def, and try
+statements within a function. Names beginning with an underscore are
+exempt, as are parameters and loop variables.`,
+	Run: runUnusedLocal,
+}
+
+func runDuplicateKey(pass *Pass) error {
+	syntax.Walk(pass.File, func(n syntax.Node) bool {
+		dict, ok := n.(*syntax.DictExpr)
+		if !ok {
+			return true
+		}
+		seen := make(map[string]syntax.Position)
+		for _, entry := range dict.List {
+			lit, ok := entry.(*syntax.DictEntry).Key.(*syntax.Literal)
+			if !ok {
+				continue
+			}
+			key := literalKey(lit)
+			if prev, ok := seen[key]; ok {
+				pass.Reportf(lit.TokenPos, "duplicate key %s in dict literal (previous key at %s)", lit.Raw, prev)
+			} else {
+				seen[key] = lit.TokenPos
+			}
+		}
+		return true
+	})
+	return nil
+}
+
+// literalKey returns a string that is equal for two literals
+// if and only if they denote equal dict keys.
+func literalKey(lit *syntax.Literal) string {
+	switch v := lit.Value.(type) {
+	case string:
+		if lit.Token == syntax.BYTES {
+			return "b" + strconv.Quote(v)
+		}
+		return strconv.Quote(v)
+	case float64:
+		// An integral float is equal to the int of the same value.
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			i, _ := big.NewFloat(v).Int(nil)
+			return i.String()
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(lit.Value) // int64 or *big.Int
+}
+
+func runLoadOrder(pass *Pass) error {
+	other := false // whether a statement other than a load has been seen
+	for i, stmt := range pass.File.Stmts {
+		if isLoad(stmt) {
+			if other {
+				start, _ := stmt.Span()
+				pass.Reportf(start, "load statement follows other statements")
+			}
+		} else if i > 0 || !isDocString(stmt) {
+			other = true
+		}
+	}
+	return nil
+}
+
+func isLoad(stmt syntax.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case *syntax.LoadStmt:
+		return true
+	case *syntax.TryStmt:
+		for _, stmt := range stmt.Body {
+			if _, ok := stmt.(*syntax.LoadStmt); !ok {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func isDocString(stmt syntax.Stmt) bool {
+	if expr, ok := stmt.(*syntax.ExprStmt); ok {
+		lit, ok := expr.X.(*syntax.Literal)
+		return ok && lit.Token == syntax.STRING
+	}
+	return false
+}
+
+func runMutableDefault(pass *Pass) error {
+	check := func(params []syntax.Expr) {
+		for _, param := range params {
+			if binary, ok := param.(*syntax.BinaryExpr); ok && isMutable(binary.Y) {
+				start, _ := binary.Y.Span()
+				pass.Reportf(start, "mutable default value for parameter %s", binary.X.(*syntax.Ident).Name)
+			}
+		}
+	}
+	syntax.Walk(pass.File, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			check(n.Params)
+		case *syntax.LambdaExpr:
+			check(n.Params)
+		}
+		return true
+	})
+	return nil
+}
+
+// isMutable reports whether e is a list, dict, or set constructor.
+func isMutable(e syntax.Expr) bool {
+	switch e := e.(type) {
+	case *syntax.ListExpr, *syntax.DictExpr, *syntax.Comprehension:
+		return true
+	case *syntax.CallExpr:
+		if id, ok := e.Fn.(*syntax.Ident); ok && scope(id) == resolve.Universal {
+			return id.Name == "list" || id.Name == "dict" || id.Name == "set"
+		}
+	}
+	return false
+}
+
+func runRedefined(pass *Pass) error {
+	forEachBlock(pass.File, func(stmts []syntax.Stmt) {
+		defs := make(map[*resolve.Binding]*syntax.DefStmt)
+		for _, stmt := range stmts {
+			def, ok := stmt.(*syntax.DefStmt)
+			if !ok {
+				continue
+			}
+			bind := def.Name.Binding.(*resolve.Binding)
+			if prev, ok := defs[bind]; ok {
+				pass.Reportf(def.Name.NamePos, "%s redefined (previous definition at %s)", def.Name.Name, prev.Name.NamePos)
+			} else {
+				defs[bind] = def
+			}
+		}
+	})
+	return nil
+}
+
+func runShadowBuiltin(pass *Pass) error {
+	var visit func(b *resolve.Block)
+	visit = func(b *resolve.Block) {
+		for _, bind := range b.Bindings {
+			if id := bind.First; pass.IsUniversal(id.Name) {
+				pass.Reportf(id.NamePos, "%s shadows the built-in %s", id.Name, id.Name)
+			}
+		}
+		for _, child := range b.Children {
+			visit(child)
+		}
+	}
+	visit(pass.Info.Root)
+	return nil
+}
+
+func runUnreachable(pass *Pass) error {
+	forEachBlock(pass.File, func(stmts []syntax.Stmt) {
+		for i, stmt := range stmts[:max(len(stmts)-1, 0)] {
+			if terminates(stmt) {
+				start, _ := stmts[i+1].Span()
+				pass.Reportf(start, "unreachable code")
+				break
+			}
+		}
+	})
+	return nil
+}
+
+// terminates reports whether control never passes from stmt
+// to the next statement in the same block.
+func terminates(stmt syntax.Stmt) bool {
+	switch stmt := stmt.(type) {
+	case *syntax.ReturnStmt:
+		return true
+	case *syntax.BranchStmt:
+		return stmt.Token == syntax.BREAK || stmt.Token == syntax.CONTINUE
+	case *syntax.ExprStmt:
+		if call, ok := stmt.X.(*syntax.CallExpr); ok {
+			id, ok := call.Fn.(*syntax.Ident)
+			return ok && id.Name == "fail" && scope(id) == resolve.Universal
+		}
+	}
+	return false
+}
+
+func runUnusedLoad(pass *Pass) error {
+	syntax.Walk(pass.File, func(n syntax.Node) bool {
+		load, ok := n.(*syntax.LoadStmt)
+		if !ok {
+			return true
+		}
+		for _, id := range load.To {
+			bind := pass.Info.Binding(id)
+			if bind.First == id && bind.Scope != resolve.Global && pass.Info.Uses(bind) == nil && !strings.HasPrefix(id.Name, "_") {
+				pass.Reportf(id.NamePos, "%s is loaded but never used", id.Name)
+			}
+		}
+		return false
+	})
+	return nil
+}
+
+func runUnusedLocal(pass *Pass) error {
+	kinds := bindingKinds(pass.File)
+	var visit func(b *resolve.Block)
+	visit = func(b *resolve.Block) {
+		if b.Function() != nil {
+			for _, bind := range b.Bindings {
+				id := bind.First
+				if pass.Info.Uses(bind) != nil || strings.HasPrefix(id.Name, "_") {
+					continue
+				}
+				switch kinds[id] {
+				case assigned, caught:
+					pass.Reportf(id.NamePos, "local variable %s is assigned but never used", id.Name)
+				case defined:
+					pass.Reportf(id.NamePos, "local function %s is never used", id.Name)
+				}
+			}
+		}
+		for _, child := range b.Children {
+			visit(child)
+		}
+	}
+	visit(pass.Info.Root)
+	return nil
+}
+
+// -- helpers --
+
+// A bindingKind describes how an identifier binds a variable.
+type bindingKind uint8
+
+const (
+	assigned bindingKind = iota + 1 // x = ...
+	defined                         // def x(): ...
+	caught                          // except x: ...
+)
+
+// bindingKinds returns the kind of each identifier in f
+// that is bound by an assignment, def, or try statement.
+func bindingKinds(f *syntax.File) map[*syntax.Ident]bindingKind {
+	kinds := make(map[*syntax.Ident]bindingKind)
+	var lhs func(e syntax.Expr)
+	lhs = func(e syntax.Expr) {
+		switch e := e.(type) {
+		case *syntax.Ident:
+			kinds[e] = assigned
+		case *syntax.ParenExpr:
+			lhs(e.X)
+		case *syntax.TupleExpr:
+			for _, x := range e.List {
+				lhs(x)
+			}
+		case *syntax.ListExpr:
+			for _, x := range e.List {
+				lhs(x)
+			}
+		}
+	}
+	syntax.Walk(f, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.AssignStmt:
+			if n.Op == syntax.EQ {
+				lhs(n.LHS)
+			}
+		case *syntax.DefStmt:
+			kinds[n.Name] = defined
+		case *syntax.TryStmt:
+			if n.Name != nil {
+				kinds[n.Name] = caught
+			}
+		}
+		return true
+	})
+	return kinds
+}
+
+// scope returns the scope of a resolved identifier.
+func scope(id *syntax.Ident) resolve.Scope {
+	if bind, ok := id.Binding.(*resolve.Binding); ok {
+		return bind.Scope
+	}
+	return resolve.Undefined
+}
+
+// forEachBlock calls fn for each list of statements in the file,
+// including the file's top-level statements.
+func forEachBlock(f *syntax.File, fn func([]syntax.Stmt)) {
+	fn(f.Stmts)
+	syntax.Walk(f, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			fn(n.Body)
+		case *syntax.IfStmt:
+			fn(n.True)
+			fn(n.False)
+		case *syntax.ForStmt:
+			fn(n.Body)
+		case *syntax.WhileStmt:
+			fn(n.Body)
+		case *syntax.TryStmt:
+			fn(n.Body)
+			fn(n.Except)
+			fn(n.Finally)
+		}
+		return true
+	})
+}
@@ -0,0 +1,253 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package lint defines a framework for static checks of Starlark files,
+// and a suite of checks for common mistakes.
+//
+// Each check is an Analyzer, in the manner of the Go analysis framework
+// (golang.org/x/tools/go/analysis), but simpler: an analyzer inspects
+// a single resolved syntax.File and reports Diagnostics through a Pass.
+// The built-in analyzers are listed in All; clients may define their
+// own and apply them in any combination using a Config.
+//
+// A diagnostic may be suppressed by a comment of the form
+//
+//	# lint:ignore name[,name...] [reason]
+//
+// where each name is the name of an analyzer. A comment at the end of
+// a line suppresses diagnostics on that line; a comment on a line of
+// its own suppresses diagnostics on the next line that is not a comment.
+// Comments are retained only if the file was parsed in the
+// syntax.RetainComments mode, as by Config.CheckFile.
+package lint // import "go.starlark.net/lint"
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// An Analyzer describes a static check of a Starlark file.
+type Analyzer struct {
+	// Name is the name of the analyzer, used in diagnostics and
+	// suppression comments. It must be a valid identifier.
+	Name string
+
+	// Doc is the documentation of the analyzer.
+	// Its first sentence is a summary.
+	Doc string
+
+	// Run applies the analyzer to a file. It reports diagnostics
+	// using the Pass, and returns an error only if the analysis
+	// could not be completed.
+	Run func(*Pass) error
+}
+
+func (a *Analyzer) String() string { return a.Name }
+
+// A Pass provides an analyzer with a file to inspect and a means of
+// reporting its findings.
+type Pass struct {
+	Analyzer *Analyzer     // the analyzer being applied
+	File     *syntax.File  // the file, which has been resolved
+	Info     *resolve.Info // the resolver's information about the file
+
+	// IsUniversal reports whether a name is universal
+	// (such as len or None) in the file's environment.
+	IsUniversal func(name string) bool
+
+	report func(Diagnostic)
+}
+
+// Report reports a diagnostic.
+func (pass *Pass) Report(d Diagnostic) {
+	if d.Analyzer == "" {
+		d.Analyzer = pass.Analyzer.Name
+	}
+	pass.report(d)
+}
+
+// Reportf reports a diagnostic with a formatted message.
+func (pass *Pass) Reportf(pos syntax.Position, format string, args ...any) {
+	pass.Report(Diagnostic{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// A Diagnostic is a finding reported by an analyzer.
+type Diagnostic struct {
+	Pos      syntax.Position
+	Analyzer string // name of the analyzer that reported it
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Analyzer)
+}
+
+// A Config specifies the analyzers to apply and the environment of
+// the files to be checked.
+type Config struct {
+	// Analyzers is the list of analyzers to apply.
+	// If nil, the analyzers in All are applied.
+	Analyzers []*Analyzer
+
+	// IsPredeclared and IsUniversal report whether a name is
+	// predeclared in the files' module, or universal, as for
+	// resolve.File. A nil function reports false for all names.
+	IsPredeclared func(name string) bool
+	IsUniversal   func(name string) bool
+}
+
+// CheckFile parses the specified file, retaining comments, resolves
+// it, and applies the analyzers to it. Syntax and resolution errors
+// are returned as the error result.
+//
+// The src argument is interpreted as for syntax.FileOptions.Parse.
+func (cfg *Config) CheckFile(opts *syntax.FileOptions, filename string, src any) ([]Diagnostic, error) {
+	f, err := opts.Parse(filename, src, syntax.RetainComments)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolve.File(f, orFalse(cfg.IsPredeclared), orFalse(cfg.IsUniversal)); err != nil {
+		return nil, err
+	}
+	return cfg.Check(f)
+}
+
+// Check applies the analyzers to the file f, which must have been
+// successfully resolved (see resolve.File), and returns the
+// diagnostics they report, less those suppressed by comments,
+// in order of position.
+func (cfg *Config) Check(f *syntax.File) ([]Diagnostic, error) {
+	analyzers := cfg.Analyzers
+	if analyzers == nil {
+		analyzers = All
+	}
+	info := resolve.NewInfo(f)
+	var diags []Diagnostic
+	for _, a := range analyzers {
+		pass := &Pass{
+			Analyzer:    a,
+			File:        f,
+			Info:        info,
+			IsUniversal: orFalse(cfg.IsUniversal),
+			report:      func(d Diagnostic) { diags = append(diags, d) },
+		}
+		if err := a.Run(pass); err != nil {
+			return nil, fmt.Errorf("%s: analyzer %s failed: %v", f.Path, a.Name, err)
+		}
+	}
+
+	diags = suppress(f, diags)
+	sort.SliceStable(diags, func(i, j int) bool {
+		x, y := diags[i].Pos, diags[j].Pos
+		return x.Line < y.Line || x.Line == y.Line && x.Col < y.Col
+	})
+	return diags, nil
+}
+
+func orFalse(f func(string) bool) func(string) bool {
+	if f == nil {
+		return func(string) bool { return false }
+	}
+	return f
+}
+
+// Lookup returns the analyzer in All with the given name, or nil.
+func Lookup(name string) *Analyzer {
+	for _, a := range All {
+		if a.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+// suppress returns the diagnostics that are not suppressed
+// by a "lint:ignore" comment in the file.
+func suppress(f *syntax.File, diags []Diagnostic) []Diagnostic {
+	if len(diags) == 0 {
+		return diags
+	}
+
+	// Gather the file's comments.
+	type directive struct {
+		line   int32
+		suffix bool
+		names  []string
+	}
+	var directives []directive
+	commentLines := make(map[int32]bool)
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if n == nil {
+			return false
+		}
+		c := n.Comments()
+		if c == nil {
+			return true
+		}
+		add := func(list []syntax.Comment, suffix bool) {
+			for _, com := range list {
+				if !suffix {
+					commentLines[com.Start.Line] = true
+				}
+				if names, ok := parseIgnore(com.Text); ok {
+					directives = append(directives, directive{com.Start.Line, suffix, names})
+				}
+			}
+		}
+		add(c.Before, false)
+		add(c.Suffix, true)
+		add(c.After, false)
+		return true
+	})
+	if directives == nil {
+		return diags
+	}
+
+	// Map each line to the analyzers suppressed on it.
+	ignored := make(map[int32][]string)
+	for _, d := range directives {
+		line := d.line
+		if !d.suffix {
+			for line++; commentLines[line]; line++ {
+			}
+		}
+		ignored[line] = append(ignored[line], d.names...)
+	}
+
+	out := diags[:0]
+	for _, d := range diags {
+		if !contains(ignored[d.Pos.Line], d.Analyzer) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// parseIgnore parses a comment of the form "# lint:ignore a,b reason"
+// and returns the analyzer names.
+func parseIgnore(text string) ([]string, bool) {
+	text = strings.TrimSpace(strings.TrimPrefix(text, "#"))
+	rest, ok := strings.CutPrefix(text, "lint:ignore")
+	if !ok || rest == "" || rest[0] != ' ' && rest[0] != '\t' {
+		return nil, false
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return nil, false
+	}
+	return strings.Split(fields[0], ","), true
+}
+
+func contains(list []string, x string) bool {
+	for _, y := range list {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package lint_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/internal/chunkedfile"
+	"go.starlark.net/lint"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarktest"
+	"go.starlark.net/syntax"
+)
+
+func TestAnalyzers(t *testing.T) {
+	cfg := &lint.Config{IsUniversal: starlark.Universe.Has}
+	opts := &syntax.FileOptions{
+		Set:             true,
+		While:           true,
+		TopLevelControl: true,
+		GlobalReassign:  true,
+		Exceptions:      true,
+	}
+	filename := starlarktest.DataFile("lint", "testdata/lint.star")
+	for _, chunk := range chunkedfile.Read(filename, t) {
+		diags, err := cfg.CheckFile(opts, filename, chunk.Source)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, d := range diags {
+			chunk.GotError(int(d.Pos.Line), d.Message)
+		}
+		chunk.Done()
+	}
+}
+
+func TestConfig(t *testing.T) {
+	const src = `
+load("a.star", "a")
+
+def f():
+    x = 1
+    return
+    print(x)
+
+def g():
+    todo()
+`
+	// A client-defined analyzer.
+	todo := &lint.Analyzer{
+		Name: "todo",
+		Doc:  "report calls to todo",
+		Run: func(pass *lint.Pass) error {
+			syntax.Walk(pass.File, func(n syntax.Node) bool {
+				if call, ok := n.(*syntax.CallExpr); ok {
+					if id, ok := call.Fn.(*syntax.Ident); ok && id.Name == "todo" {
+						pass.Reportf(id.NamePos, "unfinished code")
+					}
+				}
+				return true
+			})
+			return nil
+		},
+	}
+	cfg := &lint.Config{
+		Analyzers:     []*lint.Analyzer{todo, lint.Lookup("unreachable"), lint.UnusedLoad},
+		IsPredeclared: func(name string) bool { return name == "todo" },
+		IsUniversal:   starlark.Universe.Has,
+	}
+	diags, err := cfg.CheckFile(&syntax.FileOptions{}, "x.star", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	want := `x.star:2:17: a is loaded but never used (unusedload)
+x.star:7:5: unreachable code (unreachable)
+x.star:10:5: unfinished code (todo)`
+	if s := strings.Join(got, "\n"); s != want {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", s, want)
+	}
+
+	if lint.Lookup("nonesuch") != nil {
+		t.Errorf("Lookup(nonesuch) succeeded")
+	}
+}
+
+func TestAll(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, a := range lint.All {
+		if seen[a.Name] {
+			t.Errorf("duplicate analyzer name %s", a.Name)
+		}
+		seen[a.Name] = true
+		if a.Doc == "" || a.Run == nil {
+			t.Errorf("analyzer %s lacks Doc or Run", a.Name)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package modresolve defines standard schemes for resolving the
+// module names that appear in Starlark load statements.
+//
+// A [Resolver] maps a module name, as it appears in a load statement,
+// to a canonical key, which identifies the module for the purposes of
+// caching, and opens the module with a given key. This package
+// provides resolvers for several schemes:
+//
+//   - [Relative] interprets names as file paths relative to the
+//     directory of the loading module, such as "lib.star" or "../x/y.star".
+//   - [SearchPath] looks for names in a list of directories.
+//   - [Labels] interprets Bazel-style labels such as
+//     "@repo//pkg:file.star", "//pkg:file.star", and ":file.star".
+//   - [Virtual] provides modules defined in Go, such as a
+//     [starlarkstruct.Module].
+//
+// A [Chain] tries several resolvers in turn, and reports all the
+// alternatives it tried when a name cannot be resolved.
+//
+// The file schemes agree on the keys of files in the main repository:
+// each is a clean slash-separated path relative to its root, so a
+// file loaded both by path and by label is a single module.
+//
+// [NewLoader] returns a [starlark.Loader] that uses a Resolver.
+package modresolve // import "go.starlark.net/modresolve"
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// A Resolver resolves module names and opens modules.
+// Its methods may be called concurrently.
+type Resolver interface {
+	// Resolve returns the canonical key of the module named name
+	// in a load statement of the module whose key is from,
+	// or of a root module if from is "".
+	// If the resolver does not recognize or cannot find the
+	// module, the error satisfies errors.Is(err, ErrNotFound).
+	Resolve(from, name string) (key string, err error)
+
+	// Open returns the module whose key was returned by Resolve.
+	// If the key does not belong to this resolver, the error
+	// satisfies errors.Is(err, ErrNotFound).
+	Open(key string) (*Module, error)
+}
+
+// A Module is the content of a resolved module.
+// Exactly one of its fields is set.
+type Module struct {
+	Source  []byte              // source of a Starlark file
+	Members starlark.StringDict // members of a virtual module
+}
+
+// ErrNotFound is the error (possibly wrapped) returned by a Resolver
+// that does not recognize a module name or key.
+var ErrNotFound = errors.New("module not found")
+
+// A NotFoundError is returned by Chain when no resolver
+// could resolve a module name. It reports all the alternatives tried.
+type NotFoundError struct {
+	From, Name string
+	Tried      []error // the error from each resolver
+}
+
+func (e *NotFoundError) Error() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "cannot resolve %q", e.Name)
+	if e.From != "" {
+		fmt.Fprintf(&buf, " from %s", e.From)
+	}
+	for _, err := range e.Tried {
+		fmt.Fprintf(&buf, "\n\t%v", err)
+	}
+	return buf.String()
+}
+
+func (e *NotFoundError) Unwrap() error { return ErrNotFound }
+
+// notFound returns an error that wraps ErrNotFound.
+func notFound(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrNotFound, fmt.Sprintf(format, args...))
+}
+
+// A Chain is a Resolver that tries each of a list of resolvers in turn.
+// It opens each module using the resolver that resolved its key, or,
+// for a key it has not resolved, such as that of a root module, the
+// first resolver that recognizes the key.
+//
+// If several resolvers resolve names to the same key, the key must
+// denote the same module for each of them, as it does for the file
+// schemes of this package when they share a file system.
+type Chain struct {
+	resolvers []Resolver
+	owners    sync.Map // key -> Resolver that first resolved it
+}
+
+// NewChain returns a Chain of the specified resolvers.
+func NewChain(resolvers ...Resolver) *Chain {
+	return &Chain{resolvers: resolvers}
+}
+
+func (c *Chain) Resolve(from, name string) (string, error) {
+	var tried []error
+	for _, r := range c.resolvers {
+		key, err := r.Resolve(from, name)
+		if err == nil {
+			c.owners.LoadOrStore(key, r)
+			return key, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return "", err
+		}
+		tried = append(tried, err)
+	}
+	return "", &NotFoundError{From: from, Name: name, Tried: tried}
+}
+
+func (c *Chain) Open(key string) (*Module, error) {
+	if r, ok := c.owners.Load(key); ok {
+		return r.(Resolver).Open(key)
+	}
+	for _, r := range c.resolvers {
+		m, err := r.Open(key)
+		if err == nil || !errors.Is(err, ErrNotFound) {
+			return m, err
+		}
+	}
+	return nil, notFound("no resolver for key %q", key)
+}
+
+// -- file schemes --
+
+// openFile returns the module for the file of the specified key.
+// A key that is not a valid path, such as a label or a key of
+// another resolver in a Chain, is not found.
+func openFile(fsys fs.FS, key string) (*Module, error) {
+	if !fs.ValidPath(key) {
+		return nil, notFound("%q is not a file path", key)
+	}
+	data, err := fs.ReadFile(fsys, key)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			return nil, notFound("%s", err)
+		}
+		return nil, err
+	}
+	return &Module{Source: data}, nil
+}
+
+// exists reports whether the file system contains a regular file of that name.
+func exists(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// isLabel reports whether name has the syntax of a label.
+func isLabel(name string) bool {
+	return strings.HasPrefix(name, "@") || strings.HasPrefix(name, "//") || strings.HasPrefix(name, ":")
+}
+
+// Relative is a Resolver for module names that are slash-separated
+// file paths, interpreted relative to the directory of the loading
+// module, or to the root of FS if they begin with a slash or are
+// loaded by a root module. Keys are clean paths within FS.
+//
+// The keys of loading modules are assumed to be paths within FS, such
+// as those produced by Relative, SearchPath, and Labels (for files in
+// the main repository). Relative does not resolve paths relative to
+// a module with another key, such as one in another repository.
+type Relative struct {
+	FS fs.FS
+}
+
+func (r Relative) Resolve(from, name string) (string, error) {
+	if name == "" || isLabel(name) {
+		return "", notFound("relative: %q is not a relative path", name)
+	}
+	var key string
+	if strings.HasPrefix(name, "/") || from == "" {
+		key = path.Clean(strings.TrimPrefix(name, "/"))
+	} else if !fs.ValidPath(from) {
+		return "", notFound("relative: %s is not a file path", from)
+	} else {
+		key = path.Join(path.Dir(from), name)
+	}
+	if !fs.ValidPath(key) {
+		return "", fmt.Errorf("relative: %q refers to a file outside the root", name)
+	}
+	if !exists(r.FS, key) {
+		return "", notFound("relative: %s does not exist", key)
+	}
+	return key, nil
+}
+
+func (r Relative) Open(key string) (*Module, error) { return openFile(r.FS, key) }
+
+// SearchPath is a Resolver that looks up module names, which are
+// slash-separated file paths, in each of a list of directories of FS,
+// in order. Keys are clean paths within FS.
+// Names beginning with "./" or "../" are not resolved by SearchPath.
+type SearchPath struct {
+	FS   fs.FS
+	Dirs []string
+}
+
+func (r SearchPath) Resolve(from, name string) (string, error) {
+	if name == "" || isLabel(name) || strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../") {
+		return "", notFound("search path: %q is not a path", name)
+	}
+	var tried []string
+	for _, dir := range r.Dirs {
+		key := path.Join(dir, name)
+		if !fs.ValidPath(key) {
+			continue
+		}
+		if exists(r.FS, key) {
+			return key, nil
+		}
+		tried = append(tried, key)
+	}
+	return "", notFound("search path: none of [%s] exists", strings.Join(tried, ", "))
+}
+
+func (r SearchPath) Open(key string) (*Module, error) { return openFile(r.FS, key) }
+
+// Labels is a Resolver for Bazel-style labels of the forms:
+//
+//	@repo//pkg:file.star	# file in package pkg of repository repo
+//	//pkg:file.star		# file in package pkg of the loading module's repository
+//	:file.star		# file in the loading module's package
+//
+// The key of a file in the main repository is its path, for example
+// "pkg/file.star", as produced by Relative for the same file. The key
+// of a file in another repository is a canonical label, in which the
+// repository is explicit, for example "@repo//pkg:file.star".
+// A package is a directory of a repository; a file may be in a
+// subdirectory of its package, as in "//pkg:sub/file.star".
+//
+// A loading module whose key is a path is in the main repository.
+type Labels struct {
+	Main  fs.FS            // the main repository
+	Repos map[string]fs.FS // other repositories, by name
+}
+
+// A label is a parsed label.
+type label struct {
+	repo, pkg, file string
+}
+
+func (l label) String() string { return "@" + l.repo + "//" + l.pkg + ":" + l.file }
+
+// key returns the key of the labeled file; see Labels.
+func (l label) key() string {
+	if l.repo == "" {
+		return path.Join(l.pkg, l.file)
+	}
+	return l.String()
+}
+
+// parseLabel parses a label, relative to the label of the loading module.
+func parseLabel(name string, from label) (label, error) {
+	var l label
+	rest := name
+	switch {
+	case strings.HasPrefix(rest, "@"):
+		repo, after, ok := strings.Cut(rest[1:], "//")
+		if !ok {
+			return l, fmt.Errorf("label %q: missing // after repository", name)
+		}
+		l.repo, rest = repo, after
+	case strings.HasPrefix(rest, "//"):
+		l.repo, rest = from.repo, rest[2:]
+	case strings.HasPrefix(rest, ":"):
+		l.repo, l.pkg, rest = from.repo, from.pkg, rest[1:]
+		if rest == "" {
+			return l, fmt.Errorf("label %q: empty file name", name)
+		}
+		l.file = rest
+		return l, validLabel(name, l)
+	}
+	pkg, file, ok := strings.Cut(rest, ":")
+	if !ok {
+		return l, fmt.Errorf("label %q: missing :file", name)
+	}
+	l.pkg, l.file = pkg, file
+	return l, validLabel(name, l)
+}
+
+func validLabel(name string, l label) error {
+	if l.file == "" || !fs.ValidPath(l.file) {
+		return fmt.Errorf("label %q: invalid file name %q", name, l.file)
+	}
+	if l.pkg != "" && !fs.ValidPath(l.pkg) {
+		return fmt.Errorf("label %q: invalid package %q", name, l.pkg)
+	}
+	return nil
+}
+
+// fromLabel returns the label of the loading module with the specified key.
+func fromLabel(from string) label {
+	if isLabel(from) {
+		if l, err := parseLabel(from, label{}); err == nil {
+			return l
+		}
+	}
+	// A path within the main repository.
+	dir := path.Dir(from)
+	if dir == "." {
+		dir = ""
+	}
+	return label{pkg: dir, file: path.Base(from)}
+}
+
+func (r Labels) repo(name string) (fs.FS, bool) {
+	if name == "" {
+		return r.Main, r.Main != nil
+	}
+	fsys, ok := r.Repos[name]
+	return fsys, ok
+}
+
+func (r Labels) Resolve(from, name string) (string, error) {
+	if !isLabel(name) {
+		return "", notFound("labels: %q is not a label", name)
+	}
+	l, err := parseLabel(name, fromLabel(from))
+	if err != nil {
+		return "", err
+	}
+	fsys, ok := r.repo(l.repo)
+	if !ok {
+		return "", notFound("labels: unknown repository @%s", l.repo)
+	}
+	if !exists(fsys, path.Join(l.pkg, l.file)) {
+		return "", notFound("labels: %s does not exist", l)
+	}
+	return l.key(), nil
+}
+
+func (r Labels) Open(key string) (*Module, error) {
+	if !strings.HasPrefix(key, "@") {
+		if r.Main == nil {
+			return nil, notFound("labels: no main repository for %q", key)
+		}
+		return openFile(r.Main, key)
+	}
+	l, err := parseLabel(key, label{})
+	if err != nil {
+		return nil, notFound("labels: %v", err)
+	}
+	fsys, ok := r.repo(l.repo)
+	if !ok {
+		return nil, notFound("labels: unknown repository @%s", l.repo)
+	}
+	return openFile(fsys, path.Join(l.pkg, l.file))
+}
+
+// -- virtual modules --
+
+// Virtual is a Resolver for modules defined in Go.
+// The key of each module is its name.
+// The zero value is an empty set of modules ready to use.
+type Virtual struct {
+	mu      sync.Mutex
+	modules map[string]*starlarkstruct.Module
+}
+
+// Register adds a module to the set, under the name m.Name.
+// The module is frozen.
+func (v *Virtual) Register(m *starlarkstruct.Module) {
+	m.Freeze()
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.modules == nil {
+		v.modules = make(map[string]*starlarkstruct.Module)
+	}
+	v.modules[m.Name] = m
+}
+
+func (v *Virtual) lookup(name string) *starlarkstruct.Module {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.modules[name]
+}
+
+func (v *Virtual) Resolve(from, name string) (string, error) {
+	if v.lookup(name) == nil {
+		return "", notFound("virtual: no module %q", name)
+	}
+	return name, nil
+}
+
+func (v *Virtual) Open(key string) (*Module, error) {
+	m := v.lookup(key)
+	if m == nil {
+		return nil, notFound("virtual: no module %q", key)
+	}
+	return &Module{Members: m.Members}, nil
+}
+
+// -- integration with starlark.Loader --
+
+// NewLoader returns a new starlark.Loader that uses r to resolve
+// and open modules. The caller may set other fields of the Loader,
+// such as Options and Predeclared, before its first use.
+func NewLoader(r Resolver) *starlark.Loader {
+	var sources sync.Map // key -> []byte read by Virtual, awaiting Read
+	return &starlark.Loader{
+		Resolve: r.Resolve,
+		Virtual: func(key string) starlark.StringDict {
+			m, err := r.Open(key)
+			if err != nil {
+				return nil // reported by Read
+			}
+			if m.Members != nil {
+				return m.Members
+			}
+			sources.Store(key, m.Source)
+			return nil
+		},
+		Read: func(key string) ([]byte, error) {
+			if src, ok := sources.LoadAndDelete(key); ok {
+				return src.([]byte), nil
+			}
+			m, err := r.Open(key)
+			if err != nil {
+				return nil, err
+			}
+			return m.Source, nil
+		},
+	}
+}
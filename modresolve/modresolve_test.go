@@ -0,0 +1,224 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package modresolve_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.starlark.net/modresolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func file(src string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(src)} }
+
+func TestResolve(t *testing.T) {
+	main := fstest.MapFS{
+		"pkg/a.star":     file(""),
+		"pkg/sub/b.star": file(""),
+		"lib/c.star":     file(""),
+		"vendor/d.star":  file(""),
+	}
+	other := fstest.MapFS{
+		"x/y.star": file(""),
+	}
+	var virtual modresolve.Virtual
+	virtual.Register(&starlarkstruct.Module{Name: "json"})
+
+	r := modresolve.NewChain(
+		&virtual,
+		modresolve.Relative{FS: main},
+		modresolve.SearchPath{FS: main, Dirs: []string{"lib", "vendor"}},
+		modresolve.Labels{Main: main, Repos: map[string]fs.FS{"other": other}},
+	)
+	for _, test := range []struct {
+		from, name, want string
+	}{
+		{"", "pkg/a.star", "pkg/a.star"},
+		{"pkg/a.star", "sub/b.star", "pkg/sub/b.star"},
+		{"pkg/sub/b.star", "../a.star", "pkg/a.star"},
+		{"pkg/sub/b.star", "/lib/c.star", "lib/c.star"},
+		{"pkg/a.star", "c.star", "lib/c.star"},
+		{"pkg/a.star", "d.star", "vendor/d.star"},
+		{"pkg/a.star", "json", "json"},
+		{"pkg/a.star", "//pkg:sub/b.star", "pkg/sub/b.star"},
+		{"pkg/a.star", ":a.star", "pkg/a.star"},
+		{"", "@//pkg:a.star", "pkg/a.star"},
+		{"", "@other//x:y.star", "@other//x:y.star"},
+		{"@other//x:y.star", ":y.star", "@other//x:y.star"},
+		{"@other//x:y.star", "z.star", `cannot resolve "z.star" from @other//x:y.star`},
+		{"@//pkg:a.star", "//lib:c.star", "lib/c.star"},
+		{"pkg/a.star", "missing.star", `cannot resolve "missing.star" from pkg/a.star`},
+		{"pkg/a.star", "../../x.star", "refers to a file outside the root"},
+		{"", "@nope//x:y.star", "unknown repository @nope"},
+		{"", "//pkg", `missing :file`},
+	} {
+		key, err := r.Resolve(test.from, test.name)
+		if err != nil {
+			key = err.Error()
+		}
+		if !strings.Contains(key, test.want) {
+			t.Errorf("Resolve(%q, %q) = %q, want %q", test.from, test.name, key, test.want)
+		}
+	}
+}
+
+func TestNotFoundChain(t *testing.T) {
+	r := modresolve.NewChain(
+		modresolve.Relative{FS: fstest.MapFS{}},
+		modresolve.SearchPath{FS: fstest.MapFS{}, Dirs: []string{"a", "b"}},
+	)
+	_, err := r.Resolve("x/y.star", "z.star")
+	if !errors.Is(err, modresolve.ErrNotFound) {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+	const want = `cannot resolve "z.star" from x/y.star
+	module not found: relative: x/z.star does not exist
+	module not found: search path: none of [a/z.star, b/z.star] exists`
+	if got := err.Error(); got != want {
+		t.Errorf("got <<%s>>, want <<%s>>", got, want)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	main := fstest.MapFS{
+		"main.star":       file(`load("//lib:util.star", "twice"); load("math", "pi"); x = twice(pi)`),
+		"lib/util.star":   file(`load(":helper.star", "helper"); twice = helper`),
+		"lib/helper.star": file(`def helper(x): return 2 * x`),
+		"bad.star":        file(`load("lib/missing.star", "x")`),
+	}
+	var virtual modresolve.Virtual
+	virtual.Register(&starlarkstruct.Module{
+		Name:    "math",
+		Members: starlark.StringDict{"pi": starlark.MakeInt(3)},
+	})
+	loader := modresolve.NewLoader(modresolve.NewChain(
+		&virtual,
+		modresolve.Labels{Main: main},
+		modresolve.Relative{FS: main},
+	))
+
+	globals, err := loader.Load(new(starlark.Thread), "main.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := globals["x"].String(); got != "6" {
+		t.Errorf("x = %s, want 6", got)
+	}
+
+	_, err = loader.Load(new(starlark.Thread), "bad.star")
+	if want := `cannot load lib/missing.star: cannot resolve "lib/missing.star" from bad.star`; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+// TestChainDirFS checks that each resolver of a Chain over an os.DirFS
+// declines the keys of the others, in either order.
+func TestChainDirFS(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"main.star":      `load("//pkg:x.star", "x"); load("lib.star", "y"); z = x + y`,
+		"lib.star":       `y = 2`,
+		"pkg/x.star":     `x = 1`,
+		"pkg/sub.star":   `load(":x.star", "x"); w = x`,
+		"pkg/other.star": `load("sub.star", "w"); v = w`,
+	} {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := os.DirFS(dir)
+	relative := modresolve.Relative{FS: fsys}
+	labels := modresolve.Labels{Main: fsys}
+	for _, resolvers := range [][]modresolve.Resolver{{relative, labels}, {labels, relative}} {
+		chain := modresolve.NewChain(resolvers...)
+		loader := modresolve.NewLoader(chain)
+		globals, err := loader.Load(new(starlark.Thread), "main.star")
+		if err != nil {
+			t.Errorf("%T first: %v", resolvers[0], err)
+		} else if got := globals["z"].String(); got != "3" {
+			t.Errorf("%T first: z = %s, want 3", resolvers[0], got)
+		}
+		globals, err = loader.Load(new(starlark.Thread), "pkg/other.star")
+		if err != nil {
+			t.Errorf("%T first: %v", resolvers[0], err)
+		} else if got := globals["v"].String(); got != "1" {
+			t.Errorf("%T first: v = %s, want 1", resolvers[0], got)
+		}
+		if _, err := chain.Open("@//pkg:x.star"); err != nil {
+			t.Errorf("%T first: Open(label): %v", resolvers[0], err)
+		}
+		if _, err := chain.Open("pkg/x.star"); err != nil {
+			t.Errorf("%T first: Open(path): %v", resolvers[0], err)
+		}
+	}
+}
+
+// TestPathAndLabel checks that a file loaded both by path and by label
+// is a single module.
+func TestPathAndLabel(t *testing.T) {
+	main := fstest.MapFS{
+		"main.star": file(`
+load("//pkg:x.star", "f")
+load("pkg/x.star", g = "f")
+load("pkg/y.star", h = "f")
+same = f == g and g == h
+`),
+		"pkg/x.star": file(`def f(): pass`),
+		"pkg/y.star": file(`load(":x.star", _f = "f"); f = _f`),
+	}
+	var loads []string
+	loader := modresolve.NewLoader(modresolve.NewChain(
+		modresolve.Relative{FS: main},
+		modresolve.Labels{Main: main},
+	))
+	read := loader.Read
+	loader.Read = func(key string) ([]byte, error) {
+		loads = append(loads, key)
+		return read(key)
+	}
+	globals, err := loader.Load(new(starlark.Thread), "main.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if globals["same"] != starlark.True {
+		t.Errorf("f, g, and h are different functions")
+	}
+	slices.Sort(loads)
+	if got, want := strings.Join(loads, " "), "main.star pkg/x.star pkg/y.star"; got != want {
+		t.Errorf("loaded %s, want %s", got, want)
+	}
+}
+
+// TestChainOpen checks that a Chain opens a module using the resolver
+// that resolved its key, even if an earlier one can open the key too.
+func TestChainOpen(t *testing.T) {
+	r := modresolve.NewChain(
+		modresolve.Relative{FS: fstest.MapFS{"x.star": file("a")}},
+		modresolve.SearchPath{FS: fstest.MapFS{"x.star": file("b")}, Dirs: []string{"."}},
+	)
+	key, err := r.Resolve("sub/y.star", "x.star") // not sub/x.star
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := r.Open(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(m.Source); got != "b" {
+		t.Errorf("Open(%q) = %q, want %q", key, got, "b")
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package refactor
+
+// This file defines RemoveUnusedLoads and the editing of load statements.
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"go.starlark.net/index"
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// RemoveUnusedLoads returns the edits that remove the names bound by
+// load statements of the module with the specified key that are never
+// used, and the load statements that bind only such names.
+//
+// As in the unusedload check of package lint, names beginning with an
+// underscore are retained, as are names bound globally (see
+// syntax.FileOptions.LoadBindsGlobally), which may be used by other
+// modules.
+func RemoveUnusedLoads(ix *index.Index, key string) ([]TextEdit, error) {
+	e := newEditor(ix)
+	m, err := e.module(key)
+	if err != nil {
+		return nil, err
+	}
+	info := m.Info()
+	syntax.Walk(m.Syntax(), func(n syntax.Node) bool {
+		load, ok := n.(*syntax.LoadStmt)
+		if !ok {
+			return true
+		}
+		for i, id := range load.To {
+			bind := info.Binding(id)
+			if bind != nil && bind.First == id && bind.Scope != resolve.Global &&
+				info.Uses(bind) == nil && !strings.HasPrefix(id.Name, "_") {
+				e.removeLoadItem(m, load, i)
+			}
+		}
+		return false
+	})
+	return e.result(), nil
+}
+
+// removeLoadItem records the removal of the ith name of a load
+// statement of module m. If all its names are removed, the statement
+// is removed.
+func (e *editor) removeLoadItem(m *index.Module, load *syntax.LoadStmt, i int) {
+	if e.removed[load] == nil {
+		e.removed[load] = make(map[int]bool)
+		e.removedM[load] = m
+	}
+	e.removed[load][i] = true
+}
+
+// flushRemovals records the edits for the removal of load items.
+func (e *editor) flushRemovals() {
+	for load, removed := range e.removed {
+		m := e.removedM[load]
+		if len(removed) == len(load.To) {
+			start, end := e.stmtLines(m, load)
+			e.replace(m, start, end, "")
+			continue
+		}
+		// Delete each removed item with the separator that follows it,
+		// or, if no retained item follows, the separator that precedes it.
+		for i := range load.To {
+			if !removed[i] {
+				continue
+			}
+			start, end := e.loadItem(m, load, i)
+			retainedAfter := false
+			for j := i + 1; j < len(load.To); j++ {
+				retainedAfter = retainedAfter || !removed[j]
+			}
+			if retainedAfter {
+				next, _ := e.loadItem(m, load, i+1)
+				e.replace(m, start, next, "")
+			} else {
+				_, prev := e.loadItem(m, load, i-1)
+				e.replace(m, prev, end, "")
+			}
+		}
+	}
+	e.removed = make(map[*syntax.LoadStmt]map[int]bool)
+}
+
+// loadItem returns the offsets of the start and end of the ith item of
+// a load statement, such as "x" or y="x".
+func (e *editor) loadItem(m *index.Module, load *syntax.LoadStmt, i int) (start, end int) {
+	f := e.file(m)
+	lit := f.offset(load.From[i].NamePos) - 1 // the opening quote or raw prefix
+	start, end = lit, f.literalEnd(lit)
+	if load.To[i] != load.From[i] {
+		start = f.offset(load.To[i].NamePos)
+	}
+	return start, end
+}
+
+// stmtLines returns the offsets of the lines spanned by a top-level
+// statement of m, including its newline, or, if the statement does not
+// occupy its lines alone, of the statement itself.
+func (e *editor) stmtLines(m *index.Module, stmt syntax.Stmt) (start, end int) {
+	f := e.file(m)
+	first, last := stmt.Span()
+	start, end = f.offset(first), f.offset(last)
+	if _, ok := stmt.(*syntax.LoadStmt); ok {
+		end++ // Span ends at the closing parenthesis
+	}
+	if strings.TrimSpace(string(f.src[f.lineStart(first):start])) != "" {
+		return start, end
+	}
+	if rest := strings.TrimSpace(string(f.src[end:f.lineEnd(last)])); rest != "" && !strings.HasPrefix(rest, "#") {
+		return start, end
+	}
+	return f.lineStart(first), f.lineEnd(last)
+}
+
+// A loadItem is a name to be loaded: load(module, Local=Remote).
+type loadItem struct {
+	Local, Remote string
+}
+
+// formatLoad returns the text of a load statement, with a newline.
+func formatLoad(module string, items []loadItem) string {
+	var buf strings.Builder
+	buf.WriteString("load(")
+	buf.WriteString(quote(module))
+	for _, item := range items {
+		buf.WriteString(", ")
+		if item.Local != item.Remote {
+			buf.WriteString(item.Local)
+			buf.WriteString(" = ")
+		}
+		buf.WriteString(quote(item.Remote))
+	}
+	buf.WriteString(")\n")
+	return buf.String()
+}
+
+// addLoad records the insertion into module m of a load statement
+// that loads the items from the module with key target, after the
+// last top-level load statement of m, if any, or otherwise before its
+// first statement.
+func (e *editor) addLoad(m *index.Module, target string, items []loadItem) error {
+	name, err := e.moduleName(m, target)
+	if err != nil {
+		return err
+	}
+	text := formatLoad(name, items)
+
+	f := e.file(m)
+	stmts := m.Syntax().Stmts
+	var last syntax.Stmt
+	for _, stmt := range stmts {
+		if _, ok := stmt.(*syntax.LoadStmt); ok {
+			last = stmt
+		}
+	}
+	switch {
+	case last != nil:
+		_, end := e.stmtLines(m, last)
+		e.insert(m, end, text)
+	case len(stmts) > 0 && isDocString(stmts[0]):
+		_, end := e.stmtLines(m, stmts[0])
+		e.insert(m, end, "\n"+text)
+	case len(stmts) > 0:
+		// Insert after any header comment that is
+		// separated by a blank line from the first statement.
+		first, _ := stmts[0].Span()
+		at := 0
+		for line := 1; line < int(first.Line); line++ {
+			if strings.TrimSpace(f.line(line)) == "" {
+				at = f.lines[line]
+			}
+		}
+		e.insert(m, at, text+"\n")
+	default:
+		e.insert(m, len(f.src), text)
+	}
+	return nil
+}
+
+func isDocString(stmt syntax.Stmt) bool {
+	if expr, ok := stmt.(*syntax.ExprStmt); ok {
+		lit, ok := expr.X.(*syntax.Literal)
+		return ok && lit.Token == syntax.STRING
+	}
+	return false
+}
+
+// moduleName returns a module name by which module m may load the
+// module with the specified key. It prefers a name used by an existing
+// load statement, then a path relative to m, then the key itself.
+func (e *editor) moduleName(m *index.Module, key string) (string, error) {
+	var candidates []string
+	for _, mod := range e.ix.Modules {
+		for _, load := range mod.Loads {
+			if load.Target != nil && load.Target.Key == key {
+				candidates = append(candidates, load.Name)
+			}
+		}
+	}
+	if rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(m.Key)), filepath.FromSlash(key)); err == nil {
+		candidates = append(candidates, filepath.ToSlash(rel))
+	}
+	candidates = append(candidates, "/"+key, key)
+	for _, name := range candidates {
+		if k, err := e.ix.Resolve(m.Key, name); err == nil && k == key {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no module name by which %s can load %s", m.Key, key)
+}
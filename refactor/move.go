@@ -0,0 +1,381 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package refactor
+
+// This file defines Move.
+
+import (
+	"fmt"
+	"strings"
+
+	"go.starlark.net/index"
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// Move returns the edits that move the function defined by the
+// top-level def statement for name in the module with key from to the
+// end of the module with key to, together with the comment lines
+// immediately preceding it. If the new module already loaded the
+// function, it is moved instead before the first top-level statement
+// that refers to it, so that it is defined before its uses.
+//
+// The load statements of the function's new module are updated to
+// load the names the function uses that were loaded by its old one,
+// and those of its old module to load the function if it is still
+// used there. Each other module that loads the function is updated to
+// load it from its new module. If the new module loaded the function
+// under another name, as in load("m", g="f"), the load is removed and
+// that name's uses are renamed. Names loaded by the old module only
+// for the use of the function are no longer loaded.
+//
+// Move reports an error if the function refers to another global of
+// its module, which would also need to be moved; if a name it needs
+// is already declared differently in the new module; if renaming an
+// alias of the function in the new module would conflict with another
+// declaration; or if the moved function would create a load cycle.
+func Move(ix *index.Index, from, name, to string) ([]TextEdit, error) {
+	e := newEditor(ix)
+	src, err := e.module(from)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := e.module(to)
+	if err != nil {
+		return nil, err
+	}
+	if src == dst {
+		return nil, fmt.Errorf("cannot move %s from %s to itself", name, from)
+	}
+
+	// Find the def statement.
+	var def *syntax.DefStmt
+	for _, stmt := range src.Syntax().Stmts {
+		if d, ok := stmt.(*syntax.DefStmt); ok && d.Name.Name == name {
+			def = d
+		}
+	}
+	if def == nil {
+		return nil, fmt.Errorf("module %s has no top-level function %s", from, name)
+	}
+	sinfo, dinfo := src.Info(), dst.Info()
+	bind := sinfo.Binding(def.Name)
+	if bind == nil || bind.Scope != resolve.Global {
+		return nil, fmt.Errorf("%s: %s is not a global", def.Name.NamePos, name)
+	}
+	if defs := sinfo.Defs(bind); len(defs) > 1 {
+		return nil, fmt.Errorf("%s: %s is also assigned at %s", def.Name.NamePos, name, defs[1].NamePos)
+	}
+	defStart, defEnd := def.Span()
+	inDef := func(id *syntax.Ident) bool {
+		return !before(id.NamePos, defStart) && !before(defEnd, id.NamePos)
+	}
+
+	// The names loaded by each module, and the load items that bind them.
+	type loadRef struct {
+		load *index.Load
+		item int
+	}
+	loadsOf := func(m *index.Module) map[*resolve.Binding]loadRef {
+		refs := make(map[*resolve.Binding]loadRef)
+		for _, load := range m.Loads {
+			for i, id := range load.Stmt().To {
+				if b := m.Info().Binding(id); b != nil {
+					refs[m.Info().Origin(b)] = loadRef{load, i}
+				}
+			}
+		}
+		return refs
+	}
+	srcLoads, dstLoads := loadsOf(src), loadsOf(dst)
+
+	// The new module must not already declare the name,
+	// unless it loads it from the old module.
+	var dstLoad *loadRef
+	var dstBindings []*resolve.Binding // bindings of the function in the new module
+	if prev := dinfo.Root.Lookup(name); prev != nil {
+		ref, ok := dstLoads[prev]
+		if !ok || ref.load.Target != src || ref.load.Stmt().From[ref.item].Name != name {
+			return nil, fmt.Errorf("%s: %s is already declared in %s", prev.First.NamePos, name, to)
+		}
+		dstLoad = &ref
+		dstBindings = append(dstBindings, prev)
+	}
+
+	// The aliases under which the new module loads the function,
+	// such as g in load("m", g="f"), must be renamed.
+	var dstAliases []loadRef
+	for _, load := range dst.Loads {
+		if load.Target != src {
+			continue
+		}
+		stmt := load.Stmt()
+		for i, id := range stmt.From {
+			if id.Name != name || stmt.To[i].Name == name {
+				continue
+			}
+			b := dinfo.Binding(stmt.To[i])
+			if b == nil {
+				continue
+			}
+			if err := conflicts(dinfo, dinfo.Origin(b), name); err != nil {
+				return nil, fmt.Errorf("cannot rename %s, the name of %s in %s: %v", stmt.To[i].Name, name, to, err)
+			}
+			dstAliases = append(dstAliases, loadRef{load, i})
+			dstBindings = append(dstBindings, dinfo.Origin(b))
+		}
+	}
+
+	// Determine the names that the function needs to load,
+	// grouped by module in order of first use.
+	needed := make(map[string][]loadItem)
+	var neededKeys []string
+	usedByDef := make(map[*resolve.Binding]bool)
+	var walkErr error
+	syntax.Walk(def, func(n syntax.Node) bool {
+		id, ok := n.(*syntax.Ident)
+		if !ok || walkErr != nil {
+			return walkErr == nil
+		}
+		b := sinfo.Binding(id)
+		if b == nil {
+			return true
+		}
+		b = sinfo.Origin(b)
+		if b == bind || sinfo.BlockOf(b) != sinfo.Root || usedByDef[b] {
+			return true
+		}
+		usedByDef[b] = true
+		ref, ok := srcLoads[b]
+		if !ok {
+			walkErr = fmt.Errorf("%s: %s refers to %s, a global of %s", id.NamePos, name, id.Name, from)
+			return false
+		}
+		if ref.load.Target == nil {
+			walkErr = fmt.Errorf("%s: %s refers to %s, loaded from unresolved module %q", id.NamePos, name, id.Name, ref.load.Name)
+			return false
+		}
+		item := loadItem{Local: id.Name, Remote: ref.load.Stmt().From[ref.item].Name}
+		target := ref.load.Target.Key
+		if target == to {
+			if item.Local != item.Remote {
+				walkErr = fmt.Errorf("%s: %s refers to %s, which would be %s in %s", id.NamePos, name, id.Name, item.Remote, to)
+				return false
+			}
+			return true // a global of the new module
+		}
+		if prev := dinfo.Root.Lookup(id.Name); prev != nil {
+			dref, ok := dstLoads[prev]
+			if ok && dref.load.Target != nil && dref.load.Target.Key == target && dref.load.Stmt().From[dref.item].Name == item.Remote {
+				return true // already loaded
+			}
+			walkErr = fmt.Errorf("%s: %s refers to %s, which is declared differently in %s at %s",
+				id.NamePos, name, id.Name, to, prev.First.NamePos)
+			return false
+		}
+		if needed[target] == nil {
+			neededKeys = append(neededKeys, target)
+		}
+		needed[target] = append(needed[target], item)
+		return true
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	// Does the old module still use the function?
+	stillUsed := false
+	for _, id := range sinfo.Uses(bind) {
+		stillUsed = stillUsed || !inDef(id)
+	}
+
+	// The loaders of the function, other than the new module.
+	var loaders []*index.Load
+	if sym := ix.Lookup(from, name); sym != nil {
+		for _, load := range ix.LoadersOf(sym) {
+			if load.From != dst {
+				loaders = append(loaders, load)
+			}
+		}
+	}
+
+	// Check that the new loads would not create a cycle.
+	// Every new edge leads to or from the new module.
+	// The loads of the function by the new module are removed.
+	removed := make(map[*index.Load]int) // number of removed items
+	for _, ref := range dstAliases {
+		removed[ref.load]++
+	}
+	if dstLoad != nil {
+		removed[dstLoad.load]++
+	}
+	edges := make(map[*index.Module][]*index.Module)
+	for _, m := range ix.Modules {
+		for _, load := range m.Loads {
+			if load.Target != nil && removed[load] < len(load.Names) {
+				edges[m] = append(edges[m], load.Target)
+			}
+		}
+	}
+	for _, key := range neededKeys {
+		edges[dst] = append(edges[dst], ix.Module(key))
+	}
+	if stillUsed {
+		edges[src] = append(edges[src], dst)
+	}
+	for _, load := range loaders {
+		edges[load.From] = append(edges[load.From], dst)
+	}
+	if path := cycle(edges, dst); path != "" {
+		return nil, fmt.Errorf("moving %s to %s would create a load cycle: %s", name, to, path)
+	}
+
+	// Move the function text, with its preceding comment lines,
+	// and any blank lines that follow it (or, at the end of the file,
+	// precede it).
+	sf := e.file(src)
+	first, last := int(defStart.Line), int(defEnd.Line)
+	for first > 1 && strings.HasPrefix(strings.TrimSpace(sf.line(first-1)), "#") {
+		first--
+	}
+	start, end := sf.lines[first-1], sf.lineEnd(defEnd)
+	text := string(sf.src[start:end])
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	for next := last + 1; next <= len(sf.lines) && end < len(sf.src) && strings.TrimSpace(sf.line(next)) == ""; next++ {
+		end = sf.lineEnd(syntax.MakePosition(nil, int32(next), 1))
+	}
+	if end == len(sf.src) {
+		for prev := first - 1; prev >= 1 && strings.TrimSpace(sf.line(prev)) == ""; prev-- {
+			start = sf.lines[prev-1]
+		}
+	}
+	e.replace(src, start, end, "")
+
+	// Update the loads of the new module, and add the function
+	// before the first statement that uses it, if any, or at the end.
+	for _, key := range neededKeys {
+		if err := e.addLoad(dst, key, needed[key]); err != nil {
+			return nil, err
+		}
+	}
+	df := e.file(dst)
+	firstUse := -1
+uses:
+	for _, stmt := range dst.Syntax().Stmts {
+		if _, ok := stmt.(*syntax.LoadStmt); ok {
+			continue
+		}
+		start, end := stmt.Span()
+		for _, b := range dstBindings {
+			for _, id := range dinfo.Refs(b) {
+				if !before(id.NamePos, start) && !before(end, id.NamePos) {
+					line := int(start.Line)
+					for line > 1 && strings.HasPrefix(strings.TrimSpace(df.line(line-1)), "#") {
+						line--
+					}
+					firstUse = df.lines[line-1]
+					break uses
+				}
+			}
+		}
+	}
+	switch {
+	case firstUse >= 0:
+		e.insert(dst, firstUse, text+"\n")
+	case len(df.src) == 0:
+		if len(neededKeys) > 0 {
+			text = "\n" + text
+		}
+		e.insert(dst, len(df.src), text)
+	case strings.HasSuffix(string(df.src), "\n"):
+		e.insert(dst, len(df.src), "\n"+text)
+	default:
+		e.insert(dst, len(df.src), "\n\n"+text)
+	}
+	if dstLoad != nil {
+		e.removeLoadItem(dst, dstLoad.load.Stmt(), dstLoad.item)
+	}
+	for _, ref := range dstAliases {
+		stmt := ref.load.Stmt()
+		alias := stmt.To[ref.item]
+		for _, id := range dinfo.Refs(dinfo.Origin(dinfo.Binding(alias))) {
+			if id != alias {
+				e.rename(dst, id, name)
+			}
+		}
+		e.removeLoadItem(dst, stmt, ref.item)
+	}
+
+	// Update the loads of the old module.
+	if stillUsed {
+		if err := e.addLoad(src, to, []loadItem{{name, name}}); err != nil {
+			return nil, err
+		}
+	}
+	for b := range usedByDef {
+		ref, ok := srcLoads[b]
+		if !ok {
+			continue
+		}
+		used := false
+		for _, id := range sinfo.Uses(b) {
+			used = used || !inDef(id)
+		}
+		if !used && b.Scope != resolve.Global {
+			e.removeLoadItem(src, ref.load.Stmt(), ref.item)
+		}
+	}
+
+	// Update the loads of the other loading modules.
+	for _, load := range loaders {
+		stmt := load.Stmt()
+		var items []loadItem
+		for i, id := range stmt.From {
+			if id.Name == name {
+				items = append(items, loadItem{Local: stmt.To[i].Name, Remote: name})
+				e.removeLoadItem(load.From, stmt, i)
+			}
+		}
+		modName, err := e.moduleName(load.From, to)
+		if err != nil {
+			return nil, err
+		}
+		_, end := e.stmtLines(load.From, stmt)
+		e.insert(load.From, end, formatLoad(modName, items))
+	}
+
+	return e.result(), nil
+}
+
+// cycle returns a description of a cycle in the graph through the
+// node start, or "" if there is none.
+func cycle(edges map[*index.Module][]*index.Module, start *index.Module) string {
+	seen := make(map[*index.Module]bool)
+	var path []string
+	var visit func(m *index.Module) bool
+	visit = func(m *index.Module) bool {
+		path = append(path, m.Key)
+		for _, next := range edges[m] {
+			if next == start {
+				path = append(path, start.Key)
+				return true
+			}
+			if !seen[next] {
+				seen[next] = true
+				if visit(next) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(start) {
+		return strings.Join(path, " -> ")
+	}
+	return ""
+}
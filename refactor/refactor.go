@@ -0,0 +1,311 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package refactor provides automated refactorings of Starlark
+// modules: renaming a variable, moving a function to another module,
+// and removing unused loads.
+//
+// Each refactoring operates on an index.Index of the modules concerned,
+// so that it can update the load statements of the modules that import
+// a renamed or moved name. It does not modify any file: it returns a
+// list of TextEdits, which replace spans of the original text of each
+// module and so preserve its comments and formatting. The edits may be
+// applied by Apply, or sent to an editor, for example as the changes
+// of an LSP workspace/applyEdit request (see TextEdit.LSPRange).
+//
+// A refactoring reports an error, and no edits, if it cannot be
+// applied safely: for example, if a new name would conflict with an
+// existing one, or if moving a function would create a load cycle.
+package refactor // import "go.starlark.net/refactor"
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"go.starlark.net/index"
+	"go.starlark.net/syntax"
+)
+
+// A TextEdit is a change to the text of a module: the replacement of
+// the text between Start and End by NewText. An insertion has equal
+// Start and End. As for syntax.Position, lines and columns are
+// 1-based, and columns are measured in runes.
+type TextEdit struct {
+	Filename   string // the key of the module
+	Start, End syntax.Position
+	NewText    string
+}
+
+func (e TextEdit) String() string {
+	return fmt.Sprintf("%s:%d:%d-%d:%d: %q", e.Filename, e.Start.Line, e.Start.Col, e.End.Line, e.End.Col, e.NewText)
+}
+
+// An LSPRange is a range of text in the form used by the Language
+// Server Protocol: lines are zero-based, and characters are measured
+// in UTF-16 code units.
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End   LSPPosition `json:"end"`
+}
+
+// An LSPPosition is a position in the form used by the Language
+// Server Protocol.
+type LSPPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// LSPRange returns the range of text replaced by e, where src
+// is the original text of the module.
+func (e TextEdit) LSPRange(src []byte) LSPRange {
+	f := newFile(src)
+	pos := func(p syntax.Position) LSPPosition {
+		line := int(p.Line) - 1
+		start := f.offset(syntax.MakePosition(nil, p.Line, 1))
+		chars := 0
+		for _, r := range string(src[start:f.offset(p)]) {
+			chars += utf16.RuneLen(r)
+		}
+		return LSPPosition{Line: line, Character: chars}
+	}
+	return LSPRange{Start: pos(e.Start), End: pos(e.End)}
+}
+
+// Apply returns the result of applying the edits, which must all be
+// edits of the same module and must not overlap, to its text src.
+func Apply(src []byte, edits []TextEdit) ([]byte, error) {
+	f := newFile(src)
+	type span struct {
+		start, end int
+		text       string
+	}
+	spans := make([]span, len(edits))
+	for i, e := range edits {
+		spans[i] = span{f.offset(e.Start), f.offset(e.End), e.NewText}
+		if spans[i].start > spans[i].end {
+			return nil, fmt.Errorf("invalid edit %v: end precedes start", e)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	var out []byte
+	last := 0
+	for i, s := range spans {
+		if s.start < last {
+			return nil, fmt.Errorf("overlapping edits %v", edits[i])
+		}
+		out = append(out, src[last:s.start]...)
+		out = append(out, s.text...)
+		last = s.end
+	}
+	return append(out, src[last:]...), nil
+}
+
+// -- source text --
+
+// A file provides conversions between positions and offsets
+// in the text of a module.
+type file struct {
+	src   []byte
+	lines []int // offset of the start of each line
+}
+
+func newFile(src []byte) *file {
+	f := &file{src: src, lines: []int{0}}
+	for i, b := range src {
+		if b == '\n' {
+			f.lines = append(f.lines, i+1)
+		}
+	}
+	return f
+}
+
+// offset returns the byte offset of pos.
+// A position beyond the end of the text denotes the end.
+func (f *file) offset(pos syntax.Position) int {
+	if pos.Line < 1 || int(pos.Line) > len(f.lines) {
+		return len(f.src)
+	}
+	i := f.lines[pos.Line-1]
+	for col := pos.Col; col > 1 && i < len(f.src) && f.src[i] != '\n'; col-- {
+		_, size := utf8.DecodeRune(f.src[i:])
+		i += size
+	}
+	return i
+}
+
+// position returns the position of the byte offset i.
+func (f *file) position(filename *string, i int) syntax.Position {
+	line := sort.Search(len(f.lines), func(l int) bool { return f.lines[l] > i })
+	col := 1 + utf8.RuneCount(f.src[f.lines[line-1]:i])
+	return syntax.MakePosition(filename, int32(line), int32(col))
+}
+
+// lineStart returns the offset of the start of the line containing pos.
+func (f *file) lineStart(pos syntax.Position) int {
+	return f.lines[pos.Line-1]
+}
+
+// lineEnd returns the offset of the start of the line after pos,
+// or the end of the text.
+func (f *file) lineEnd(pos syntax.Position) int {
+	if int(pos.Line) < len(f.lines) {
+		return f.lines[pos.Line]
+	}
+	return len(f.src)
+}
+
+// line returns the text of the specified 1-based line, without its newline.
+func (f *file) line(n int) string {
+	start := f.lines[n-1]
+	end := len(f.src)
+	if n < len(f.lines) {
+		end = f.lines[n] - 1
+	}
+	return string(f.src[start:end])
+}
+
+// literalEnd returns the offset of the end of the string literal
+// that begins at offset i.
+func (f *file) literalEnd(i int) int {
+	src := f.src
+	if i < len(src) && (src[i] == 'r' || src[i] == 'R') {
+		i++
+	}
+	if i >= len(src) {
+		return i
+	}
+	quote := src[i]
+	if i+2 < len(src) && src[i+1] == quote && src[i+2] == quote {
+		for j := i + 3; j+2 < len(src); j++ {
+			if src[j] == '\\' {
+				j++
+			} else if src[j] == quote && src[j+1] == quote && src[j+2] == quote {
+				return j + 3
+			}
+		}
+		return len(src)
+	}
+	for j := i + 1; j < len(src); j++ {
+		if src[j] == '\\' {
+			j++
+		} else if src[j] == quote || src[j] == '\n' {
+			return j + 1
+		}
+	}
+	return len(src)
+}
+
+// -- editing --
+
+// An editor accumulates the edits of a refactoring.
+type editor struct {
+	ix    *index.Index
+	files map[*index.Module]*file
+	edits []TextEdit
+
+	// load items to be removed, by statement
+	removed  map[*syntax.LoadStmt]map[int]bool
+	removedM map[*syntax.LoadStmt]*index.Module
+}
+
+func newEditor(ix *index.Index) *editor {
+	return &editor{
+		ix:       ix,
+		files:    make(map[*index.Module]*file),
+		removed:  make(map[*syntax.LoadStmt]map[int]bool),
+		removedM: make(map[*syntax.LoadStmt]*index.Module),
+	}
+}
+
+// module returns the module with the specified key, which must have
+// been parsed.
+func (e *editor) module(key string) (*index.Module, error) {
+	m := e.ix.Module(key)
+	if m == nil {
+		return nil, fmt.Errorf("no module %s in index", key)
+	}
+	if m.Syntax() == nil {
+		return nil, fmt.Errorf("module %s has no syntax tree", key)
+	}
+	return m, nil
+}
+
+func (e *editor) file(m *index.Module) *file {
+	f := e.files[m]
+	if f == nil {
+		f = newFile(m.Source())
+		e.files[m] = f
+	}
+	return f
+}
+
+// replace records the replacement of the text of m between the
+// offsets i and j.
+func (e *editor) replace(m *index.Module, i, j int, text string) {
+	f := e.file(m)
+	e.edits = append(e.edits, TextEdit{
+		Filename: m.Key,
+		Start:    f.position(&m.Key, i),
+		End:      f.position(&m.Key, j),
+		NewText:  text,
+	})
+}
+
+// insert records the insertion of text at offset i of m.
+func (e *editor) insert(m *index.Module, i int, text string) { e.replace(m, i, i, text) }
+
+// rename records the replacement of the identifier id of m.
+// If id is the name within a string literal of a load statement,
+// only the name is replaced.
+func (e *editor) rename(m *index.Module, id *syntax.Ident, name string) {
+	f := e.file(m)
+	i := f.offset(id.NamePos)
+	if !strings.HasPrefix(string(f.src[i:]), id.Name) {
+		i++ // the name of a raw string literal follows its quote
+	}
+	e.replace(m, i, i+len(id.Name), name)
+}
+
+// result returns the edits in order of module and position,
+// less duplicates.
+func (e *editor) result() []TextEdit {
+	e.flushRemovals()
+	sort.SliceStable(e.edits, func(i, j int) bool {
+		x, y := e.edits[i], e.edits[j]
+		if x.Filename != y.Filename {
+			return x.Filename < y.Filename
+		}
+		return before(x.Start, y.Start)
+	})
+	out := e.edits[:0]
+	for i, edit := range e.edits {
+		if i > 0 && edit == e.edits[i-1] {
+			continue
+		}
+		out = append(out, edit)
+	}
+	return out
+}
+
+func before(p, q syntax.Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+// quote returns a Starlark string literal for s.
+func quote(s string) string { return strconv.Quote(s) }
+
+// isIdent reports whether s is a valid identifier.
+func isIdent(s string) bool {
+	expr, err := new(syntax.FileOptions).ParseExpr("", s, 0)
+	if err != nil {
+		return false
+	}
+	id, ok := expr.(*syntax.Ident)
+	return ok && id.Name == s
+}
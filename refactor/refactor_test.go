@@ -0,0 +1,397 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package refactor_test
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"go.starlark.net/index"
+	"go.starlark.net/modresolve"
+	"go.starlark.net/refactor"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// buildIndex returns an index of the files.
+func buildIndex(t *testing.T, files map[string]string) *index.Index {
+	t.Helper()
+	fsys := make(fstest.MapFS)
+	var roots []string
+	for name, content := range files {
+		fsys[name] = &fstest.MapFile{Data: []byte(content)}
+		roots = append(roots, name)
+	}
+	cfg := &index.Config{
+		Resolver:    modresolve.Relative{FS: fsys},
+		IsUniversal: starlark.Universe.Has,
+	}
+	ix, err := cfg.Build(roots...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range ix.Modules {
+		if len(m.Errors) > 0 {
+			t.Fatalf("errors in %s: %v", m.Key, m.Errors)
+		}
+	}
+	return ix
+}
+
+// apply applies the edits and returns the new text of each changed file.
+func apply(t *testing.T, ix *index.Index, edits []refactor.TextEdit) map[string]string {
+	t.Helper()
+	byFile := make(map[string][]refactor.TextEdit)
+	for _, e := range edits {
+		byFile[e.Filename] = append(byFile[e.Filename], e)
+	}
+	result := make(map[string]string)
+	for name, edits := range byFile {
+		out, err := refactor.Apply(ix.Module(name).Source(), edits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result[name] = string(out)
+	}
+	return result
+}
+
+// find returns the position of the nth occurrence (from 1) of the
+// substring in the file.
+func find(t *testing.T, ix *index.Index, key, substr string, n int) syntax.Position {
+	t.Helper()
+	for i, line := range strings.Split(string(ix.Module(key).Source()), "\n") {
+		for col := 0; ; {
+			j := strings.Index(line[col:], substr)
+			if j < 0 {
+				break
+			}
+			col += j
+			if n--; n == 0 {
+				return syntax.MakePosition(&key, int32(i+1), int32(len([]rune(line[:col]))+1))
+			}
+			col++
+		}
+	}
+	t.Fatalf("%s: no occurrence of %q", key, substr)
+	return syntax.Position{}
+}
+
+func checkFiles(t *testing.T, got, want map[string]string) {
+	t.Helper()
+	for name, text := range want {
+		if got[name] != text {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, got[name], text)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("unexpected edit of %s:\n%s", name, got[name])
+		}
+	}
+}
+
+var renameFiles = map[string]string{
+	"main.star": `load("lib.star", "greet", hi = "greet")
+
+def main(name):
+    greet(name)  # say hello
+    hi(name)
+
+main("world")
+`,
+	"main2.star": `load("lib.star", "greet")
+
+greeting = greet
+`,
+	"lib.star": `def greet(who):
+    """Greets someone."""
+    msg = "hello, " + who
+    def show():
+        print(msg)
+    show()
+`,
+}
+
+func TestRenameGlobal(t *testing.T) {
+	ix := buildIndex(t, renameFiles)
+	edits, err := refactor.Rename(ix, "lib.star", find(t, ix, "lib.star", "greet", 1), "welcome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, apply(t, ix, edits), map[string]string{
+		"main.star": `load("lib.star", "welcome", hi = "welcome")
+
+def main(name):
+    welcome(name)  # say hello
+    hi(name)
+
+main("world")
+`,
+		"main2.star": `load("lib.star", "welcome")
+
+greeting = welcome
+`,
+		"lib.star": `def welcome(who):
+    """Greets someone."""
+    msg = "hello, " + who
+    def show():
+        print(msg)
+    show()
+`,
+	})
+
+	// Renaming the quoted name of an aliased load renames the global.
+	edits2, err := refactor.Rename(ix, "main.star", find(t, ix, "main.star", "greet", 2), "welcome")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edits2) != len(edits) {
+		t.Errorf("rename from load: got %d edits, want %d", len(edits2), len(edits))
+	}
+}
+
+func TestRenameLocal(t *testing.T) {
+	ix := buildIndex(t, renameFiles)
+
+	// A local captured by a nested function.
+	edits, err := refactor.Rename(ix, "lib.star", find(t, ix, "lib.star", "msg", 2), "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, apply(t, ix, edits), map[string]string{
+		"lib.star": `def greet(who):
+    """Greets someone."""
+    text = "hello, " + who
+    def show():
+        print(text)
+    show()
+`,
+	})
+
+	// A name bound by a load statement.
+	edits, err = refactor.Rename(ix, "main.star", find(t, ix, "main.star", "greet(", 1), "g")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, apply(t, ix, edits), map[string]string{
+		"main.star": `load("lib.star", g = "greet", hi = "greet")
+
+def main(name):
+    g(name)  # say hello
+    hi(name)
+
+main("world")
+`,
+	})
+}
+
+func TestRenameErrors(t *testing.T) {
+	ix := buildIndex(t, renameFiles)
+	for _, test := range []struct {
+		key, at string
+		n       int
+		newName string
+		want    string
+	}{
+		{"lib.star", "msg", 1, "who", "who is already declared"},
+		{"lib.star", "msg", 1, "show", "show is already declared"},
+		{"lib.star", "who", 1, "print", "would shadow reference to print"},
+		{"lib.star", "show", 1, "msg", "msg is already declared"},
+		{"lib.star", "greet", 1, "_greet", "cannot rename greet to unexported _greet"},
+		{"main.star", "name", 1, "hi", "would shadow reference to hi"},
+		{"main.star", `("world")`, 1, "x", "no identifier"},
+		{"lib.star", "print", 1, "x", "cannot rename built-in print"},
+		{"lib.star", "msg", 1, "def", `invalid name "def"`},
+	} {
+		_, err := refactor.Rename(ix, test.key, find(t, ix, test.key, test.at, test.n), test.newName)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("rename %s to %s: got error %v, want %q", test.at, test.newName, err, test.want)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	ix := buildIndex(t, map[string]string{
+		"main.star": `load("defs.star", "build", "other")
+load("util.star", "helper")
+
+build()
+other()
+helper()
+`,
+		"defs.star": `"""Definitions."""
+
+load("util.star", "helper", "fmt")
+
+# build builds things.
+def build():
+    fmt(helper())
+
+def other():
+    build()
+`,
+		"util.star": `def helper():
+    return 1
+
+def fmt(x):
+    return str(x)
+`,
+		"rules.star": `load("util.star", "fmt")
+`,
+		"extra.star": "helper = None\n",
+	})
+	edits, err := refactor.Move(ix, "defs.star", "build", "rules.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, apply(t, ix, edits), map[string]string{
+		"main.star": `load("defs.star", "other")
+load("rules.star", "build")
+load("util.star", "helper")
+
+build()
+other()
+helper()
+`,
+		"defs.star": `"""Definitions."""
+
+load("rules.star", "build")
+
+def other():
+    build()
+`,
+		"rules.star": `load("util.star", "fmt")
+load("util.star", "helper")
+
+# build builds things.
+def build():
+    fmt(helper())
+`,
+	})
+
+	// The new module loads the function under another name.
+	ix2 := buildIndex(t, map[string]string{
+		"a.star": `def f():
+    return 1
+
+def other():
+    pass
+`,
+		"b.star": `load("a.star", "other", g = "f")
+
+def h():
+    return g() + 1
+
+x = g()
+`,
+		"c.star": `load("a.star", g = "f")
+
+def k(f):
+    return g(f)
+`,
+	})
+	edits, err = refactor.Move(ix2, "a.star", "f", "b.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, apply(t, ix2, edits), map[string]string{
+		"a.star": `def other():
+    pass
+`,
+		"b.star": `load("a.star", "other")
+
+def f():
+    return 1
+
+def h():
+    return f() + 1
+
+x = f()
+`,
+		"c.star": `load("b.star", g = "f")
+
+def k(f):
+    return g(f)
+`,
+	})
+	_, err = refactor.Move(ix2, "a.star", "f", "c.star")
+	if want := "cannot rename g, the name of f in c.star"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("move f to c.star: got error %v, want %q", err, want)
+	}
+
+	// Errors.
+	for _, test := range []struct{ from, name, to, want string }{
+		{"defs.star", "other", "util.star", "other refers to build, a global of defs.star"},
+		{"defs.star", "build", "main.star", "would create a load cycle: main.star -> defs.star -> main.star"},
+		{"util.star", "helper", "extra.star", "helper is already declared in extra.star"},
+		{"defs.star", "nope", "util.star", "no top-level function nope"},
+	} {
+		_, err := refactor.Move(ix, test.from, test.name, test.to)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("move %s: got error %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestRemoveUnusedLoads(t *testing.T) {
+	ix := buildIndex(t, map[string]string{
+		"main.star": `# Header.
+
+load("a.star", "x", "y", "z")  # xyz
+load("a.star", "w")
+load("a.star",
+    _w = "w",
+    v = "x",
+)
+
+print(y, v)
+`,
+		"a.star": "x, y, z, w = 1, 2, 3, 4\n",
+	})
+	edits, err := refactor.RemoveUnusedLoads(ix, "main.star")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFiles(t, apply(t, ix, edits), map[string]string{
+		"main.star": `# Header.
+
+load("a.star", "y")  # xyz
+load("a.star",
+    _w = "w",
+    v = "x",
+)
+
+print(y, v)
+`,
+	})
+}
+
+func TestApply(t *testing.T) {
+	src := []byte("aπc\ndef\n")
+	file := "f"
+	pos := func(line, col int32) syntax.Position { return syntax.MakePosition(&file, line, col) }
+	edits := []refactor.TextEdit{
+		{Filename: file, Start: pos(2, 1), End: pos(2, 4), NewText: "DEF"},
+		{Filename: file, Start: pos(1, 2), End: pos(1, 3), NewText: "b"},
+		{Filename: file, Start: pos(3, 1), End: pos(3, 1), NewText: "ghi\n"},
+	}
+	got, err := refactor.Apply(src, edits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abc\nDEF\nghi\n"; string(got) != want {
+		t.Errorf("Apply: got %q, want %q", got, want)
+	}
+	if r := edits[1].LSPRange(src); r != (refactor.LSPRange{Start: refactor.LSPPosition{0, 1}, End: refactor.LSPPosition{0, 2}}) {
+		t.Errorf("LSPRange: got %+v", r)
+	}
+
+	edits = append(edits, refactor.TextEdit{Filename: file, Start: pos(2, 2), End: pos(2, 3)})
+	if _, err := refactor.Apply(src, edits); err == nil || !strings.Contains(err.Error(), "overlapping") {
+		t.Errorf("Apply: got error %v, want overlap", err)
+	}
+}
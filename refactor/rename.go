@@ -0,0 +1,250 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package refactor
+
+// This file defines Rename.
+
+import (
+	"fmt"
+	"strings"
+
+	"go.starlark.net/index"
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// Rename returns the edits that rename the variable denoted by the
+// identifier at position pos of the module with the specified key.
+//
+// The variable may be a global, a local, a parameter, or a name bound
+// by a load statement. Renaming an exported global also renames it in
+// every load statement that loads it, and, where the load statement
+// does not give it a different local name, renames its uses in the
+// loading module too. Renaming a name bound by a load statement, or
+// the quoted name in a load statement such as load("m", y="x"),
+// renames the local name or the global of the loaded module,
+// respectively.
+//
+// Rename reports an error if the new name would conflict with an
+// existing declaration, or change the meaning of an existing reference.
+// It does not update the keywords of calls that pass an argument to a
+// renamed parameter.
+func Rename(ix *index.Index, key string, pos syntax.Position, newName string) ([]TextEdit, error) {
+	e := newEditor(ix)
+	m, err := e.module(key)
+	if err != nil {
+		return nil, err
+	}
+	if !isIdent(newName) {
+		return nil, fmt.Errorf("invalid name %q", newName)
+	}
+
+	// Find the identifier at pos, noting whether it is
+	// the remote name of an aliased load.
+	var (
+		found  *syntax.Ident
+		remote *syntax.LoadStmt
+	)
+	syntax.Walk(m.Syntax(), func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.LoadStmt:
+			for i, id := range n.From {
+				if id != n.To[i] && covers(id, pos) {
+					found, remote = id, n
+				}
+			}
+		case *syntax.Ident:
+			if covers(n, pos) {
+				found = n
+			}
+		}
+		return found == nil
+	})
+	if found == nil {
+		return nil, fmt.Errorf("%s: no identifier at this position", pos)
+	}
+
+	if remote != nil {
+		// Rename the global of the loaded module.
+		var target *index.Module
+		for _, load := range m.Loads {
+			if load.Stmt() == remote {
+				target = load.Target
+			}
+		}
+		if target == nil || target.Virtual || target.Info() == nil {
+			return nil, fmt.Errorf("%s: cannot rename %s: module %q is not available", pos, found.Name, remote.ModuleName())
+		}
+		bind := target.Info().Root.Lookup(found.Name)
+		if bind == nil || bind.Scope != resolve.Global {
+			return nil, fmt.Errorf("%s: module %s has no global %s", pos, target.Key, found.Name)
+		}
+		m = target
+		found = bind.First
+	}
+
+	bind := m.Info().Binding(found)
+	if bind == nil {
+		return nil, fmt.Errorf("%s: %s is not a variable", pos, found.Name)
+	}
+	switch bind.Scope {
+	case resolve.Predeclared, resolve.Universal:
+		return nil, fmt.Errorf("%s: cannot rename built-in %s", pos, found.Name)
+	case resolve.Undefined:
+		return nil, fmt.Errorf("%s: %s is undefined", pos, found.Name)
+	}
+	bind = m.Info().Origin(bind)
+	if bind.First.Name == newName {
+		return nil, nil
+	}
+	if bind.Scope == resolve.Global {
+		err = e.renameGlobal(m, bind, newName)
+	} else {
+		err = e.renameLocal(m, bind, newName, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e.result(), nil
+}
+
+// covers reports whether pos lies within the identifier id.
+func covers(id *syntax.Ident, pos syntax.Position) bool {
+	return pos.Line == id.NamePos.Line &&
+		id.NamePos.Col <= pos.Col && pos.Col < id.NamePos.Col+int32(len([]rune(id.Name)))
+}
+
+// renameGlobal renames the global variable bind of module m,
+// and its loads by other modules.
+func (e *editor) renameGlobal(m *index.Module, bind *resolve.Binding, newName string) error {
+	oldName := bind.First.Name
+	if err := e.renameLocal(m, bind, newName, false); err != nil {
+		return err
+	}
+	sym := e.ix.Lookup(m.Key, oldName)
+	if sym == nil {
+		return nil // not exported
+	}
+	for _, load := range e.ix.LoadersOf(sym) {
+		loader, stmt := load.From, load.Stmt()
+		if strings.HasPrefix(newName, "_") {
+			return fmt.Errorf("cannot rename %s to unexported %s: it is loaded at %s", oldName, newName, load.Pos)
+		}
+		for i, from := range stmt.From {
+			if from.Name != oldName {
+				continue
+			}
+			if stmt.To[i] != from {
+				e.rename(loader, from, newName) // load("m", x="old")
+				continue
+			}
+			local := loader.Info().Binding(from)
+			if local == nil {
+				continue
+			}
+			if err := e.renameLocal(loader, local, newName, true); err != nil {
+				return fmt.Errorf("in %s: %v", loader.Key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// renameLocal renames all the references to the variable bind of
+// module m, which is not a global of an Index.
+//
+// A load statement such as load("m", "x") that binds the variable
+// becomes load("m", y = "x"), unless the remote name is also to be
+// renamed, in which case it becomes load("m", "y").
+func (e *editor) renameLocal(m *index.Module, bind *resolve.Binding, newName string, remote bool) error {
+	info := m.Info()
+	if err := conflicts(info, bind, newName); err != nil {
+		return err
+	}
+	loadNames := make(map[*syntax.Ident]bool) // unaliased load names
+	syntax.Walk(m.Syntax(), func(n syntax.Node) bool {
+		if load, ok := n.(*syntax.LoadStmt); ok {
+			for i, id := range load.To {
+				loadNames[id] = id == load.From[i]
+			}
+		}
+		return true
+	})
+	f := e.file(m)
+	for _, id := range info.Refs(bind) {
+		if loadNames[id] && !remote {
+			e.insert(m, f.offset(id.NamePos)-1, newName+" = ")
+		} else {
+			e.rename(m, id, newName)
+		}
+	}
+	return nil
+}
+
+// conflicts reports an error if renaming the variable bind to newName
+// would conflict with a declaration or change the meaning of a
+// reference. The check is conservative.
+func conflicts(info *resolve.Info, bind *resolve.Binding, newName string) error {
+	block := info.BlockOf(bind)
+	if prev := block.Lookup(newName); prev != nil {
+		return fmt.Errorf("%s: %s is already declared in this block", prev.First.NamePos, newName)
+	}
+
+	// blockOf returns the innermost block containing the identifier,
+	// except that the name of a def belongs to the enclosing block.
+	defNames := make(map[*syntax.Ident]bool)
+	syntax.Walk(info.File, func(n syntax.Node) bool {
+		if def, ok := n.(*syntax.DefStmt); ok {
+			defNames[def.Name] = true
+		}
+		return true
+	})
+	blockOf := func(id *syntax.Ident) *resolve.Block {
+		b := info.Innermost(id.NamePos)
+		if defNames[id] && b.Parent != nil {
+			b = b.Parent
+		}
+		return b
+	}
+	within := func(b, outer *resolve.Block) bool {
+		for ; b != nil; b = b.Parent {
+			if b == outer {
+				return true
+			}
+		}
+		return false
+	}
+
+	// A reference to the variable must not be captured by
+	// a declaration of newName in an intervening block.
+	for _, id := range info.Refs(bind) {
+		for b := blockOf(id); b != nil && b != block; b = b.Parent {
+			if prev := b.Lookup(newName); prev != nil {
+				return fmt.Errorf("%s: reference to %s would be shadowed by %s declared at %s",
+					id.NamePos, id.Name, newName, prev.First.NamePos)
+			}
+		}
+	}
+
+	// A reference to an outer newName must not be captured
+	// by the renamed variable.
+	var err error
+	syntax.Walk(info.File, func(n syntax.Node) bool {
+		id, ok := n.(*syntax.Ident)
+		if !ok || id.Name != newName || err != nil {
+			return err == nil
+		}
+		other := info.Binding(id)
+		if other == nil || other.Scope == resolve.Undefined {
+			return true
+		}
+		outer := info.BlockOf(other) // nil for built-ins
+		if (outer == nil || outer != block && within(block, outer)) && within(blockOf(id), block) {
+			err = fmt.Errorf("%s: renamed variable would shadow reference to %s", id.NamePos, newName)
+		}
+		return true
+	})
+	return err
+}
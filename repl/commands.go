@@ -0,0 +1,194 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// commands documents the REPL's meta-commands, in the order of :help.
+var commands = []struct{ usage, doc string }{
+	{":load file.star", "execute the file and add its globals to the environment"},
+	{":env", "print the global environment"},
+	{":type expr", "print the type of the value of expr"},
+	{":doc expr", "print the documentation of the value of expr"},
+	{":time expr", "evaluate expr, and print its value, elapsed time, and execution steps"},
+	{":reset", "delete all globals"},
+	{":help", "print this message"},
+}
+
+// isCommand reports whether the line is a meta-command.
+func isCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), ":")
+}
+
+// command executes a meta-command, such as ":type x", printing its
+// output to out.
+func command(opts *syntax.FileOptions, thread *starlark.Thread, globals starlark.StringDict, line string, out io.Writer) error {
+	name, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	eval := func() (starlark.Value, error) {
+		if arg == "" {
+			return nil, fmt.Errorf("%s: missing expression", name)
+		}
+		return starlark.EvalOptions(opts, thread, "<stdin>", arg, globals)
+	}
+
+	switch name {
+	case ":load":
+		if arg == "" {
+			return fmt.Errorf(":load: missing file name")
+		}
+		loaded, err := starlark.ExecFileOptions(opts, thread, arg, nil, nil)
+		if err != nil {
+			return err
+		}
+		for name, v := range loaded {
+			globals[name] = v
+		}
+
+	case ":env":
+		for _, name := range globals.Keys() {
+			if !strings.HasPrefix(name, "_") {
+				fmt.Fprintf(out, "%s = %s\n", name, globals[name])
+			}
+		}
+
+	case ":type":
+		v, err := eval()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, v.Type())
+
+	case ":doc":
+		v, err := eval()
+		if err != nil {
+			return err
+		}
+		switch v := v.(type) {
+		case *starlark.Function:
+			fmt.Fprintln(out, signature(v))
+			if doc := cleanDoc(v.Doc()); doc != "" {
+				fmt.Fprintf(out, "\n%s\n", doc)
+			}
+		case *starlark.Builtin:
+			fmt.Fprintf(out, "built-in function %s\n", v.Name())
+		case starlark.HasAttrs:
+			fmt.Fprintf(out, "%s with attributes %s\n", v.Type(), strings.Join(v.AttrNames(), ", "))
+		default:
+			fmt.Fprintf(out, "%s has no documentation\n", v.Type())
+		}
+
+	case ":time":
+		steps := thread.ExecutionSteps()
+		start := time.Now()
+		v, err := eval()
+		elapsed := time.Since(start)
+		if err != nil {
+			return err
+		}
+		if v != starlark.None {
+			fmt.Fprintln(out, v)
+		}
+		fmt.Fprintf(out, "time: %v, steps: %d\n", elapsed, thread.ExecutionSteps()-steps)
+
+	case ":reset":
+		for name := range globals {
+			delete(globals, name)
+		}
+
+	case ":help":
+		for _, c := range commands {
+			fmt.Fprintf(out, "%-16s %s\n", c.usage, c.doc)
+		}
+
+	default:
+		return fmt.Errorf("unknown command %s (try :help)", name)
+	}
+	return nil
+}
+
+// signature returns the signature of a function, such as
+// "f(x, y=1, *args, z, **kwargs)".
+func signature(fn *starlark.Function) string {
+	param := func(i int, prefix string) string {
+		name, _ := fn.Param(i)
+		if dflt := fn.ParamDefault(i); dflt != nil {
+			return prefix + name + "=" + dflt.String()
+		}
+		return prefix + name
+	}
+
+	// Parameters appear in the order: positional, keyword-only,
+	// *args, **kwargs.
+	var params []string
+	n, kwonly := fn.NumParams(), fn.NumKwonlyParams()
+	if fn.HasVarargs() {
+		n--
+	}
+	if fn.HasKwargs() {
+		n--
+	}
+	for i := 0; i < n-kwonly; i++ {
+		params = append(params, param(i, ""))
+	}
+	if fn.HasVarargs() {
+		params = append(params, param(n, "*"))
+	} else if kwonly > 0 {
+		params = append(params, "*")
+	}
+	for i := n - kwonly; i < n; i++ {
+		params = append(params, param(i, ""))
+	}
+	if fn.HasKwargs() {
+		i := n
+		if fn.HasVarargs() {
+			i++
+		}
+		params = append(params, param(i, "**"))
+	}
+	return fn.Name() + "(" + strings.Join(params, ", ") + ")"
+}
+
+// cleanDoc removes the indentation common to the second and subsequent
+// lines of a doc string, and its leading and trailing blank lines.
+func cleanDoc(doc string) string {
+	lines := strings.Split(doc, "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " \t"); trimmed != "" {
+			if n := len(line) - len(trimmed); indent < 0 || n < indent {
+				indent = n
+			}
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if indent > 0 && len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+// historyFile returns the name of the file that holds the history of
+// REPL input, ~/.starlark_history, or "" if there is no home directory.
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".starlark_history")
+}
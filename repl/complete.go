@@ -0,0 +1,79 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package repl
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.starlark.net/starlark"
+)
+
+// A completer completes the name before the cursor: a global or
+// universal name, or, after a dot, an attribute of the value of the
+// preceding dotted name, such as json.enc or x.app. It implements
+// readline.AutoCompleter.
+type completer struct {
+	globals starlark.StringDict
+}
+
+func (c completer) Do(line []rune, pos int) (suffixes [][]rune, length int) {
+	// Find the dotted name before the cursor.
+	start := pos
+	for start > 0 && (isIdent(line[start-1]) || line[start-1] == '.') {
+		start--
+	}
+	names := strings.Split(string(line[start:pos]), ".")
+	partial := names[len(names)-1]
+	if r := []rune(names[0]); len(r) > 0 && unicode.IsDigit(r[0]) {
+		return nil, 0 // a number
+	}
+
+	var candidates []string
+	if len(names) == 1 {
+		candidates = append(c.globals.Keys(), starlark.Universe.Keys()...)
+	} else {
+		v := c.lookup(names[0])
+		for _, name := range names[1 : len(names)-1] {
+			v = attr(v, name)
+		}
+		x, ok := v.(starlark.HasAttrs)
+		if !ok {
+			return nil, 0
+		}
+		candidates = x.AttrNames()
+	}
+
+	sort.Strings(candidates)
+	for i, name := range candidates {
+		if strings.HasPrefix(name, partial) && (i == 0 || name != candidates[i-1]) {
+			suffixes = append(suffixes, []rune(name[len(partial):]))
+		}
+	}
+	return suffixes, len([]rune(partial))
+}
+
+// lookup returns the value of the global or universal name, or nil.
+func (c completer) lookup(name string) starlark.Value {
+	if v, ok := c.globals[name]; ok {
+		return v
+	}
+	return starlark.Universe[name]
+}
+
+// attr returns the named attribute of v, or nil.
+func attr(v starlark.Value, name string) starlark.Value {
+	if x, ok := v.(starlark.HasAttrs); ok {
+		if v, err := x.Attr(name); err == nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -1,7 +1,9 @@
 // Package repl provides a read/eval/print loop for Starlark.
 //
-// It supports readline-style command editing,
-// and interrupts through Control-C.
+// It supports readline-style command editing, with a history that
+// persists in the file ~/.starlark_history, tab completion of global
+// and universal names and of attributes after a dot, and interrupts
+// through Control-C.
 //
 // If an input line can be parsed as an expression,
 // the REPL parses and evaluates it and prints its result.
@@ -10,6 +12,16 @@
 // expression. If the input still cannot be parsed as an expression,
 // the REPL parses and executes it as a file (a list of statements),
 // for side effects.
+//
+// A line beginning with a colon is a meta-command:
+//
+//	:load file.star   execute the file and add its globals to the environment
+//	:env              print the global environment
+//	:type expr        print the type of the value of expr
+//	:doc expr         print the documentation of the value of expr
+//	:time expr        evaluate expr, and print its value, elapsed time, and execution steps
+//	:reset            delete all globals
+//	:help             print a summary of the meta-commands
 package repl // import "go.starlark.net/repl"
 
 import (
@@ -44,7 +56,11 @@ func REPLOptions(opts *syntax.FileOptions, thread *starlark.Thread, globals star
 	signal.Notify(interrupted, os.Interrupt)
 	defer signal.Stop(interrupted)
 
-	rl, err := readline.New(">>> ")
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       ">>> ",
+		HistoryFile:  historyFile(),
+		AutoComplete: completer{globals},
+	})
 	if err != nil {
 		PrintError(err)
 		return
@@ -87,7 +103,13 @@ func rep(opts *syntax.FileOptions, rl *readline.Instance, thread *starlark.Threa
 
 	// readline returns EOF, ErrInterrupted, or a line including "\n".
 	rl.SetPrompt(">>> ")
+	var first []byte // the first line, already read
 	readline := func() ([]byte, error) {
+		if first != nil {
+			line := first
+			first = nil
+			return line, nil
+		}
 		line, err := rl.Readline()
 		rl.SetPrompt("... ")
 		if err != nil {
@@ -105,6 +127,22 @@ func rep(opts *syntax.FileOptions, rl *readline.Instance, thread *starlark.Threa
 	opts2.LoadBindsGlobally = true
 	opts = &opts2
 
+	// A first line beginning with a colon is a meta-command.
+	first, err := readline()
+	if err != nil {
+		if eof {
+			return io.EOF
+		}
+		PrintError(err)
+		return nil
+	}
+	if isCommand(string(first)) {
+		if err := command(opts, thread, globals, string(first), os.Stdout); err != nil {
+			PrintError(err)
+		}
+		return nil
+	}
+
 	// parse
 	f, err := opts.ParseCompoundStmt("<stdin>", readline)
 	if err != nil {
@@ -187,9 +225,10 @@ func MakeLoadOptions(opts *syntax.FileOptions) func(thread *starlark.Thread, mod
 			// Add a placeholder to indicate "load in progress".
 			cache[module] = nil
 
-			// Load it.
-			thread := &starlark.Thread{Name: "exec " + module, Load: thread.Load}
-			globals, err := starlark.ExecFileOptions(opts, thread, module, nil, nil)
+			// Load it, with the profiler and tracer of the loading thread.
+			child := &starlark.Thread{Name: "exec " + module, Load: thread.Load, Tracer: thread.Tracer}
+			child.SetProfiler(thread.Profiler())
+			globals, err := starlark.ExecFileOptions(opts, child, module, nil, nil)
 			e = &entry{globals, err}
 
 			// Update the cache.
@@ -0,0 +1,100 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package repl
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+func TestComplete(t *testing.T) {
+	globals := starlark.StringDict{
+		"items":  starlark.NewList(nil),
+		"itemz":  starlark.None,
+		"module": starlark.NewDict(0),
+	}
+	c := completer{globals}
+	for _, test := range []struct {
+		line string
+		want string // suffixes, space-separated
+	}{
+		{"ite", "ms mz"},
+		{"x = ite", "ms mz"},
+		{"len", ""},
+		{"le", "n"},
+		{"ma", "x"},
+		{"items.app", "end"},
+		{"items.", "append clear extend index insert pop remove"},
+		{"f(module.ke", "ys"},
+		{"itemz.", ""},
+		{"nosuch.x", ""},
+		{"1.", ""},
+	} {
+		suffixes, _ := c.Do([]rune(test.line), len([]rune(test.line)))
+		var got []string
+		for _, s := range suffixes {
+			got = append(got, string(s))
+		}
+		if strings.Join(got, " ") != test.want {
+			t.Errorf("complete %q: got %q, want %q", test.line, got, test.want)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.star")
+	if err := os.WriteFile(lib, []byte(`
+def square(x):
+    """Returns the square of x."""
+    return x * x
+
+def join(x, sep=", ", *rest, last=None, **kwargs):
+    """Joins its arguments.
+
+    The arguments are strings.
+    """
+    pass
+
+answer = 42
+`), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	thread := new(starlark.Thread)
+	globals := make(starlark.StringDict)
+	opts := syntax.LegacyFileOptions()
+	for _, test := range []struct {
+		line, want string // want is a regular expression
+	}{
+		{":load " + lib, ``},
+		{":env", `^answer = 42\njoin = <function join>\nsquare = <function square>\n$`},
+		{":type answer", `^int\n$`},
+		{":type", `:type: missing expression`},
+		{":doc square", `^square\(x\)\n\nReturns the square of x.\n$`},
+		{":doc join", `^join\(x, sep=", ", \*rest, last=None, \*\*kwargs\)\n\nJoins its arguments.\n\nThe arguments are strings.\n$`},
+		{":doc len", `^built-in function len\n$`},
+		{":doc 1", `^int has no documentation\n$`},
+		{":time square(answer)", `^1764\ntime: .*, steps: [1-9][0-9]*\n$`},
+		{":reset", ``},
+		{":env", `^$`},
+		{":help", `^:load file.star +execute the file`},
+		{":nosuch", `unknown command :nosuch \(try :help\)`},
+	} {
+		var out strings.Builder
+		if err := command(opts, thread, globals, test.line, &out); err != nil {
+			out.WriteString(err.Error())
+		}
+		if !regexp.MustCompile(test.want).MatchString(out.String()) {
+			t.Errorf("%s: got %q, want match for %q", test.line, out.String(), test.want)
+		}
+	}
+}
@@ -66,6 +66,9 @@ type Function struct {
 	Params []syntax.Expr   // param = ident | ident=expr | * | *ident | **ident
 	Body   []syntax.Stmt   // contains synthetic 'return expr' for lambda
 
+	ParamTypes []syntax.Expr // type annotations of Params (see syntax.DefStmt), or nil
+	ResultType syntax.Expr   // type annotation of result, or nil
+
 	HasVarargs      bool       // whether params includes *args (convenience)
 	HasKwargs       bool       // whether params includes **kwargs (convenience)
 	NumKwonlyParams int        // number of keyword-only optional parameters
@@ -0,0 +1,339 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resolve
+
+// This file defines Info, which presents the resolver's results for a
+// file in a form suitable for tools such as cross-referencers and
+// refactoring tools.
+
+import "go.starlark.net/syntax"
+
+// An Info records the results of resolving a file in a form suited to
+// queries: the variable denoted by each identifier, all the references
+// to each variable, and the tree of lexical blocks.
+//
+// A variable is represented by the Binding of its declaration. An
+// identifier that refers to a variable of an enclosing function has a
+// distinct Binding of scope Free; Origin maps it to the declaration.
+// All references to a predeclared or universal name share a Binding,
+// which is not the declaration of any identifier.
+type Info struct {
+	File *syntax.File
+	Root *Block // the file block
+
+	defs        map[*syntax.Ident]bool       // binding occurrences
+	refs        map[*Binding][]*syntax.Ident // occurrences of each variable, by origin, in order
+	blocks      map[*Binding]*Block          // block declaring each variable
+	funcs       map[*Function]*Block         // block of each function
+	predeclared map[string]*Binding          // binding of each predeclared or universal name
+}
+
+// A Block is a lexical block: the file block, a function, or a
+// comprehension.
+type Block struct {
+	// Node is the syntax node of the block: a *syntax.File,
+	// *syntax.DefStmt, *syntax.LambdaExpr, or *syntax.Comprehension.
+	Node syntax.Node
+
+	Parent   *Block   // enclosing block, or nil for the file block
+	Children []*Block // nested blocks, in order of position
+
+	// Bindings lists the variables declared in this block, in
+	// order of their first binding. Global variables, and the
+	// file-local variables bound by load statements, are declared
+	// in the file block.
+	Bindings []*Binding
+
+	info *Info
+}
+
+// Span returns the start and end position of the block.
+// The span of the file block is that of its statements.
+func (b *Block) Span() (start, end syntax.Position) { return b.Node.Span() }
+
+// Function returns the function of a def or lambda block, or nil.
+func (b *Block) Function() *Function {
+	switch n := b.Node.(type) {
+	case *syntax.DefStmt:
+		fn, _ := n.Function.(*Function)
+		return fn
+	case *syntax.LambdaExpr:
+		fn, _ := n.Function.(*Function)
+		return fn
+	}
+	return nil
+}
+
+// FreeVars returns the variables of enclosing functions that are
+// referenced by the function of a def or lambda block, or by a
+// function nested within it; it returns nil for other blocks.
+// Each variable is represented by its declaring Binding.
+func (b *Block) FreeVars() []*Binding {
+	fn := b.Function()
+	if fn == nil || len(fn.FreeVars) == 0 {
+		return nil
+	}
+	vars := make([]*Binding, len(fn.FreeVars))
+	for i, v := range fn.FreeVars {
+		vars[i] = b.info.Origin(v)
+	}
+	return vars
+}
+
+// Lookup returns the variable of the given name declared in this
+// block, or nil if there is none. It does not consult enclosing blocks.
+func (b *Block) Lookup(name string) *Binding {
+	for _, bind := range b.Bindings {
+		if bind.First.Name == name {
+			return bind
+		}
+	}
+	return nil
+}
+
+// contains reports whether pos lies within the block.
+func (b *Block) contains(pos syntax.Position) bool {
+	if b.Parent == nil {
+		return true // file block
+	}
+	start, end := b.Span()
+	return !before(pos, start) && before(pos, end)
+}
+
+func before(p, q syntax.Position) bool {
+	return p.Line < q.Line || p.Line == q.Line && p.Col < q.Col
+}
+
+// NewInfo returns an Info for a file that has been resolved by File or
+// REPLChunk. If resolution failed, the Info describes only the
+// identifiers that were successfully resolved.
+func NewInfo(file *syntax.File) *Info {
+	info := &Info{
+		File:        file,
+		defs:        make(map[*syntax.Ident]bool),
+		refs:        make(map[*Binding][]*syntax.Ident),
+		blocks:      make(map[*Binding]*Block),
+		funcs:       make(map[*Function]*Block),
+		predeclared: make(map[string]*Binding),
+	}
+	info.Root = &Block{Node: file, info: info}
+
+	// Record the binding occurrences of identifiers.
+	var lhs func(e syntax.Expr)
+	lhs = func(e syntax.Expr) {
+		switch e := e.(type) {
+		case *syntax.Ident:
+			info.defs[e] = true
+		case *syntax.ParenExpr:
+			lhs(e.X)
+		case *syntax.TupleExpr:
+			for _, x := range e.List {
+				lhs(x)
+			}
+		case *syntax.ListExpr:
+			for _, x := range e.List {
+				lhs(x)
+			}
+		}
+	}
+	params := func(params []syntax.Expr) {
+		for _, param := range params {
+			switch param := param.(type) {
+			case *syntax.BinaryExpr: // x=default
+				lhs(param.X)
+			case *syntax.UnaryExpr: // *args, **kwargs
+				if param.X != nil {
+					lhs(param.X)
+				}
+			default:
+				lhs(param)
+			}
+		}
+	}
+	syntax.Walk(file, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.AssignStmt:
+			if n.Op == syntax.EQ {
+				lhs(n.LHS)
+			}
+		case *syntax.DefStmt:
+			info.defs[n.Name] = true
+			params(n.Params)
+		case *syntax.LambdaExpr:
+			params(n.Params)
+		case *syntax.ForStmt:
+			lhs(n.Vars)
+		case *syntax.ForClause:
+			lhs(n.Vars)
+		case *syntax.LoadStmt:
+			for _, id := range n.To {
+				info.defs[id] = true
+			}
+		case *syntax.TryStmt:
+			if n.Name != nil {
+				info.defs[n.Name] = true
+			}
+		}
+		return true
+	})
+
+	// Build the block tree, and record the occurrences
+	// of each variable and the block that declares it.
+	var stack []syntax.Node // nodes on the path from the root
+	blocks := []*Block{info.Root}
+	declare := func(id *syntax.Ident, b *Block) {
+		bind, ok := id.Binding.(*Binding)
+		if !ok || bind.First != id {
+			return
+		}
+		switch bind.Scope {
+		case Global:
+			b = info.Root
+		case Local, Cell:
+		default:
+			return
+		}
+		if _, ok := info.blocks[bind]; !ok {
+			info.blocks[bind] = b
+			b.Bindings = append(b.Bindings, bind)
+		}
+	}
+	syntax.Walk(file, func(n syntax.Node) bool {
+		if n == nil {
+			if len(blocks) > 1 && blocks[len(blocks)-1].Node == stack[len(stack)-1] {
+				blocks = blocks[:len(blocks)-1]
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+
+		enclosing := blocks[len(blocks)-1]
+		switch n := n.(type) {
+		case *syntax.DefStmt, *syntax.LambdaExpr, *syntax.Comprehension:
+			if def, ok := n.(*syntax.DefStmt); ok {
+				declare(def.Name, enclosing) // a function's name belongs to the enclosing block
+			}
+			b := &Block{Node: n, Parent: enclosing, info: info}
+			enclosing.Children = append(enclosing.Children, b)
+			if fn := b.Function(); fn != nil {
+				info.funcs[fn] = b
+			}
+			blocks = append(blocks, b)
+
+		case *syntax.Ident:
+			bind, ok := n.Binding.(*Binding)
+			if !ok || bind.Scope == Undefined {
+				break
+			}
+			if info.defs[n] {
+				declare(n, enclosing)
+			}
+			if bind.Scope == Predeclared || bind.Scope == Universal {
+				if _, ok := info.predeclared[n.Name]; !ok {
+					info.predeclared[n.Name] = bind
+				}
+			}
+			origin := info.Origin(bind)
+			refs := info.refs[origin]
+			if len(refs) > 0 && refs[len(refs)-1] == n {
+				break // load("m", "x") uses the same Ident for From and To
+			}
+			info.refs[origin] = append(refs, n)
+		}
+		return true
+	})
+	return info
+}
+
+// Binding returns the binding of the identifier id, or nil if id was
+// not resolved. An identifier that is the name of a field or keyword
+// argument, as in x.f or f(k=v), has no binding.
+func (info *Info) Binding(id *syntax.Ident) *Binding {
+	bind, _ := id.Binding.(*Binding)
+	return bind
+}
+
+// Origin returns the declaring Binding of the variable denoted by
+// bind. For a free variable, this is the Binding of the local variable
+// of the enclosing function; for all other bindings, it is bind itself.
+func (info *Info) Origin(bind *Binding) *Binding {
+	if bind.Scope == Free {
+		if origin, ok := bind.First.Binding.(*Binding); ok {
+			return origin
+		}
+	}
+	return bind
+}
+
+// Defs returns the identifiers that bind the variable bind, in order
+// of position: the identifiers on the left side of assignments, and the
+// names of functions, parameters, loop variables, loads, and errors
+// caught by except clauses.
+func (info *Info) Defs(bind *Binding) []*syntax.Ident {
+	var defs []*syntax.Ident
+	for _, id := range info.refs[info.Origin(bind)] {
+		if info.defs[id] {
+			defs = append(defs, id)
+		}
+	}
+	return defs
+}
+
+// Uses returns the identifiers that refer to the variable bind
+// without binding it, in order of position. (The left operand of an
+// augmented assignment such as x += 1 is considered a use.)
+func (info *Info) Uses(bind *Binding) []*syntax.Ident {
+	var uses []*syntax.Ident
+	for _, id := range info.refs[info.Origin(bind)] {
+		if !info.defs[id] {
+			uses = append(uses, id)
+		}
+	}
+	return uses
+}
+
+// Refs returns all identifiers that denote the variable bind,
+// both binding occurrences and uses, in order of position.
+// Renaming a variable entails renaming each of them.
+func (info *Info) Refs(bind *Binding) []*syntax.Ident {
+	return info.refs[info.Origin(bind)]
+}
+
+// IsDef reports whether the identifier id is a binding occurrence.
+func (info *Info) IsDef(id *syntax.Ident) bool { return info.defs[id] }
+
+// BlockOf returns the block that declares the variable bind,
+// or nil for predeclared and universal names.
+func (info *Info) BlockOf(bind *Binding) *Block { return info.blocks[info.Origin(bind)] }
+
+// FunctionBlock returns the block of the function fn.
+func (info *Info) FunctionBlock(fn *Function) *Block { return info.funcs[fn] }
+
+// Innermost returns the innermost block containing pos.
+func (info *Info) Innermost(pos syntax.Position) *Block {
+	b := info.Root
+outer:
+	for {
+		for _, child := range b.Children {
+			if child.contains(pos) {
+				b = child
+				continue outer
+			}
+		}
+		return b
+	}
+}
+
+// Predeclared returns the predeclared and universal names referenced by
+// the file, mapped to the Binding shared by all references to each
+// name, whose Scope is either Predeclared or Universal.
+func (info *Info) Predeclared() map[string]*Binding {
+	names := make(map[string]*Binding, len(info.predeclared))
+	for name, bind := range info.predeclared {
+		names[name] = bind
+	}
+	return names
+}
@@ -0,0 +1,189 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package resolve_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+const infoSrc = `load("lib.star", "helper")
+
+x = 1
+
+def f(a, b=U):
+    y = a + x
+    def g():
+        return y + helper
+    y += 1
+    return [z * y for z in g()]
+
+h = lambda: f(M)
+`
+
+func resolveInfo(t *testing.T, src string) *resolve.Info {
+	t.Helper()
+	file, err := syntax.Parse("info.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resolve.File(file, isPredeclared, isUniversal); err != nil {
+		t.Fatal(err)
+	}
+	return resolve.NewInfo(file)
+}
+
+// positions formats the line:col positions of a list of identifiers.
+func positions(ids []*syntax.Ident) string {
+	var buf strings.Builder
+	for i, id := range ids {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%d:%d", id.NamePos.Line, id.NamePos.Col)
+	}
+	return buf.String()
+}
+
+func TestInfoBlocks(t *testing.T) {
+	info := resolveInfo(t, infoSrc)
+
+	var buf strings.Builder
+	var visit func(b *resolve.Block, depth int)
+	visit = func(b *resolve.Block, depth int) {
+		start, end := b.Span()
+		fmt.Fprintf(&buf, "%s%T %d:%d-%d:%d", strings.Repeat("  ", depth), b.Node, start.Line, start.Col, end.Line, end.Col)
+		for _, bind := range b.Bindings {
+			fmt.Fprintf(&buf, " %s(%s)", bind.First.Name, bind.Scope)
+		}
+		if free := b.FreeVars(); free != nil {
+			buf.WriteString(" free:")
+			for _, v := range free {
+				fmt.Fprintf(&buf, " %s@%d", v.First.Name, v.First.NamePos.Line)
+			}
+		}
+		buf.WriteByte('\n')
+		for _, child := range b.Children {
+			if child.Parent != b {
+				t.Errorf("%T: wrong parent", child.Node)
+			}
+			visit(child, depth+1)
+		}
+	}
+	visit(info.Root, 0)
+	got := buf.String()
+	// (File-local variables, such as helper, are captured by
+	// closures just like the locals of an enclosing function.)
+	const want = `*syntax.File 1:1-12:17 helper(cell) x(global) f(global) h(global)
+  *syntax.DefStmt 5:1-10:32 a(local) b(local) y(cell) g(local) free: helper@1
+    *syntax.DefStmt 7:5-8:26 free: y@6 helper@1
+    *syntax.Comprehension 10:12-10:32 z(local)
+  *syntax.LambdaExpr 12:5-12:17
+`
+	if got != want {
+		t.Errorf("got blocks:\n%s\nwant:\n%s", got, want)
+	}
+
+	for _, test := range []struct {
+		line, col int32
+		want      string // position of innermost block
+	}{
+		{1, 1, "1:1"},
+		{5, 1, "5:1"},
+		{6, 9, "5:1"},
+		{8, 16, "7:5"},
+		{10, 13, "10:12"},
+		{11, 1, "1:1"},
+		{12, 14, "12:5"},
+	} {
+		pos := syntax.MakePosition(nil, test.line, test.col)
+		start, _ := info.Innermost(pos).Span()
+		if got := fmt.Sprintf("%d:%d", start.Line, start.Col); got != test.want {
+			t.Errorf("Innermost(%d:%d) = block at %s, want %s", test.line, test.col, got, test.want)
+		}
+	}
+}
+
+func TestInfoRefs(t *testing.T) {
+	info := resolveInfo(t, infoSrc)
+	fblock := info.Root.Children[0]
+	if info.FunctionBlock(fblock.Function()) != fblock {
+		t.Errorf("FunctionBlock(f) is not the block of f")
+	}
+
+	for _, test := range []struct {
+		block      *resolve.Block
+		name       string
+		defs, uses string
+	}{
+		{info.Root, "helper", "1:19", "8:20"},
+		{info.Root, "x", "3:1", "6:13"},
+		{info.Root, "f", "5:5", "12:13"},
+		{fblock, "a", "5:7", "6:9"},
+		{fblock, "b", "5:10", ""},
+		{fblock, "y", "6:5", "8:16 9:5 10:17"}, // y += 1 is a use
+		{fblock.Children[1], "z", "10:23", "10:13"},
+	} {
+		bind := test.block.Lookup(test.name)
+		if bind == nil {
+			t.Errorf("Lookup(%s) failed", test.name)
+			continue
+		}
+		if got := positions(info.Defs(bind)); got != test.defs {
+			t.Errorf("Defs(%s) = %s, want %s", test.name, got, test.defs)
+		}
+		if got := positions(info.Uses(bind)); got != test.uses {
+			t.Errorf("Uses(%s) = %s, want %s", test.name, got, test.uses)
+		}
+		if got := len(info.Refs(bind)); got != len(info.Defs(bind))+len(info.Uses(bind)) {
+			t.Errorf("len(Refs(%s)) = %d", test.name, got)
+		}
+		if info.BlockOf(bind) != test.block {
+			t.Errorf("BlockOf(%s) is wrong", test.name)
+		}
+	}
+
+	// A reference to y within g has a distinct Binding of scope Free,
+	// whose origin is the variable of f.
+	y := info.Uses(fblock.Lookup("y"))[0]
+	if bind := info.Binding(y); bind.Scope != resolve.Free {
+		t.Errorf("Binding(y) has scope %s, want free", bind.Scope)
+	} else if info.Origin(bind) != fblock.Lookup("y") {
+		t.Errorf("Origin(y) is not the local of f")
+	}
+	if !info.IsDef(fblock.Lookup("y").First) || info.IsDef(y) {
+		t.Errorf("IsDef is wrong")
+	}
+
+	// Predeclared and universal names.
+	var names []string
+	for name, bind := range info.Predeclared() {
+		names = append(names, fmt.Sprintf("%s=%s:%s", name, bind.Scope, positions(info.Uses(bind))))
+		if info.BlockOf(bind) != nil {
+			t.Errorf("BlockOf(%s) != nil", name)
+		}
+	}
+	sort.Strings(names)
+	if got, want := strings.Join(names, " "), "M=predeclared:12:15 U=universal:5:12"; got != want {
+		t.Errorf("Predeclared() = %s, want %s", got, want)
+	}
+}
+
+func TestInfoFieldNames(t *testing.T) {
+	info := resolveInfo(t, "x = U(k = 1).k\n")
+	call := info.File.Stmts[0].(*syntax.AssignStmt).RHS.(*syntax.DotExpr)
+	if bind := info.Binding(call.Name); bind != nil {
+		t.Errorf("Binding(.k) = %v, want nil", bind)
+	}
+	k := call.X.(*syntax.CallExpr).Args[0].(*syntax.BinaryExpr).X.(*syntax.Ident)
+	if bind := info.Binding(k); bind != nil {
+		t.Errorf("Binding(k=) = %v, want nil", bind)
+	}
+}
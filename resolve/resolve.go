@@ -507,6 +507,10 @@ func (r *resolver) stmt(stmt syntax.Stmt) {
 	case *syntax.ExprStmt:
 		r.expr(stmt.X)
 
+	case *syntax.BadStmt:
+		// Produced only by a parser recovering from errors.
+		r.errorf(stmt.From, "invalid syntax")
+
 	case *syntax.BranchStmt:
 		if r.loops == 0 && (stmt.Token == syntax.BREAK || stmt.Token == syntax.CONTINUE) {
 			r.errorf(stmt.TokenPos, "%s not in a loop", stmt.Token)
@@ -523,17 +527,25 @@ func (r *resolver) stmt(stmt syntax.Stmt) {
 		r.ifstmts--
 
 	case *syntax.AssignStmt:
+		if stmt.Type != nil && !r.options.TypeAnnotations {
+			r.errorf(stmt.Colon, doesnt+"support type annotations")
+		}
 		r.expr(stmt.RHS)
 		isAugmented := stmt.Op != syntax.EQ
 		r.assign(stmt.LHS, isAugmented)
 
 	case *syntax.DefStmt:
+		if (stmt.ParamTypes != nil || stmt.ResultType != nil) && !r.options.TypeAnnotations {
+			r.errorf(stmt.Def, doesnt+"support type annotations")
+		}
 		r.bind(stmt.Name)
 		fn := &Function{
-			Name:   stmt.Name.Name,
-			Pos:    stmt.Def,
-			Params: stmt.Params,
-			Body:   stmt.Body,
+			Name:       stmt.Name.Name,
+			Pos:        stmt.Def,
+			Params:     stmt.Params,
+			Body:       stmt.Body,
+			ParamTypes: stmt.ParamTypes,
+			ResultType: stmt.ResultType,
 		}
 		stmt.Function = fn
 		r.function(fn, stmt.Def)
@@ -561,6 +573,21 @@ func (r *resolver) stmt(stmt syntax.Stmt) {
 		r.stmts(stmt.Body)
 		r.loops--
 
+	case *syntax.TryStmt:
+		// A try statement is permitted at top level even without
+		// TopLevelControl, so that a load statement may be guarded.
+		if !r.options.Exceptions {
+			r.errorf(stmt.Try, doesnt+"support try statements")
+		}
+		r.stmts(stmt.Body)
+		r.ifstmts++
+		if stmt.Name != nil {
+			r.bind(stmt.Name)
+		}
+		r.stmts(stmt.Except)
+		r.stmts(stmt.Finally)
+		r.ifstmts--
+
 	case *syntax.ReturnStmt:
 		if r.container().function == nil {
 			r.errorf(stmt.Return, "return statement not within a function")
@@ -653,6 +680,18 @@ func (r *resolver) expr(e syntax.Expr) {
 
 	case *syntax.Literal:
 
+	case *syntax.FStringExpr:
+		if !r.options.FStrings {
+			r.errorf(e.TokenPos, doesnt+"support f-strings")
+		}
+		for _, field := range e.Fields {
+			r.expr(field.X)
+		}
+
+	case *syntax.BadExpr:
+		// Produced only by a parser recovering from errors.
+		r.errorf(e.From, "invalid syntax")
+
 	case *syntax.ListExpr:
 		for _, x := range e.List {
 			r.expr(x)
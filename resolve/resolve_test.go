@@ -23,6 +23,9 @@ func getOptions(src string) *syntax.FileOptions {
 		GlobalReassign:    option(src, "globalreassign"),
 		LoadBindsGlobally: option(src, "loadbindsglobally"),
 		Recursion:         option(src, "recursion"),
+		TypeAnnotations:   option(src, "typeannotations"),
+		FStrings:          option(src, "fstrings"),
+		Exceptions:        option(src, "exceptions"),
 	}
 }
 
@@ -15,6 +15,7 @@ import (
 	"go.starlark.net/lib/json"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarktest"
+	"go.starlark.net/syntax"
 )
 
 func BenchmarkStarlark(b *testing.B) {
@@ -168,3 +169,53 @@ func BenchmarkProgram(b *testing.B) {
 		}
 	})
 }
+
+// BenchmarkCall measures the cost of calls to Starlark functions,
+// which should not allocate once the thread's arena is warm.
+// (Before locals were allocated from the arena, each call cost
+// 1 alloc, or 3 with keyword arguments, and was about twice as slow.)
+func BenchmarkCall(b *testing.B) {
+	const src = `
+def leaf(x, y = 0):
+    return x
+
+def positional(n):
+    for i in range(n):
+        leaf(i, i)
+
+def keywords(n):
+    for i in range(n):
+        leaf(x = i, y = i)
+
+def descend(depth):
+    if depth == 0:
+        return 0
+    return descend(depth - 1) + 1
+
+def recursive(n):
+    for _ in range(n):
+        descend(20)
+
+def closures(n):
+    k = 1
+    def add(x):
+        return x + k
+    for i in range(n):
+        add(i)
+`
+	opts := &syntax.FileOptions{Recursion: true}
+	thread := new(starlark.Thread)
+	globals, err := starlark.ExecFileOptions(opts, thread, "call.star", src, nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, name := range []string{"positional", "keywords", "recursive", "closures"} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			_, err := starlark.Call(thread, globals[name], starlark.Tuple{starlark.MakeInt(b.N)}, nil)
+			if err != nil {
+				reportEvalError(b, err)
+			}
+		})
+	}
+}
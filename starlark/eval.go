@@ -5,13 +5,17 @@
 package starlark
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"math/big"
 	"math/bits"
+	"os"
 	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unicode"
@@ -19,6 +23,7 @@ import (
 
 	"go.starlark.net/internal/compile"
 	"go.starlark.net/internal/spell"
+	"go.starlark.net/internal/types"
 	"go.starlark.net/resolve"
 	"go.starlark.net/syntax"
 )
@@ -31,8 +36,20 @@ type Thread struct {
 	Name string
 
 	// stack is the stack of (internal) call frames.
+	// Its slack portion holds a freelist of empty frames.
 	stack []*frame
 
+	// values is an arena from which the interpreter allocates the
+	// locals and operand stack of each call to a Starlark function,
+	// in LIFO order; nvalues is the number of elements in use.
+	// Similarly, pairs is an arena for the keyword arguments
+	// passed by the CALL instructions to Starlark functions.
+	// See allocValues.
+	values  []Value
+	nvalues int
+	pairs   []Tuple
+	npairs  int
+
 	// Print is the client-supplied implementation of the Starlark
 	// 'print' function. If nil, fmt.Fprintln(os.Stderr, msg) is
 	// used instead.
@@ -43,9 +60,24 @@ type Thread struct {
 	// module environment or error.
 	// The error message need not include the module name.
 	//
-	// See example_test.go for some example implementations of Load.
+	// Loader provides a standard concurrent implementation of Load;
+	// see example_test.go for some simpler example implementations.
 	Load func(thread *Thread, module string) (StringDict, error)
 
+	// Interner, if non-nil, is used by built-in functions that
+	// create many strings, such as json.decode, to share values
+	// for repeated strings. It may be shared by many threads.
+	Interner *Interner
+
+	// Tracer, if non-nil, is notified of the start and end of each
+	// call, load, and top-level statement executed by the thread.
+	// See ChromeTracer for a Tracer that records a timeline.
+	Tracer Tracer
+
+	// Hooks, if non-nil, holds functions that observe, and may
+	// answer, each call of a function or built-in by the thread.
+	Hooks *CallHooks
+
 	// OnMaxSteps is called when the thread reaches the limit set by SetMaxExecutionSteps.
 	// The default behavior is to call thread.Cancel("too many steps").
 	OnMaxSteps func(thread *Thread)
@@ -61,12 +93,24 @@ type Thread struct {
 	// cancelReason records the reason from the first call to Cancel.
 	cancelReason atomic.Pointer[string]
 
+	// cancelHooks are functions called by Cancel, such as those
+	// that propagate cancellation to threads started by a Loader.
+	cancelMu    sync.Mutex
+	cancelHooks map[*func(string)]bool
+
 	// locals holds arbitrary "thread-local" Go values belonging to the client.
 	// They are accessible to the client but not to any Starlark program.
 	locals map[string]any
 
+	// profiler is the thread's profiler, if any (see SetProfiler);
 	// proftime holds the accumulated execution time since the last profile event.
+	profiler atomic.Pointer[Profiler]
 	proftime time.Duration
+
+	// profAllocs is set while the current profiler span records
+	// allocations, which are accumulated in allocSites.
+	profAllocs bool
+	allocSites []allocSite
 }
 
 // ExecutionSteps returns the current value of Steps.
@@ -103,6 +147,51 @@ func (thread *Thread) Uncancel() {
 func (thread *Thread) Cancel(reason string) {
 	// Atomically set cancelReason, preserving earlier reason if any.
 	thread.cancelReason.CompareAndSwap(nil, &reason)
+
+	thread.cancelMu.Lock()
+	hooks := make([]*func(string), 0, len(thread.cancelHooks))
+	for hook := range thread.cancelHooks {
+		hooks = append(hooks, hook)
+	}
+	thread.cancelMu.Unlock()
+	for _, hook := range hooks {
+		(*hook)(reason)
+	}
+}
+
+// onCancel arranges for f to be called when the thread is cancelled,
+// or immediately if it has been cancelled already. It returns a
+// function that removes the hook.
+func (thread *Thread) onCancel(f func(reason string)) (remove func()) {
+	hook := &f
+	thread.cancelMu.Lock()
+	if thread.cancelHooks == nil {
+		thread.cancelHooks = make(map[*func(string)]bool)
+	}
+	thread.cancelHooks[hook] = true
+	thread.cancelMu.Unlock()
+
+	if reason := thread.cancelReason.Load(); reason != nil {
+		f(*reason)
+	}
+	return func() {
+		thread.cancelMu.Lock()
+		delete(thread.cancelHooks, hook)
+		thread.cancelMu.Unlock()
+	}
+}
+
+// remainingSteps returns the number of steps the thread may execute
+// before reaching the limit set by SetMaxExecutionSteps, if any.
+// The result is at least 1 if there is a limit.
+func (thread *Thread) remainingSteps() (uint64, bool) {
+	if thread.maxSteps == 0 || thread.maxSteps == math.MaxUint64 {
+		return 0, false // no limit
+	}
+	if thread.Steps >= thread.maxSteps {
+		return 1, true
+	}
+	return thread.maxSteps - thread.Steps, true
 }
 
 // SetLocal sets the thread-local value associated with the specified key.
@@ -189,7 +278,8 @@ type frame struct {
 	callable  Callable // current function (or toplevel) or built-in
 	pc        uint32   // program counter (Starlark frames only)
 	locals    []Value  // local variables (Starlark frames only)
-	spanStart int64    // start time of current profiler span
+	spanStart int64    // start time (or steps) of current profiler span
+	spanGen   uint64   // generation of the profiler run of current span
 }
 
 // Position returns the source position of the current point of execution in this frame.
@@ -317,6 +407,11 @@ func (prog *Program) Load(i int) (string, syntax.Position) {
 	return id.Name, id.Pos
 }
 
+// SourceDigest returns the SHA-256 digest of the source file from
+// which this program was compiled, or nil if it is not known, as when
+// the program was produced by FileProgram from a syntax tree.
+func (prog *Program) SourceDigest() []byte { return prog.compiled.SourceDigest }
+
 // WriteTo writes the compiled module to the specified output stream.
 func (prog *Program) Write(out io.Writer) error {
 	data := prog.compiled.Encode()
@@ -324,6 +419,43 @@ func (prog *Program) Write(out io.Writer) error {
 	return err
 }
 
+// A ProgramHeader describes a compiled program saved by Program.Write.
+// Its fields may be read by ReadProgramHeader without decoding the
+// program, allowing a cache of compiled programs to validate
+// entries, for example by comparing SourceDigest against the current
+// source, and to evict those compiled by another CompilerVersion.
+type ProgramHeader struct {
+	CompilerVersion int                // version of the compiler that produced the file
+	Options         syntax.FileOptions // options used to compile the file
+	SourceDigest    []byte             // SHA-256 digest of the source, if known
+	Filename        string             // name of the source file
+	Loads           []string           // module names of load statements, unresolved
+}
+
+// Compatible reports whether the program described by the header
+// may be loaded by CompiledProgram, that is, whether it was produced
+// by the current CompilerVersion.
+func (hdr *ProgramHeader) Compatible() bool { return hdr.CompilerVersion == CompilerVersion }
+
+// ReadProgramHeader reads the header of a compiled program previously
+// saved by Program.Write. It does not read beyond the end of the
+// header, and succeeds even for programs produced by other compiler
+// versions, though files produced by versions older than 15 record
+// only their CompilerVersion.
+func ReadProgramHeader(in io.Reader) (*ProgramHeader, error) {
+	hdr, err := compile.ReadHeader(in)
+	if err != nil {
+		return nil, err
+	}
+	return &ProgramHeader{
+		CompilerVersion: hdr.Version,
+		Options:         hdr.Options,
+		SourceDigest:    hdr.SourceDigest,
+		Filename:        hdr.Filename,
+		Loads:           hdr.Loads,
+	}, nil
+}
+
 // ExecFile calls [ExecFileOptions] using [syntax.LegacyFileOptions].
 //
 // Deprecated: use [ExecFileOptions] with [syntax.FileOptions] instead,
@@ -379,14 +511,48 @@ func SourceProgram(filename string, src any, isPredeclared func(string) bool) (*
 // Its typical value is predeclared.Has,
 // where predeclared is a StringDict of pre-declared values.
 func SourceProgramOptions(opts *syntax.FileOptions, filename string, src any, isPredeclared func(string) bool) (*syntax.File, *Program, error) {
+	// Read the source so that we can record its digest.
+	var content []byte
+	switch s := src.(type) {
+	case string:
+		content = []byte(s)
+	case []byte:
+		content = s
+	case syntax.FilePortion:
+		content = s.Content
+	case io.Reader, nil:
+		data, err := readSource(filename, src)
+		if err != nil {
+			return nil, nil, err
+		}
+		content, src = data, data
+	}
+
 	f, err := opts.Parse(filename, src, 0)
 	if err != nil {
 		return nil, nil, err
 	}
 	prog, err := FileProgram(f, isPredeclared)
+	if prog != nil && content != nil {
+		digest := sha256.Sum256(content)
+		prog.compiled.SourceDigest = digest[:]
+	}
 	return f, prog, err
 }
 
+// readSource reads the source of a file, as syntax.Parse would,
+// for src values that are an io.Reader or nil.
+func readSource(filename string, src any) ([]byte, error) {
+	if r, ok := src.(io.Reader); ok {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, &os.PathError{Op: "read", Path: filename, Err: err}
+		}
+		return data, nil
+	}
+	return os.ReadFile(filename)
+}
+
 // FileProgram produces a new program by resolving,
 // and compiling the Starlark source file syntax tree.
 // On success, it returns the compiled program.
@@ -402,6 +568,11 @@ func FileProgram(f *syntax.File, isPredeclared func(string) bool) (*Program, err
 	if err := resolve.File(f, isPredeclared, Universe.Has); err != nil {
 		return nil, err
 	}
+	if f.Options.CheckTypes {
+		if err := types.CheckAnnotations(f); err != nil {
+			return nil, err
+		}
+	}
 
 	var pos syntax.Position
 	if len(f.Stmts) > 0 {
@@ -459,6 +630,11 @@ func ExecREPLChunk(f *syntax.File, thread *Thread, globals StringDict) error {
 	if err := resolve.REPLChunk(f, globals.Has, predeclared.Has, Universe.Has); err != nil {
 		return err
 	}
+	if f.Options.CheckTypes {
+		if err := types.CheckAnnotations(f); err != nil {
+			return err
+		}
+	}
 
 	var pos syntax.Position
 	if len(f.Stmts) > 0 {
@@ -1136,7 +1312,8 @@ unknown:
 
 // It's always possible to overeat in small bites but we'll
 // try to stop someone swallowing the world in one gulp.
-const maxAlloc = 1 << 30
+// (It is a variable so that tests may lower it.)
+var maxAlloc uint = 1 << 30
 
 func tupleRepeat(elems Tuple, n Int) (Tuple, error) {
 	if len(elems) == 0 {
@@ -1190,13 +1367,61 @@ func stringRepeat(s String, n Int) (String, error) {
 	return String(strings.Repeat(string(s), i)), nil
 }
 
+// A CallHooks holds functions called by a thread before and after
+// each call of a function or built-in, whether by a Starlark program
+// or by a call of Call from Go. The hooks are called with the stack
+// of the caller: the callee's frame is not yet pushed, or already
+// popped. Either hook may be nil.
+//
+// The hooks may be used to audit the functions a program calls,
+// to charge for the use of particular built-ins, or to memoize the
+// results of pure functions.
+type CallHooks struct {
+	// OnCall is called before each call. If it returns a non-nil
+	// result or error, the call is not made, OnReturn is not called,
+	// and the call instead returns that result or error.
+	// OnCall may retain args and kwargs, but must not modify them.
+	OnCall func(thread *Thread, fn Callable, args Tuple, kwargs []Tuple) (Value, error)
+
+	// OnReturn is called after each call made, with its result or error.
+	OnReturn func(thread *Thread, fn Callable, result Value, err error)
+}
+
+// call makes the call of c by Call, with the hooks h.
+func (h *CallHooks) call(thread *Thread, c Callable, args Tuple, kwargs []Tuple) (Value, error) {
+	if h.OnCall != nil {
+		result, err := h.OnCall(thread, c, args, kwargs)
+		if err != nil {
+			if !is[*EvalError](err) {
+				err = thread.evalError(err)
+			}
+			return nil, err
+		}
+		if result != nil {
+			return result, nil
+		}
+	}
+	result, err := call(thread, c, args, kwargs)
+	if h.OnReturn != nil {
+		h.OnReturn(thread, c, result, err)
+	}
+	return result, err
+}
+
 // Call calls the function fn with the specified positional and keyword arguments.
 func Call(thread *Thread, fn Value, args Tuple, kwargs []Tuple) (Value, error) {
 	c, ok := fn.(Callable)
 	if !ok {
 		return nil, fmt.Errorf("invalid call of non-function (%s)", fn.Type())
 	}
+	if thread.Hooks != nil {
+		return thread.Hooks.call(thread, c, args, kwargs)
+	}
+	return call(thread, c, args, kwargs)
+}
 
+// call calls the callable c, with a new frame.
+func call(thread *Thread, c Callable, args Tuple, kwargs []Tuple) (_ Value, err error) {
 	// Allocate and push a new frame.
 	var fr *frame
 	// Optimization: use slack portion of thread.stack
@@ -1221,6 +1446,11 @@ func Call(thread *Thread, fn Value, args Tuple, kwargs []Tuple) (Value, error) {
 
 	thread.beginProfSpan()
 
+	if thread.Tracer != nil {
+		span := thread.traceCall(c)
+		defer func() { thread.Tracer.End(thread, span, err) }()
+	}
+
 	// Use defer to ensure that panics from built-ins
 	// pass through the interpreter without leaving
 	// it in a bad state.
@@ -1239,7 +1469,7 @@ func Call(thread *Thread, fn Value, args Tuple, kwargs []Tuple) (Value, error) {
 
 	// Sanity check: nil is not a valid Starlark value.
 	if result == nil && err == nil {
-		err = fmt.Errorf("internal error: nil (not None) returned from %s", fn)
+		err = fmt.Errorf("internal error: nil (not None) returned from %s", c)
 	}
 
 	// Always return an EvalError with an accurate frame.
@@ -1496,6 +1726,10 @@ func setArgs(locals []Value, fn *Function, args Tuple, kwargs []Tuple) error {
 				fn.Name(), len(missing), cond(len(missing) > 1, "s", ""), strings.Join(missing, ", "))
 		}
 	}
+
+	if fn.funcode.ParamTypes != nil {
+		return checkParamTypes(locals, fn)
+	}
 	return nil
 }
 
@@ -41,6 +41,10 @@ func getOptions(src string) *syntax.FileOptions {
 		GlobalReassign:    option(src, "globalreassign"),
 		LoadBindsGlobally: option(src, "loadbindsglobally"),
 		Recursion:         option(src, "recursion"),
+		TypeAnnotations:   option(src, "typeannotations"),
+		CheckTypes:        option(src, "checktypes"),
+		FStrings:          option(src, "fstrings"),
+		Exceptions:        option(src, "exceptions"),
 	}
 }
 
@@ -113,6 +117,35 @@ func TestEvalExpr(t *testing.T) {
 	}
 }
 
+// execFileTests are the test files of TestExecFile, which also form
+// the seed corpus of FuzzExecFile.
+var execFileTests = []string{
+	"testdata/annotations.star",
+	"testdata/assign.star",
+	"testdata/bool.star",
+	"testdata/builtins.star",
+	"testdata/bytes.star",
+	"testdata/control.star",
+	"testdata/dict.star",
+	"testdata/exceptions.star",
+	"testdata/float.star",
+	"testdata/fstring.star",
+	"testdata/function.star",
+	"testdata/int.star",
+	"testdata/json.star",
+	"testdata/list.star",
+	"testdata/math.star",
+	"testdata/misc.star",
+	"testdata/proto.star",
+	"testdata/set.star",
+	"testdata/string.star",
+	"testdata/time.star",
+	"testdata/tuple.star",
+	"testdata/recursion.star",
+	"testdata/module.star",
+	"testdata/while.star",
+}
+
 func TestExecFile(t *testing.T) {
 	testdata := starlarktest.DataFile("starlark", ".")
 	thread := &starlark.Thread{Load: load}
@@ -134,29 +167,7 @@ func TestExecFile(t *testing.T) {
 	}
 	starlarkproto.SetPool(thread, pool)
 
-	for _, file := range []string{
-		"testdata/assign.star",
-		"testdata/bool.star",
-		"testdata/builtins.star",
-		"testdata/bytes.star",
-		"testdata/control.star",
-		"testdata/dict.star",
-		"testdata/float.star",
-		"testdata/function.star",
-		"testdata/int.star",
-		"testdata/json.star",
-		"testdata/list.star",
-		"testdata/math.star",
-		"testdata/misc.star",
-		"testdata/proto.star",
-		"testdata/set.star",
-		"testdata/string.star",
-		"testdata/time.star",
-		"testdata/tuple.star",
-		"testdata/recursion.star",
-		"testdata/module.star",
-		"testdata/while.star",
-	} {
+	for _, file := range execFileTests {
 		filename := filepath.Join(testdata, file)
 		for _, chunk := range chunkedfile.Read(filename, t) {
 			predeclared := starlark.StringDict{
@@ -191,6 +202,90 @@ func TestExecFile(t *testing.T) {
 	}
 }
 
+// FuzzExecFile executes arbitrary files, starting from the chunks of
+// the test files of TestExecFile, to check that the interpreter
+// reports errors rather than crashing.
+//
+// The step limit does not bound the work done within a single
+// operation or call of a built-in, so the size of the source, the
+// results of repeat operations, and the arguments and results of
+// built-ins are limited too, lest hangs and exhausted memory be
+// reported as failures.
+func FuzzExecFile(f *testing.F) {
+	const maxSize = 1 << 16
+	defer starlark.SetMaxAlloc(starlark.SetMaxAlloc(maxSize))
+
+	testdata := starlarktest.DataFile("starlark", ".")
+	for _, file := range execFileTests {
+		for _, chunk := range chunkedfile.Read(filepath.Join(testdata, file), f) {
+			f.Add(chunk.Source)
+		}
+	}
+	// tooLarge reports whether v is a value whose use by a
+	// built-in might be expensive, such as a long string or list,
+	// or an int usable as a large width or count.
+	tooLarge := func(v starlark.Value) bool {
+		switch v := v.(type) {
+		case starlark.Int:
+			i, ok := v.Int64()
+			return !ok || i < -maxSize || i > maxSize
+		case interface{ Len() int }:
+			return v.Len() > maxSize
+		}
+		return false
+	}
+	hooks := &starlark.CallHooks{
+		OnCall: func(thread *starlark.Thread, fn starlark.Callable, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if _, ok := fn.(*starlark.Builtin); ok {
+				for _, arg := range args {
+					if tooLarge(arg) {
+						return nil, fmt.Errorf("%s: argument too large", fn.Name())
+					}
+				}
+				for _, kwarg := range kwargs {
+					if tooLarge(kwarg[1]) {
+						return nil, fmt.Errorf("%s: argument %s too large", fn.Name(), kwarg[0])
+					}
+				}
+			}
+			return nil, nil
+		},
+		OnReturn: func(thread *starlark.Thread, fn starlark.Callable, result starlark.Value, err error) {
+			if _, ok := fn.(*starlark.Builtin); ok && err == nil && tooLarge(result) {
+				thread.Cancel(fn.Name() + ": result too large")
+			}
+		},
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		if len(src) > maxSize {
+			return
+		}
+		thread := &starlark.Thread{
+			Print: func(*starlark.Thread, string) {},
+			Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+				switch module {
+				case "assert.star", "json.star", "time.star", "math.star", "proto.star":
+					return load(thread, module) // no files
+				}
+				return nil, fmt.Errorf("no module %s", module)
+			},
+		}
+		thread.SetMaxExecutionSteps(100_000)
+		thread.Hooks = hooks
+		starlarktest.SetReporter(thread, discard{})
+		predeclared := starlark.StringDict{
+			"hasfields": starlark.NewBuiltin("hasfields", newHasFields),
+			"struct":    starlark.NewBuiltin("struct", starlarkstruct.Make),
+		}
+		starlark.ExecFileOptions(getOptions(src), thread, "fuzz.star", src, predeclared)
+	})
+}
+
+// discard is a starlarktest.Reporter that discards errors.
+type discard struct{}
+
+func (discard) Error(args ...any) {}
+
 // A fib is an iterable value representing the infinite Fibonacci sequence.
 type fib struct{}
 
@@ -1247,3 +1342,130 @@ func TestUnpackArgNoEscape(t *testing.T) {
 		t.Errorf("AllocsPerRun = %v, want none", n)
 	}
 }
+
+func TestCallNoAlloc(t *testing.T) {
+	const src = `
+def leaf(x, y = 0):
+    return x
+
+def f(n):
+    for i in range(n):
+        leaf(i, y = i)
+        leaf(x = i)
+`
+	thread := new(starlark.Thread)
+	globals, err := starlark.ExecFile(thread, "call.star", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls to Starlark functions, with or without keyword
+	// arguments, must not allocate once the thread is warm.
+	// The few remaining allocations (for the range and its
+	// iterator, for example) do not depend on the number of calls.
+	args := starlark.Tuple{starlark.MakeInt(100)}
+	n := testing.AllocsPerRun(10, func() {
+		if _, err := starlark.Call(thread, globals["f"], args, nil); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if n > 10 {
+		t.Errorf("AllocsPerRun = %v for 200 calls, want at most 10", n)
+	}
+}
+
+func TestCallHooks(t *testing.T) {
+	const src = `
+def square(x):
+    return x * x
+
+def f():
+    return [square(x % 3) for x in range(6)]
+
+result = f()
+total = len(result)
+`
+	// Record the built-ins used, and memoize calls of square.
+	var (
+		builtins []string
+		computed int
+		memo     = make(map[string]starlark.Value)
+		pending  []string // arguments of active calls of square
+	)
+	thread := &starlark.Thread{
+		Hooks: &starlark.CallHooks{
+			OnCall: func(thread *starlark.Thread, fn starlark.Callable, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if _, ok := fn.(*starlark.Builtin); ok {
+					builtins = append(builtins, fn.Name())
+				}
+				if fn.Name() == "square" {
+					if v, ok := memo[args.String()]; ok {
+						return v, nil
+					}
+					pending = append(pending, args.String())
+				}
+				return nil, nil
+			},
+			OnReturn: func(thread *starlark.Thread, fn starlark.Callable, result starlark.Value, err error) {
+				if fn.Name() == "square" {
+					computed++
+					memo[pending[len(pending)-1]] = result
+					pending = pending[:len(pending)-1]
+				}
+			},
+		},
+	}
+	globals, err := starlark.ExecFile(thread, "hooks.star", src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := globals["result"].String(), "[0, 1, 4, 0, 1, 4]"; got != want {
+		t.Errorf("result = %s, want %s", got, want)
+	}
+	if computed != 3 {
+		t.Errorf("square computed %d times, want 3", computed)
+	}
+	if got, want := strings.Join(builtins, " "), "range len"; got != want {
+		t.Errorf("built-ins called: %s, want %s", got, want)
+	}
+
+	// OnCall may retain the arguments of calls of Starlark functions.
+	var retained []string
+	var calls []starlark.Tuple
+	var kwcalls [][]starlark.Tuple
+	thread.Hooks = &starlark.CallHooks{
+		OnCall: func(thread *starlark.Thread, fn starlark.Callable, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if fn.Name() == "g" {
+				retained = append(retained, fmt.Sprint(args, kwargs))
+				calls = append(calls, args)
+				kwcalls = append(kwcalls, kwargs)
+			}
+			return nil, nil
+		},
+	}
+	if _, err := starlark.ExecFile(thread, "retain.star", `
+def g(x, y=0): return x + y
+[g(i, y=2*i) for i in range(3)]
+`, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := range calls {
+		if got := fmt.Sprint(calls[i], kwcalls[i]); got != retained[i] {
+			t.Errorf("retained arguments of call %d: got %s, want %s", i, got, retained[i])
+		}
+	}
+
+	// An error from OnCall prevents the call.
+	thread.Hooks = &starlark.CallHooks{
+		OnCall: func(thread *starlark.Thread, fn starlark.Callable, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if fn.Name() == "len" {
+				return nil, fmt.Errorf("len is not permitted")
+			}
+			return nil, nil
+		},
+	}
+	_, err = starlark.ExecFile(thread, "hooks.star", src, nil)
+	if err == nil || !strings.Contains(err.Error(), "len is not permitted") {
+		t.Errorf("got error %v, want len is not permitted", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark
+
+// This file defines the value of an error caught by a try statement,
+// which is enabled by the syntax.FileOptions.Exceptions dialect option.
+// The interpreter's handling of errors is in interp.go.
+
+import "fmt"
+
+// An ErrorValue is the value of an error caught by the except clause
+// of a try statement:
+//
+//	try:
+//	    load("config.star", "settings")
+//	except e:
+//	    print(e.message)
+//
+// An ErrorValue is immutable. Its attributes are:
+//
+//	message    string  the error message
+//	backtrace  string  the call stack at the point of the error
+//	cause      string  the name of the Go type of the underlying error
+type ErrorValue struct {
+	err *EvalError
+}
+
+var _ HasAttrs = (*ErrorValue)(nil)
+
+// Err returns the caught error.
+func (e *ErrorValue) Err() *EvalError { return e.err }
+
+func (e *ErrorValue) String() string        { return "error(" + String(e.err.Msg).String() + ")" }
+func (e *ErrorValue) Type() string          { return "error" }
+func (e *ErrorValue) Freeze()               {} // immutable
+func (e *ErrorValue) Truth() Bool           { return True }
+func (e *ErrorValue) Hash() (uint32, error) { return 0, fmt.Errorf("unhashable type: error") }
+
+func (e *ErrorValue) Attr(name string) (Value, error) {
+	switch name {
+	case "message":
+		return String(e.err.Msg), nil
+	case "backtrace":
+		return String(e.err.CallStack.String()), nil
+	case "cause":
+		return String(causeName(e.err)), nil
+	}
+	return nil, nil
+}
+
+func (e *ErrorValue) AttrNames() []string { return []string{"backtrace", "cause", "message"} }
+
+// causeName returns the name of the Go type of the error underlying err,
+// looking through the interpreter's own wrappers.
+func causeName(err error) string {
+	for {
+		switch e := err.(type) {
+		case *EvalError:
+			if e.cause == nil {
+				return fmt.Sprintf("%T", e)
+			}
+			err = e.cause
+			continue
+		case wrappedError:
+			err = e.cause
+			continue
+		}
+		return fmt.Sprintf("%T", err)
+	}
+}
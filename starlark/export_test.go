@@ -13,3 +13,11 @@ func UnpackArgNoEscape(v Value, ptr any) error {
 func UnpackPositionalArgsNoEscape(fnname string, args Tuple, kwargs []Tuple, min int, vars ...any) error {
 	return unpackPositionalArgsNoEscape(fnname, args, kwargs, min, vars...)
 }
+
+// SetMaxAlloc sets the limit on the size of the result of a repeat
+// operation such as "x" * n, and returns the previous limit.
+func SetMaxAlloc(n uint) uint {
+	old := maxAlloc
+	maxAlloc = n
+	return old
+}
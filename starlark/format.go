@@ -0,0 +1,356 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark
+
+// This file defines the format specifier language of replacement
+// fields, shared by str.format and f-strings, as in "{x:>10}" or
+// f"{x:.2f}". Like f-strings, non-empty specifiers in str.format
+// require the FStrings file option.
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A formatSpec is a parsed format specifier.
+type formatSpec struct {
+	fill     rune // padding character
+	align    byte // '<', '>', '^', '=', or 0 for the default
+	sign     byte // '+', '-', ' ', or 0 for the default
+	alt      bool // '#': use the 0b, 0o, or 0x prefix
+	zero     bool // '0': pad numbers with zeros after the sign
+	width    int  // minimum width, in runes
+	grouping byte // thousands separator ',' or '_', or 0 for none
+	prec     int  // precision, or -1 for the default
+	typ      byte // presentation type, or 0 for the default
+}
+
+// parseFormatSpec parses a format specifier, whose syntax is:
+//
+//	[[fill]align][sign][#][0][width][,|_][.precision][type]
+func parseFormatSpec(spec string) (formatSpec, error) {
+	fs := formatSpec{fill: ' ', prec: -1}
+	s := spec
+	explicitFill := false
+	isAlign := func(c byte) bool { return strings.IndexByte("<>^=", c) >= 0 }
+	if r, n := utf8.DecodeRuneInString(s); n < len(s) && isAlign(s[n]) {
+		fs.fill, fs.align = r, s[n]
+		explicitFill = true
+		s = s[n+1:]
+	} else if len(s) > 0 && isAlign(s[0]) {
+		fs.align = s[0]
+		s = s[1:]
+	}
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-' || s[0] == ' ') {
+		fs.sign = s[0]
+		s = s[1:]
+	}
+	if len(s) > 0 && s[0] == '#' {
+		fs.alt = true
+		s = s[1:]
+	}
+	if len(s) > 0 && s[0] == '0' {
+		fs.zero = true
+		if !explicitFill {
+			fs.fill = '0'
+		}
+		s = s[1:]
+	}
+	var ok bool
+	if fs.width, s, ok = leadingNumber(s); !ok {
+		return fs, fmt.Errorf("invalid format spec %q: width too large", spec)
+	}
+	if len(s) > 0 && (s[0] == ',' || s[0] == '_') {
+		fs.grouping = s[0]
+		s = s[1:]
+	}
+	if len(s) > 0 && s[0] == '.' {
+		s = s[1:]
+		if len(s) == 0 || s[0] < '0' || s[0] > '9' {
+			return fs, fmt.Errorf("invalid format spec %q: missing precision", spec)
+		}
+		if fs.prec, s, ok = leadingNumber(s); !ok {
+			return fs, fmt.Errorf("invalid format spec %q: precision too large", spec)
+		}
+	}
+	if len(s) == 1 && strings.IndexByte("sdboxXeEfFgG%", s[0]) >= 0 {
+		fs.typ = s[0]
+		s = ""
+	}
+	if s != "" {
+		return fs, fmt.Errorf("invalid format spec %q", spec)
+	}
+	return fs, nil
+}
+
+// leadingNumber parses the decimal digits at the start of s,
+// and returns their value (or zero if none) and the remainder of s.
+// It reports false if the number is implausibly large.
+func leadingNumber(s string) (int, string, bool) {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, s, true
+	}
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n > maxFormatWidth {
+		return 0, s, false
+	}
+	return n, s[i:], true
+}
+
+// maxFormatWidth limits the width and precision of a format
+// specifier, to avoid unbounded allocation.
+const maxFormatWidth = 1 << 20
+
+// formatField appends to buf the value x, formatted according to the
+// conversion conv (0 for none, 's' for str, or 'r' for repr) and the
+// format specifier spec, which may be empty. An explicit conversion
+// is applied first; the specifier then applies to the resulting string.
+func formatField(buf *strings.Builder, x Value, conv byte, spec string) error {
+	if spec == "" {
+		if s, ok := AsString(x); ok && conv != 'r' {
+			buf.WriteString(s)
+		} else {
+			writeValue(buf, x, nil)
+		}
+		return nil
+	}
+
+	fs, err := parseFormatSpec(spec)
+	if err != nil {
+		return err
+	}
+	switch conv {
+	case 's':
+		if _, ok := x.(String); !ok {
+			x = String(x.String())
+		}
+	case 'r':
+		x = String(x.String())
+	}
+
+	invalid := func() error {
+		return fmt.Errorf("invalid format spec %q for %s value", spec, x.Type())
+	}
+	var prefix, body string // prefix holds the sign and base prefix of a number
+	switch x := x.(type) {
+	case Int:
+		switch fs.typ {
+		case 0, 'd', 'b', 'o', 'x', 'X':
+			if fs.prec >= 0 {
+				return invalid()
+			}
+			prefix, body = fs.formatInt(x)
+		case 'e', 'E', 'f', 'F', 'g', 'G', '%':
+			f, err := x.finiteFloat()
+			if err != nil {
+				return err
+			}
+			prefix, body = fs.formatFloat(float64(f))
+		default:
+			return invalid()
+		}
+
+	case Float:
+		switch fs.typ {
+		case 0, 'e', 'E', 'f', 'F', 'g', 'G', '%':
+			prefix, body = fs.formatFloat(float64(x))
+		default:
+			return invalid()
+		}
+
+	default:
+		if fs.typ != 0 && fs.typ != 's' || fs.sign != 0 || fs.alt || fs.grouping != 0 || fs.align == '=' {
+			return invalid()
+		}
+		if s, ok := x.(String); ok {
+			body = string(s)
+		} else {
+			body = x.String()
+		}
+		if fs.prec >= 0 {
+			// Truncate to prec runes.
+			n := 0
+			for i := range body {
+				if n == fs.prec {
+					body = body[:i]
+					break
+				}
+				n++
+			}
+		}
+		if fs.align == 0 {
+			fs.align = '<'
+		}
+	}
+	fs.pad(buf, prefix, body)
+	return nil
+}
+
+// formatInt returns the sign and base prefix, and the digits, of x.
+func (fs *formatSpec) formatInt(x Int) (prefix, digits string) {
+	base, group := 10, 3
+	switch fs.typ {
+	case 'b':
+		base, group, prefix = 2, 4, "0b"
+	case 'o':
+		base, group, prefix = 8, 4, "0o"
+	case 'x':
+		base, group, prefix = 16, 4, "0x"
+	case 'X':
+		base, group, prefix = 16, 4, "0X"
+	}
+	if !fs.alt {
+		prefix = ""
+	}
+	bigint := x.BigInt()
+	digits = new(big.Int).Abs(bigint).Text(base)
+	if fs.typ == 'X' {
+		digits = strings.ToUpper(digits)
+	}
+	if fs.grouping != 0 {
+		digits = groupDigits(digits, group, fs.grouping)
+	}
+	return fs.signOf(bigint.Sign() < 0) + prefix, digits
+}
+
+// formatFloat returns the sign and the digits of f.
+func (fs *formatSpec) formatFloat(f float64) (sign, digits string) {
+	sign = fs.signOf(math.Signbit(f) && !math.IsNaN(f))
+	f = math.Abs(f)
+	prec := fs.prec
+	switch {
+	case math.IsInf(f, 0):
+		digits = "inf"
+	case math.IsNaN(f):
+		digits = "nan"
+	case fs.typ == 0 && prec < 0:
+		var buf strings.Builder
+		Float(f).format(&buf, 'g')
+		digits = buf.String()
+	case fs.typ == '%':
+		if prec < 0 {
+			prec = 6
+		}
+		digits = strconv.FormatFloat(f*100, 'f', prec, 64) + "%"
+	default:
+		if prec < 0 {
+			prec = 6
+		}
+		conv := fs.typ
+		switch conv {
+		case 0:
+			conv = 'g'
+		case 'F':
+			conv = 'f'
+		}
+		digits = strconv.FormatFloat(f, conv, prec, 64)
+	}
+	if fs.typ == 'E' || fs.typ == 'F' || fs.typ == 'G' {
+		digits = strings.ToUpper(digits)
+	}
+	if fs.grouping != 0 {
+		// Group the digits of the integer part.
+		i := 0
+		for i < len(digits) && '0' <= digits[i] && digits[i] <= '9' {
+			i++
+		}
+		digits = groupDigits(digits[:i], 3, fs.grouping) + digits[i:]
+	}
+	return sign, digits
+}
+
+// signOf returns the sign prefix for a number.
+func (fs *formatSpec) signOf(negative bool) string {
+	switch {
+	case negative:
+		return "-"
+	case fs.sign == '+':
+		return "+"
+	case fs.sign == ' ':
+		return " "
+	}
+	return ""
+}
+
+// groupDigits inserts the separator sep between each group of n digits,
+// counting from the right.
+func groupDigits(digits string, n int, sep byte) string {
+	if len(digits) <= n {
+		return digits
+	}
+	var buf strings.Builder
+	first := len(digits) % n
+	if first == 0 {
+		first = n
+	}
+	buf.WriteString(digits[:first])
+	for i := first; i < len(digits); i += n {
+		buf.WriteByte(sep)
+		buf.WriteString(digits[i : i+n])
+	}
+	return buf.String()
+}
+
+// pad appends the prefix and body to buf, padded to the width of fs.
+func (fs *formatSpec) pad(buf *strings.Builder, prefix, body string) {
+	align := fs.align
+	if align == 0 {
+		align = '>' // numbers are right-aligned by default
+		if fs.zero {
+			align = '='
+		}
+	}
+	n := fs.width - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(body)
+	if n <= 0 {
+		buf.WriteString(prefix)
+		buf.WriteString(body)
+		return
+	}
+	fill := string(fs.fill)
+	switch align {
+	case '<':
+		buf.WriteString(prefix)
+		buf.WriteString(body)
+		buf.WriteString(strings.Repeat(fill, n))
+	case '>':
+		buf.WriteString(strings.Repeat(fill, n))
+		buf.WriteString(prefix)
+		buf.WriteString(body)
+	case '^':
+		buf.WriteString(strings.Repeat(fill, n/2))
+		buf.WriteString(prefix)
+		buf.WriteString(body)
+		buf.WriteString(strings.Repeat(fill, n-n/2))
+	case '=':
+		buf.WriteString(prefix)
+		buf.WriteString(strings.Repeat(fill, n))
+		buf.WriteString(body)
+	}
+}
+
+// formatDirective returns the f-string replacement field for the value
+// x, formatted according to the directive "[!conv][:spec]" compiled
+// from the field.
+func formatDirective(x Value, directive string) (Value, error) {
+	var conv byte
+	if strings.HasPrefix(directive, "!") {
+		conv = directive[1]
+		directive = directive[2:]
+	}
+	spec := strings.TrimPrefix(directive, ":")
+	var buf strings.Builder
+	if err := formatField(&buf, x, conv, spec); err != nil {
+		return nil, err
+	}
+	return String(buf.String()), nil
+}
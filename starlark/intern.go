@@ -0,0 +1,132 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark
+
+// This file defines canonical preallocated values for common small
+// strings, ints, and the empty tuple, and the Interner type, which
+// lets clients share values for frequently repeated strings.
+//
+// Converting a String or (on some platforms) an Int to a Value
+// requires a heap allocation to hold the boxed value. Operations that
+// produce many small values, such as string indexing and iteration
+// over s.elems(), can avoid this cost by returning a canonical Value.
+
+import (
+	"strings"
+	"sync"
+)
+
+var (
+	// byteStrings[b] is the canonical Value for the one-byte string string(b).
+	byteStrings [256]Value
+
+	// smallInts[i] is the canonical Value for MakeInt(i + minSmallInt).
+	smallInts [maxSmallInt - minSmallInt + 1]Value
+
+	// emptyTuple is the canonical Value for the empty tuple.
+	emptyTuple Value = Tuple(nil)
+)
+
+// Bounds of the table of canonical small ints.
+const (
+	minSmallInt = -128
+	maxSmallInt = 1023
+)
+
+func init() {
+	for i := range byteStrings {
+		byteStrings[i] = String([]byte{byte(i)})
+	}
+	for i := range smallInts {
+		smallInts[i] = MakeInt(i + minSmallInt)
+	}
+}
+
+// stringValue returns s as a Value, using a canonical value if s is empty
+// or has a length of one byte (as do all one-character ASCII strings).
+func stringValue(s string) Value {
+	switch len(s) {
+	case 0:
+		return String("")
+	case 1:
+		return byteStrings[s[0]]
+	}
+	return String(s)
+}
+
+// intValue returns MakeInt(x) as a Value, using a canonical value if x is small.
+func intValue(x int) Value {
+	if minSmallInt <= x && x <= maxSmallInt {
+		return smallInts[x-minSmallInt]
+	}
+	return MakeInt(x)
+}
+
+// An Interner maps strings to canonical String values, so that
+// repeated occurrences of the same string, such as the keys of dicts
+// decoded from a large JSON document, share a single value instead of
+// each requiring a separate allocation and copy.
+//
+// An Interner retains every string it has interned, so its lifetime
+// should be limited to that of a single task, such as the evaluation
+// of a set of related files. Built-in functions that create many
+// strings, such as json.decode, use the Interner of the current
+// thread, if any; see Thread.Interner.
+//
+// The zero value is an empty Interner ready to use.
+// An Interner is safe for concurrent use by multiple goroutines.
+// A nil *Interner is valid, and interns nothing.
+type Interner struct {
+	// MaxLen is the length of the longest string that will be interned.
+	// Longer strings are returned as fresh values.
+	// If zero, strings of any length are interned.
+	MaxLen int
+
+	mu sync.Mutex
+	m  map[string]Value
+}
+
+// Intern returns the canonical Value for the String s.
+func (in *Interner) Intern(s string) Value {
+	if in == nil || len(s) < 2 || in.MaxLen > 0 && len(s) > in.MaxLen {
+		return stringValue(s)
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	v, ok := in.m[s]
+	if !ok {
+		if in.m == nil {
+			in.m = make(map[string]Value)
+		}
+		s = strings.Clone(s) // don't retain a larger string of which s is a part
+		v = String(s)
+		in.m[s] = v
+	}
+	return v
+}
+
+// InternBytes returns the canonical Value for the String whose
+// content is b. It does not allocate if the string is already interned.
+func (in *Interner) InternBytes(b []byte) Value {
+	if in != nil && len(b) >= 2 && !(in.MaxLen > 0 && len(b) > in.MaxLen) {
+		in.mu.Lock()
+		v, ok := in.m[string(b)] // does not allocate
+		in.mu.Unlock()
+		if ok {
+			return v
+		}
+	}
+	return in.Intern(string(b))
+}
+
+// Len returns the number of strings interned by in.
+func (in *Interner) Len() int {
+	if in == nil {
+		return 0
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return len(in.m)
+}
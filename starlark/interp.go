@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strings"
 
 	"go.starlark.net/internal/compile"
 	"go.starlark.net/internal/spell"
@@ -47,22 +48,36 @@ func (fn *Function) CallInternal(thread *Thread, args Tuple, kwargs []Tuple) (Va
 
 	fr := thread.frameAt(0)
 
-	// Allocate space for stack and locals.
+	// Allocate space for stack and locals from the thread's arena.
 	// Logically these do not escape from this frame
 	// (See https://github.com/golang/go/issues/20533.)
-	//
-	// This heap allocation looks expensive, but I was unable to get
-	// more than 1% real time improvement in a large alloc-heavy
-	// benchmark (in which this alloc was 8% of alloc-bytes)
-	// by allocating space for 8 Values in each frame, or
-	// by allocating stack by slicing an array held by the Thread
-	// that is expanded in chunks of min(k, nspace), for k=256 or 1024.
+	// Closures that capture a local refer to its cell, which is
+	// allocated separately, never to the arena itself.
 	nlocals := len(f.Locals)
 	nspace := nlocals + f.MaxStack
-	space := make([]Value, nspace)
+	valuesMark, pairsMark := thread.nvalues, thread.npairs
+	space := thread.allocValues(nspace)
 	locals := space[:nlocals:nlocals] // local variables, starting with parameters
 	stack := space[nlocals:]          // operand stack
 
+	var iterstack []Iterator // stack of active iterators
+
+	// Use defer so that application panics can pass through
+	// interpreter without leaving thread in a bad state.
+	defer func() {
+		// ITERPOP the rest of the iterator stack.
+		for _, iter := range iterstack {
+			iter.Done()
+		}
+
+		fr.locals = nil
+
+		// Release arena space, clearing it to avoid retaining garbage.
+		clear(space)
+		clear(thread.pairs[pairsMark:thread.npairs])
+		thread.nvalues, thread.npairs = valuesMark, pairsMark
+	}()
+
 	// Digest arguments and set parameters.
 	err := setArgs(locals, fn, args, kwargs)
 	if err != nil {
@@ -87,23 +102,17 @@ func (fn *Function) CallInternal(thread *Thread, args Tuple, kwargs []Tuple) (Va
 	// - there is exactly one return statement
 	// - there is no redefinition of 'err'.
 
-	var iterstack []Iterator // stack of active iterators
-
-	// Use defer so that application panics can pass through
-	// interpreter without leaving thread in a bad state.
-	defer func() {
-		// ITERPOP the rest of the iterator stack.
-		for _, iter := range iterstack {
-			iter.Done()
-		}
-
-		fr.locals = nil
-	}()
-
 	sp := 0
 	var pc uint32
 	var result Value
 	code := f.Code
+
+	// Trace the top-level statements of a module.
+	var tracer Tracer
+	stmt := -1 // index of current top-level statement, if tracing
+	if thread.Tracer != nil && f.StmtLines != nil && f == f.Prog.Toplevel {
+		tracer = thread.Tracer
+	}
 loop:
 	for {
 		thread.Steps++
@@ -120,6 +129,9 @@ loop:
 		}
 
 		fr.pc = pc
+		if tracer != nil {
+			stmt = thread.traceStmt(tracer, f, stmt, pc)
+		}
 
 		op := compile.Opcode(code[pc])
 		pc++
@@ -197,6 +209,9 @@ loop:
 				err = err2
 				break loop
 			}
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, z)
+			}
 			stack[sp] = z
 			sp++
 
@@ -213,6 +228,9 @@ loop:
 				err = err2
 				break loop
 			}
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, y)
+			}
 			stack[sp-1] = y
 
 		case compile.INPLACE_ADD:
@@ -229,7 +247,11 @@ loop:
 					if err = xlist.checkMutable("apply += to"); err != nil {
 						break loop
 					}
+					oldcap := cap(xlist.elems)
 					listExtend(xlist, yiter)
+					if thread.profAllocs {
+						thread.recordGrowth(fr.pc, oldcap, cap(xlist.elems))
+					}
 					z = xlist
 				}
 			}
@@ -238,6 +260,9 @@ loop:
 				if err != nil {
 					break loop
 				}
+				if thread.profAllocs {
+					thread.recordAlloc(fr.pc, z)
+				}
 			}
 
 			stack[sp] = z
@@ -257,7 +282,11 @@ loop:
 					if err = xdict.ht.checkMutable("apply |= to"); err != nil {
 						break loop
 					}
+					oldlen := xdict.Len()
 					xdict.ht.addAll(&ydict.ht) // can't fail
+					if thread.profAllocs && xdict.Len() > oldlen {
+						thread.recordAllocBytes(fr.pc, 0, hashtableEntrySize*int64(xdict.Len()-oldlen))
+					}
 					z = xdict
 				}
 			}
@@ -266,6 +295,9 @@ loop:
 				if err != nil {
 					break loop
 				}
+				if thread.profAllocs {
+					thread.recordAlloc(fr.pc, z)
+				}
 			}
 
 			stack[sp] = z
@@ -303,18 +335,35 @@ loop:
 				sp--
 			}
 
+			// If the callee is another Starlark function, it can be
+			// trusted neither to mutate nor to retain its arguments.
+			// Call hooks, which may retain them, are not trusted.
+			npos := int(arg >> 8)
+			nkvpairs := int(arg & 0xff)
+			_, trusted := stack[sp-2*nkvpairs-npos-1].(*Function)
+			trusted = trusted && thread.Hooks == nil
+
 			// named args (pairs)
 			var kvpairs []Tuple
-			if nkvpairs := int(arg & 0xff); nkvpairs > 0 {
-				kvpairs = make([]Tuple, 0, nkvpairs)
-				kvpairsAlloc := make(Tuple, 2*nkvpairs) // allocate a single backing array
+			if nkvpairs > 0 {
 				sp -= 2 * nkvpairs
-				for i := range nkvpairs {
-					pair := kvpairsAlloc[:2:2]
-					kvpairsAlloc = kvpairsAlloc[2:]
-					pair[0] = stack[sp+2*i]   // name
-					pair[1] = stack[sp+2*i+1] // value
-					kvpairs = append(kvpairs, pair)
+				if trusted && kwargs == nil {
+					// Avoid allocation by referring to pairs
+					// of operands on the stack.
+					kvpairs = thread.allocPairs(nkvpairs)
+					for i := range kvpairs {
+						kvpairs[i] = stack[sp+2*i : sp+2*i+2 : sp+2*i+2]
+					}
+				} else {
+					kvpairs = make([]Tuple, 0, nkvpairs)
+					kvpairsAlloc := make(Tuple, 2*nkvpairs) // allocate a single backing array
+					for i := range nkvpairs {
+						pair := kvpairsAlloc[:2:2]
+						kvpairsAlloc = kvpairsAlloc[2:]
+						pair[0] = stack[sp+2*i]   // name
+						pair[1] = stack[sp+2*i+1] // value
+						kvpairs = append(kvpairs, pair)
+					}
 				}
 			}
 			if kwargs != nil {
@@ -340,14 +389,13 @@ loop:
 
 			// positional args
 			var positional Tuple
-			if npos := int(arg >> 8); npos > 0 {
+			if npos > 0 {
 				positional = stack[sp-npos : sp]
 				sp -= npos
 
 				// Copy positional arguments into a new array,
-				// unless the callee is another Starlark function,
-				// in which case it can be trusted not to mutate them.
-				if !is[*Function](stack[sp-1]) || args != nil {
+				// unless the callee is trusted.
+				if !trusted || args != nil {
 					positional = slices.Clone(positional)
 				}
 			}
@@ -375,6 +423,12 @@ loop:
 			thread.endProfSpan()
 			z, err2 := Call(thread, function, positional, kvpairs)
 			thread.beginProfSpan()
+			if thread.profAllocs && err2 == nil && !is[*Function](function) {
+				thread.recordAlloc(fr.pc, z)
+			}
+			if trusted && kwargs == nil && nkvpairs > 0 {
+				thread.freePairs(kvpairs)
+			}
 			if err2 != nil {
 				err = err2
 				break loop
@@ -410,8 +464,15 @@ loop:
 		case compile.NOT:
 			stack[sp-1] = !stack[sp-1].Truth()
 
+		case compile.RERAISE:
+			err = stack[sp-1].(*ErrorValue).err
+			break loop
+
 		case compile.RETURN:
 			result = stack[sp-1]
+			if f.ResultType != nil {
+				err = checkResultType(fn, result)
+			}
 			break loop
 
 		case compile.SETINDEX:
@@ -459,6 +520,9 @@ loop:
 		case compile.MAKEDICT:
 			stack[sp] = new(Dict)
 			sp++
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, stack[sp-1])
+			}
 
 		case compile.SETDICT, compile.SETDICTUNIQ:
 			dict := stack[sp-3].(*Dict)
@@ -474,12 +538,19 @@ loop:
 				err = fmt.Errorf("duplicate key: %v", k)
 				break loop
 			}
+			if thread.profAllocs && dict.Len() > oldlen {
+				thread.recordAllocBytes(fr.pc, 0, hashtableEntrySize)
+			}
 
 		case compile.APPEND:
 			elem := stack[sp-1]
 			list := stack[sp-2].(*List)
 			sp -= 2
+			oldcap := cap(list.elems)
 			list.elems = append(list.elems, elem)
+			if thread.profAllocs {
+				thread.recordGrowth(fr.pc, oldcap, cap(list.elems))
+			}
 
 		case compile.SLICE:
 			x := stack[sp-4]
@@ -492,6 +563,9 @@ loop:
 				err = err2
 				break loop
 			}
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, res)
+			}
 			stack[sp] = res
 			sp++
 
@@ -533,11 +607,19 @@ loop:
 
 		case compile.MAKETUPLE:
 			n := int(arg)
+			if n == 0 {
+				stack[sp] = emptyTuple
+				sp++
+				break
+			}
 			tuple := make(Tuple, n)
 			sp -= n
 			copy(tuple, stack[sp:])
 			stack[sp] = tuple
 			sp++
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, tuple)
+			}
 
 		case compile.MAKELIST:
 			n := int(arg)
@@ -546,6 +628,43 @@ loop:
 			copy(elems, stack[sp:])
 			stack[sp] = NewList(elems)
 			sp++
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, stack[sp-1])
+			}
+
+		case compile.FORMAT:
+			x := stack[sp-1]
+			directive := string(fn.module.constants[arg].(String))
+			if _, ok := x.(String); ok && directive == "" {
+				break // fast path: f"{s}" where s is a string
+			}
+			z, err2 := formatDirective(x, directive)
+			if err2 != nil {
+				err = err2
+				break loop
+			}
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, z)
+			}
+			stack[sp-1] = z
+
+		case compile.CONCAT:
+			n := int(arg)
+			sp -= n
+			size := 0
+			for _, s := range stack[sp : sp+n] {
+				size += len(s.(String))
+			}
+			var buf strings.Builder
+			buf.Grow(size)
+			for _, s := range stack[sp : sp+n] {
+				buf.WriteString(string(s.(String)))
+			}
+			stack[sp] = String(buf.String())
+			sp++
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, stack[sp-1])
+			}
 
 		case compile.MAKEFUNC:
 			funcode := f.Prog.Functions[arg]
@@ -559,6 +678,9 @@ loop:
 				defaults: defaults,
 				freevars: freevars,
 			}
+			if thread.profAllocs {
+				thread.recordAlloc(fr.pc, stack[sp-1])
+			}
 
 		case compile.LOAD:
 			n := int(arg)
@@ -571,7 +693,15 @@ loop:
 			}
 
 			thread.endProfSpan()
+			var span TraceSpan
+			if thread.Tracer != nil {
+				span = TraceSpan{Kind: TraceLoad, Name: module, Pos: fr.Position()}
+				thread.Tracer.Begin(thread, span)
+			}
 			dict, err2 := thread.Load(thread, module)
+			if thread.Tracer != nil {
+				thread.Tracer.End(thread, span, err2)
+			}
 			thread.beginProfSpan()
 			if err2 != nil {
 				err = wrappedError{
@@ -665,6 +795,30 @@ loop:
 			break loop
 		}
 	}
+
+	// If the failed instruction is within a try statement,
+	// continue at its handler, unless the thread was cancelled.
+	if err != nil && f.Handlers != nil && thread.cancelReason.Load() == nil {
+		if h := f.Handler(fr.pc); h != nil {
+			for _, iter := range iterstack[h.IterDepth:] {
+				iter.Done()
+			}
+			iterstack = iterstack[:h.IterDepth]
+			evalErr, ok := err.(*EvalError)
+			if !ok {
+				evalErr = thread.evalError(err)
+			}
+			sp = int(h.Depth)
+			stack[sp] = &ErrorValue{evalErr}
+			sp++
+			pc = h.Target
+			err = nil
+			goto loop
+		}
+	}
+	if tracer != nil && stmt >= 0 {
+		tracer.End(thread, stmtSpan(f, stmt), err)
+	}
 	// (deferred cleanup runs here)
 	return result, err
 }
@@ -711,3 +865,40 @@ func (c *cell) Freeze() {
 }
 func (c *cell) Truth() Bool           { panic("unreachable") }
 func (c *cell) Hash() (uint32, error) { panic("unreachable") }
+
+// minArena is the minimum size of a thread's value arena.
+const minArena = 256
+
+// allocValues returns a slice of n nil values from the thread's value
+// arena. Space must be released in LIFO order, by restoring
+// thread.nvalues to its value before the call.
+//
+// When the arena is exhausted, a new, larger one replaces it. Active
+// frames continue to refer to the old arena, which becomes garbage
+// once they return. Indices are preserved across arenas, so the
+// prefix of the new arena that is still in use by older frames is
+// unused; doubling bounds this waste.
+func (thread *Thread) allocValues(n int) []Value {
+	lo, hi := thread.nvalues, thread.nvalues+n
+	if hi > len(thread.values) {
+		thread.values = make([]Value, max(2*len(thread.values), hi, minArena))
+	}
+	thread.nvalues = hi
+	return thread.values[lo:hi:hi]
+}
+
+// allocPairs is the analogue of allocValues for keyword arguments.
+func (thread *Thread) allocPairs(n int) []Tuple {
+	lo, hi := thread.npairs, thread.npairs+n
+	if hi > len(thread.pairs) {
+		thread.pairs = make([]Tuple, max(2*len(thread.pairs), hi, minArena/8))
+	}
+	thread.npairs = hi
+	return thread.pairs[lo:hi:hi]
+}
+
+// freePairs releases the most recent allocation by allocPairs.
+func (thread *Thread) freePairs(pairs []Tuple) {
+	clear(pairs)
+	thread.npairs -= len(pairs)
+}
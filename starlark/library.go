@@ -294,6 +294,9 @@ func chr(thread *Thread, _ *Builtin, args Tuple, kwargs []Tuple) (Value, error)
 	if i > unicode.MaxRune {
 		return nil, fmt.Errorf("chr: Unicode code point U+%X out of range (>0x10FFFF)", i)
 	}
+	if i < utf8.RuneSelf {
+		return byteStrings[i], nil
+	}
 	return String(string(rune(i))), nil
 }
 
@@ -781,14 +784,14 @@ func ord(thread *Thread, _ *Builtin, args Tuple, kwargs []Tuple) (Value, error)
 			n := utf8.RuneCountInString(s)
 			return nil, fmt.Errorf("ord: string encodes %d Unicode code points, want 1", n)
 		}
-		return MakeInt(int(r)), nil
+		return intValue(int(r)), nil
 
 	case Bytes:
 		// ord(bytes) returns int value of sole byte.
 		if len(x) != 1 {
 			return nil, fmt.Errorf("ord: bytes has length %d, want 1", len(x))
 		}
-		return MakeInt(int(x[0])), nil
+		return intValue(int(x[0])), nil
 	default:
 		return nil, fmt.Errorf("ord: got %s, want string or bytes", x.Type())
 	}
@@ -1689,7 +1692,7 @@ func string_find(_ *Thread, b *Builtin, args Tuple, kwargs []Tuple) (Value, erro
 }
 
 // https://github.com/google/starlark-go/blob/master/doc/spec.md#string·format
-func string_format(_ *Thread, b *Builtin, args Tuple, kwargs []Tuple) (Value, error) {
+func string_format(thread *Thread, b *Builtin, args Tuple, kwargs []Tuple) (Value, error) {
 	format := string(b.Receiver().(String))
 	var auto, manual bool // kinds of positional indexing used
 	buf := new(strings.Builder)
@@ -1733,7 +1736,7 @@ func string_format(_ *Thread, b *Builtin, args Tuple, kwargs []Tuple) (Value, er
 		}
 
 		var arg Value
-		conv := "s"
+		var conv byte
 		var spec string
 
 		field := format[:i]
@@ -1753,12 +1756,12 @@ func string_format(_ *Thread, b *Builtin, args Tuple, kwargs []Tuple) (Value, er
 			name = field[:i]
 			field = field[i+1:]
 			// "conv" or "conv:spec"
-			if before, after, ok := strings.Cut(field, ":"); !ok {
-				conv = field
-			} else {
-				conv = before
-				spec = after
+			c, after, _ := strings.Cut(field, ":")
+			if c != "s" && c != "r" {
+				return nil, fmt.Errorf("format: unknown conversion %q", c)
 			}
+			conv = c[0]
+			spec = after
 		}
 
 		if name == "" {
@@ -1807,27 +1810,28 @@ func string_format(_ *Thread, b *Builtin, args Tuple, kwargs []Tuple) (Value, er
 			}
 		}
 
-		if spec != "" {
-			// Starlark does not support Python's format_spec features.
+		if spec != "" && !callerAllowsFStrings(thread) {
+			// Format specifiers belong to the f-strings dialect.
 			return nil, fmt.Errorf("format spec features not supported in replacement fields: %s", spec)
 		}
 
-		switch conv {
-		case "s":
-			if str, ok := AsString(arg); ok {
-				buf.WriteString(str)
-			} else {
-				writeValue(buf, arg, nil)
-			}
-		case "r":
-			writeValue(buf, arg, nil)
-		default:
-			return nil, fmt.Errorf("format: unknown conversion %q", conv)
+		if err := formatField(buf, arg, conv, spec); err != nil {
+			return nil, fmt.Errorf("format: %v", err)
 		}
 	}
 	return String(buf.String()), nil
 }
 
+// callerAllowsFStrings reports whether the built-in of the current
+// frame of the thread was called from a file that permits f-strings.
+func callerAllowsFStrings(thread *Thread) bool {
+	if thread.CallStackDepth() < 2 {
+		return false
+	}
+	fn, ok := thread.frameAt(1).callable.(*Function)
+	return ok && fn.funcode.Prog.Options.FStrings
+}
+
 // decimal interprets s as a sequence of decimal digits.
 func decimal(s string) (x int, ok bool) {
 	n := len(s)
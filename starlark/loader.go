@@ -0,0 +1,277 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark
+
+// This file defines Loader, a standard concurrent implementation of
+// Thread.Load. (See example_test.go for simpler implementations.)
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.starlark.net/syntax"
+)
+
+// A Loader is a concurrency-safe, duplicate-suppressing cache of
+// Starlark modules, suitable for use as the Load function of a Thread:
+//
+//	loader := &starlark.Loader{Read: readFile}
+//	thread := &starlark.Thread{Load: loader.Load}
+//
+// Each module is executed at most once, in its own goroutine and
+// Thread. Before executing a module, the Loader starts loading all the
+// modules it loads, so that independent modules are loaded in
+// parallel. Concurrent requests for the same module wait for a single
+// execution, whose globals are frozen and shared by all requesters.
+//
+// The Loader detects cycles in the load graph, even when the modules
+// that form the cycle are being loaded by different goroutines, and
+// reports them with an error such as:
+//
+//	cycle in load graph: a.star -> b.star -> a.star
+//
+// The thread that first requests a module determines its execution
+// budget: the module's thread is cancelled when the requesting thread
+// is cancelled, and its step limit is the number of steps remaining to
+// the requesting thread. The steps executed by a module are added to
+// the Steps of the thread that requested it when that thread receives
+// the result. Since modules execute in parallel, the total number of
+// steps may exceed the budget of the requesting thread.
+//
+// By default, module names are used verbatim as cache keys and file
+// names. Clients that need to interpret names relative to the loading
+// module, or to canonicalize them, should provide a Resolve function.
+// (The go.starlark.net/modresolve package provides standard schemes.)
+//
+// The zero value is not usable; the Read field must be set.
+// A Loader must not be copied after first use.
+type Loader struct {
+	// Resolve, if non-nil, returns the canonical name of a module
+	// named in a load statement of the module whose canonical name
+	// is from, or in a call to Load if from is "". The canonical
+	// name is used as the cache key and file name of the module,
+	// and is passed to Read and Virtual.
+	// It may be called concurrently.
+	Resolve func(from, module string) (string, error)
+
+	// Virtual, if non-nil, returns the members of a module provided
+	// by the application, rather than loaded from a source file,
+	// such as a module defined by starlarkstruct.Module.
+	// If it returns a non-nil dict, which must be frozen,
+	// Read is not called. It may be called concurrently.
+	Virtual func(module string) StringDict
+
+	// Read returns the source of the named module.
+	// It is required, and may be called concurrently.
+	Read func(module string) ([]byte, error)
+
+	// Options, if non-nil, are the file options used to compile
+	// each module. If nil, default options are used.
+	Options *syntax.FileOptions
+
+	// Predeclared is the predeclared environment of each module.
+	Predeclared StringDict
+
+	// NewThread, if non-nil, returns a new Thread on which to
+	// execute the named module, typically one whose Print function
+	// or thread-local values have been set. The Loader sets the
+	// thread's Load function, step limit, and cancellation.
+	NewThread func(module string) *Thread
+
+	mu    sync.Mutex
+	cache map[string]*loadEntry // guarded by mu
+}
+
+// A loadEntry is a module that has been, or is being, loaded.
+type loadEntry struct {
+	module string
+	ready  chan struct{} // closed when globals and err are set
+
+	// Set before ready is closed.
+	globals StringDict
+	err     error
+	steps   uint64 // steps executed by module
+
+	// Guarded by Loader.mu.
+	requester *Thread      // thread that requested the load; nil after steps are charged
+	waitsFor  []*loadEntry // incomplete modules whose loads this one is awaiting
+}
+
+// Load returns the frozen globals of the specified module,
+// loading it if necessary. Its signature is that of Thread.Load.
+func (l *Loader) Load(thread *Thread, module string) (StringDict, error) {
+	return l.load(nil, thread, module)
+}
+
+// load waits for the specified module, which is loaded by the module
+// of entry from (or nil for a load not made by any module) on the
+// specified thread.
+func (l *Loader) load(from *loadEntry, thread *Thread, module string) (StringDict, error) {
+	if l.Resolve != nil {
+		var fromName string
+		if from != nil {
+			fromName = from.module
+		}
+		var err error
+		module, err = l.Resolve(fromName, module)
+		if err != nil {
+			return nil, err
+		}
+	}
+	e := l.start(thread, module)
+
+	l.mu.Lock()
+	select {
+	case <-e.ready:
+		// Already loaded.
+	default:
+		if from != nil {
+			// Detect load cycles to avoid deadlocks.
+			if path := l.waitPath(e, from); path != nil {
+				l.mu.Unlock()
+				names := []string{from.module}
+				for _, e := range path {
+					names = append(names, e.module)
+				}
+				return nil, fmt.Errorf("cycle in load graph: %s", strings.Join(names, " -> "))
+			}
+			from.waitsFor = append(from.waitsFor, e)
+		}
+	}
+	l.mu.Unlock()
+
+	<-e.ready
+
+	l.mu.Lock()
+	if from != nil {
+		if i := slices.Index(from.waitsFor, e); i >= 0 {
+			from.waitsFor = slices.Delete(from.waitsFor, i, i+1)
+		}
+	}
+	charge := e.requester == thread
+	if charge {
+		e.requester = nil
+	}
+	l.mu.Unlock()
+
+	if charge {
+		thread.Steps += e.steps
+	}
+	return e.globals, e.err
+}
+
+// waitPath returns the path from e to target in the graph of
+// incomplete modules awaiting the loading of other modules,
+// or nil if there is none. Precondition: l.mu is held.
+func (l *Loader) waitPath(e, target *loadEntry) []*loadEntry {
+	seen := make(map[*loadEntry]bool)
+	var visit func(e *loadEntry) []*loadEntry
+	visit = func(e *loadEntry) []*loadEntry {
+		if e == target {
+			return []*loadEntry{e}
+		}
+		if !seen[e] {
+			seen[e] = true
+			for _, dep := range e.waitsFor {
+				if path := visit(dep); path != nil {
+					return append([]*loadEntry{e}, path...)
+				}
+			}
+		}
+		return nil
+	}
+	return visit(e)
+}
+
+// start returns the entry for the specified module, starting a
+// goroutine to load it if it was not already requested.
+// The requesting thread determines the budget of the new goroutine.
+func (l *Loader) start(requester *Thread, module string) *loadEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.cache[module]
+	if !ok {
+		e = &loadEntry{
+			module:    module,
+			ready:     make(chan struct{}),
+			requester: requester,
+		}
+		if l.cache == nil {
+			l.cache = make(map[string]*loadEntry)
+		}
+		l.cache[module] = e
+
+		// Compute the budget now, as the requester may be
+		// running by the time the new goroutine starts.
+		var maxSteps uint64
+		if requester != nil {
+			maxSteps, _ = requester.remainingSteps()
+		}
+		go l.exec(e, requester, maxSteps)
+	}
+	return e
+}
+
+// exec loads the module of entry e and marks it ready.
+// If maxSteps is nonzero, it limits the steps executed by the module.
+func (l *Loader) exec(e *loadEntry, requester *Thread, maxSteps uint64) {
+	defer close(e.ready)
+
+	var thread *Thread
+	if l.NewThread != nil {
+		thread = l.NewThread(e.module)
+	} else {
+		thread = &Thread{Name: "load " + e.module}
+	}
+	thread.Load = func(thread *Thread, module string) (StringDict, error) {
+		return l.load(e, thread, module)
+	}
+	if maxSteps > 0 {
+		thread.SetMaxExecutionSteps(maxSteps)
+	}
+	if requester != nil {
+		defer requester.onCancel(thread.Cancel)()
+	}
+
+	if l.Virtual != nil {
+		if globals := l.Virtual(e.module); globals != nil {
+			e.globals = globals
+			return
+		}
+	}
+
+	src, err := l.Read(e.module)
+	if err != nil {
+		e.err = err
+		return
+	}
+	opts := l.Options
+	if opts == nil {
+		opts = new(syntax.FileOptions)
+	}
+	_, prog, err := SourceProgramOptions(opts, e.module, src, l.Predeclared.Has)
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	// Start loading all dependencies in parallel.
+	// Errors are reported later, by load.
+	for i := range prog.NumLoads() {
+		module, _ := prog.Load(i)
+		if l.Resolve != nil {
+			if module, err = l.Resolve(e.module, module); err != nil {
+				continue
+			}
+		}
+		l.start(thread, module)
+	}
+
+	globals, err := prog.Init(thread, l.Predeclared)
+	globals.Freeze()
+	e.globals, e.err, e.steps = globals, err, thread.Steps
+}
@@ -0,0 +1,193 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark_test
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// fakeFiles returns a Loader.Read function for an in-memory file system
+// that counts the reads of each file.
+func fakeFiles(files map[string]string, reads map[string]*atomic.Int32) func(string) ([]byte, error) {
+	return func(module string) ([]byte, error) {
+		src, ok := files[module]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		if reads != nil {
+			reads[module].Add(1)
+		}
+		return []byte(src), nil
+	}
+}
+
+// ExampleLoader demonstrates the standard concurrent loader.
+func ExampleLoader() {
+	files := map[string]string{
+		"a.star": `a = 1; print("loaded a")`,
+		"b.star": `load("a.star", "a"); b = a * 3`,
+		"c.star": `load("a.star", "a"); load("b.star", "b"); c = a + b`,
+	}
+	loader := &starlark.Loader{
+		Read: fakeFiles(files, nil),
+		NewThread: func(module string) *starlark.Thread {
+			return &starlark.Thread{
+				Name:  module,
+				Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
+			}
+		},
+	}
+	thread := &starlark.Thread{Load: loader.Load}
+	globals, err := loader.Load(thread, "c.star")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(globals["c"])
+
+	// Output:
+	// loaded a
+	// 4
+}
+
+func TestLoaderDuplicateSuppression(t *testing.T) {
+	files := map[string]string{
+		"a.star": `a = [1]`,
+		"b.star": `load("a.star", "a"); b = a`,
+		"c.star": `load("a.star", "a"); c = a`,
+		"d.star": `load("b.star", "b"); load("c.star", "c"); d = b + c`,
+	}
+	reads := make(map[string]*atomic.Int32)
+	for name := range files {
+		reads[name] = new(atomic.Int32)
+	}
+	loader := &starlark.Loader{Read: fakeFiles(files, reads)}
+
+	// Load all modules concurrently.
+	errs := make(chan error)
+	for name := range files {
+		go func() {
+			_, err := loader.Load(new(starlark.Thread), name)
+			errs <- err
+		}()
+	}
+	for range files {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, n := range reads {
+		if n.Load() != 1 {
+			t.Errorf("%s was read %d times, want 1", name, n.Load())
+		}
+	}
+
+	// Results are shared and frozen.
+	a, _ := loader.Load(new(starlark.Thread), "a.star")
+	b, _ := loader.Load(new(starlark.Thread), "b.star")
+	if a["a"] != b["b"] {
+		t.Errorf("b.star did not share a.star's value")
+	}
+	if err := a["a"].(*starlark.List).Append(starlark.None); err == nil {
+		t.Errorf("loaded list is not frozen")
+	}
+}
+
+func TestLoaderCycle(t *testing.T) {
+	files := map[string]string{
+		"a.star": `load("b.star", "b"); a = 1`,
+		"b.star": `load("c.star", "c"); b = 1`,
+		"c.star": `load("a.star", "a"); c = 1`,
+		"d.star": `load("d.star", "x"); d = 1`,
+	}
+	loader := &starlark.Loader{Read: fakeFiles(files, nil)}
+
+	// Start all three modules of the cycle at once,
+	// so that each is being loaded by a different goroutine.
+	errs := make(chan error)
+	for _, name := range []string{"a.star", "b.star", "c.star"} {
+		go func() {
+			_, err := loader.Load(new(starlark.Thread), name)
+			errs <- err
+		}()
+	}
+	for range 3 {
+		err := <-errs
+		if err == nil || !strings.Contains(err.Error(), "cycle in load graph: ") {
+			t.Fatalf("got error %v, want cycle", err)
+		}
+		// The error reports the complete cycle, e.g. "b.star -> c.star -> a.star -> b.star".
+		msg := err.Error()
+		cycle := msg[strings.Index(msg, "cycle in load graph: ")+len("cycle in load graph: "):]
+		if n := strings.Count(cycle, " -> "); n != 3 {
+			t.Errorf("got cycle %q, want 3 edges", cycle)
+		}
+	}
+
+	_, err := loader.Load(new(starlark.Thread), "d.star")
+	if want := "cannot load d.star: cycle in load graph: d.star -> d.star"; err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestLoaderBudget(t *testing.T) {
+	files := map[string]string{
+		"loop.star": `
+def f():
+    for x in range(1000000):
+        pass
+f()
+`,
+		"main.star": `load("loop.star", "f")`,
+	}
+
+	// Step limits propagate from the requesting thread.
+	loader := &starlark.Loader{Read: fakeFiles(files, nil)}
+	thread := new(starlark.Thread)
+	thread.SetMaxExecutionSteps(1000)
+	_, err := loader.Load(thread, "main.star")
+	if err == nil || !strings.Contains(err.Error(), "too many steps") {
+		t.Errorf("got error %v, want too many steps", err)
+	}
+
+	// Steps executed by a module are charged to its requester.
+	files["small.star"] = `x = [i for i in range(10)]`
+	loader = &starlark.Loader{Read: fakeFiles(files, nil)}
+	thread = new(starlark.Thread)
+	if _, err := loader.Load(thread, "small.star"); err != nil {
+		t.Fatal(err)
+	}
+	if thread.Steps == 0 {
+		t.Errorf("steps of loaded module were not charged to requester")
+	}
+}
+
+func TestLoaderCancel(t *testing.T) {
+	files := map[string]string{
+		"spin.star": `
+def spin():
+    for x in range(1 << 62):
+        pass
+spin()
+`,
+	}
+	loader := &starlark.Loader{Read: fakeFiles(files, nil)}
+	thread := new(starlark.Thread)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		thread.Cancel("enough")
+	}()
+	_, err := loader.Load(thread, "spin.star")
+	if err == nil || !strings.Contains(err.Error(), "Starlark computation cancelled: enough") {
+		t.Errorf("got error %v, want cancellation", err)
+	}
+}
@@ -7,6 +7,8 @@ package starlark
 // This file defines a simple execution-time profiler for Starlark.
 // It measures the wall time spent executing Starlark code, and emits a
 // gzipped protocol message in pprof format (github.com/google/pprof).
+// It may instead measure the interpreter steps executed, or the values
+// allocated, by each call stack (see ProfileKind).
 //
 // When profiling is enabled, the interpreter calls the profiler to
 // indicate the start and end of each "span" or time interval. A leaf
@@ -41,6 +43,12 @@ package starlark
 // Go handler for some other signal to read the stack pointer of
 // the interrupted thread.
 //
+// The step and allocation profiles are not sampled: each span records
+// exactly the steps executed, and the values allocated by each
+// instruction, while its frame was at the top of the stack. These
+// numbers depend only on the program, so they are reproducible across
+// machines and runs, unlike wall time.
+//
 // Two caveats:
 // (1) it is tempting to send the leaf Frame directly to the profiler
 // goroutine instead of making a copy of the stack, since a Frame is a
@@ -54,7 +62,6 @@ package starlark
 // values to keep their free variables live much longer than necessary.
 
 // TODO(adonovan):
-// - make Start/Stop fully thread-safe.
 // - fix the pc hack.
 // - experiment with other values of quantum.
 
@@ -67,6 +74,7 @@ import (
 	"io"
 	"log"
 	"reflect"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
@@ -74,63 +82,191 @@ import (
 	"go.starlark.net/syntax"
 )
 
-// StartProfile enables time profiling of all Starlark threads,
-// and writes a profile in pprof format to w.
-// It must be followed by a call to StopProfiler to stop
-// the profiler and finalize the profile.
+// A Profiler measures the execution of the Starlark threads to which
+// it is attached by Thread.SetProfiler, and writes a profile in pprof
+// format. Several threads may share a Profiler, whose profile then
+// aggregates their execution; each sample is labeled with the name of
+// its thread, if any.
 //
-// StartProfile returns an error if profiling was already enabled.
+// A Profiler may be started and stopped at any time, even while its
+// threads are executing, and may be restarted after it is stopped.
+// Its methods may be called concurrently.
 //
-// StartProfile must not be called concurrently with Starlark execution.
-func StartProfile(w io.Writer) error {
-	if !profiler.on.CompareAndSwap(false, true) {
+// The zero value is a stopped wall-time Profiler, ready for use.
+type Profiler struct {
+	// Kind is the kind of profile. It is read by Start,
+	// and must not be changed while the profiler is running.
+	Kind ProfileKind
+
+	running atomic.Bool   // true => profiler running (a hint; see send)
+	kind    atomic.Int32  // ProfileKind of the current run
+	gen     atomic.Uint64 // generation of the current run (see profGen)
+	start   atomic.Int64  // nanotime of Start
+
+	mu     sync.Mutex
+	events chan *profEvent // profile events from interpreter threads; nil unless running
+	done   chan error      // conveys the result of the profiler goroutine
+}
+
+// A ProfileKind specifies what a Profiler measures.
+type ProfileKind int32
+
+const (
+	// WallTimeProfile measures the wall time, in nanoseconds,
+	// spent executing each call stack. It is sampled at
+	// intervals of 10ms of each thread's execution.
+	WallTimeProfile ProfileKind = iota
+
+	// StepProfile measures the interpreter steps executed by each
+	// call stack (see Thread.ExecutionSteps). Steps are executed
+	// only by Starlark functions, so built-in functions appear
+	// only as callers of Starlark functions.
+	StepProfile
+
+	// AllocProfile measures the number of values and the estimated
+	// number of bytes allocated at each site: the instructions that
+	// create lists, dicts, tuples, and functions, the operators
+	// whose results are new values, and calls to built-in functions,
+	// whose results are assumed to be new. Values that need no
+	// memory of their own, such as None, booleans, and small
+	// integers, are not counted.
+	AllocProfile
+)
+
+func (k ProfileKind) String() string {
+	switch k {
+	case WallTimeProfile:
+		return "wall"
+	case StepProfile:
+		return "steps"
+	case AllocProfile:
+		return "allocs"
+	}
+	return fmt.Sprintf("ProfileKind(%d)", int(k))
+}
+
+// profGen is the number of the most recent run of any Profiler.
+// A frame records the generation of its current span so that
+// a span that began under another run is not counted.
+var profGen atomic.Uint64
+
+// Start starts the profiler, which writes its profile to w until Stop
+// is called. It returns an error if the profiler is already running.
+func (p *Profiler) Start(w io.Writer) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.events != nil {
 		return fmt.Errorf("profiler already running")
 	}
+	if p.Kind < WallTimeProfile || p.Kind > AllocProfile {
+		return fmt.Errorf("invalid profile kind %v", p.Kind)
+	}
+	p.events = make(chan *profEvent, 1)
+	p.done = make(chan error)
+	p.kind.Store(int32(p.Kind))
+	p.gen.Store(profGen.Add(1))
+	p.start.Store(nanotime())
+	p.running.Store(true)
+	go profile(w, p.Kind, p.events, p.done)
+	return nil
+}
 
-	// TODO(adonovan): make the API fully concurrency-safe.
-	// The main challenge is racy reads/writes of profiler.events,
-	// and of send/close races on the channel it refers to.
-	// It's easy to solve them with a mutex but harder to do
-	// it efficiently.
+// Stop stops the profiler and finalizes the profile. It returns an
+// error if the profiler is not running or the profile could not be
+// completed. Spans of execution in progress when Stop is called are
+// not recorded.
+func (p *Profiler) Stop() error {
+	p.mu.Lock()
+	if p.events == nil {
+		p.mu.Unlock()
+		return fmt.Errorf("profiler not running")
+	}
+	p.running.Store(false)
+	close(p.events)
+	p.events = nil
+	done := p.done
+	p.mu.Unlock()
+
+	// Wait for the profiler goroutine to finish.
+	return <-done
+}
 
-	profiler.events = make(chan *profEvent, 1)
-	profiler.done = make(chan error)
+// send sends an event to the profiler goroutine, if it is still
+// running the generation in which the event was recorded.
+func (p *Profiler) send(ev *profEvent) {
+	p.mu.Lock()
+	if p.events != nil && p.gen.Load() == ev.gen {
+		p.events <- ev
+	}
+	p.mu.Unlock()
+}
 
-	go profile(w)
+// SetProfiler attaches the profiler p to the thread, replacing any
+// profiler previously attached; a nil p detaches it. The thread's
+// execution is measured whenever p is running. SetProfiler may be
+// called at any time, even while the thread is executing.
+//
+// A thread without a profiler of its own is measured by the profiler
+// of StartProfile, if it is running.
+func (thread *Thread) SetProfiler(p *Profiler) {
+	thread.profiler.Store(p)
+}
 
-	return nil
+// Profiler returns the profiler attached to the thread by SetProfiler,
+// or nil if none.
+func (thread *Thread) Profiler() *Profiler {
+	return thread.profiler.Load()
+}
+
+// StartProfile enables time profiling of all Starlark threads that
+// have no Profiler of their own (see Thread.SetProfiler), and writes a
+// profile in pprof format to w. It must be followed by a call to
+// StopProfile to stop the profiler and finalize the profile.
+//
+// StartProfile returns an error if profiling was already enabled.
+func StartProfile(w io.Writer) error {
+	return globalProfiler.Start(w)
 }
 
 // StopProfile stops the profiler started by a prior call to
 // StartProfile and finalizes the profile. It returns an error if the
 // profile could not be completed.
-//
-// StopProfile must not be called concurrently with Starlark execution.
 func StopProfile() error {
-	// Terminate the profiler goroutine and get its result.
-	close(profiler.events)
-	err := <-profiler.done
-
-	profiler.done = nil
-	profiler.events = nil
-	profiler.on.Store(false)
-
-	return err
+	return globalProfiler.Stop()
 }
 
-// globals
-var profiler struct {
-	on     atomic.Bool     // true => profiler running
-	events chan *profEvent // profile events from interpreter threads
-	done   chan error      // indicates profiler goroutine is ready
+// globalProfiler is the profiler of StartProfile.
+var globalProfiler Profiler
+
+// activeProfiler returns the thread's profiler, if it is running, or nil.
+func (thread *Thread) activeProfiler() *Profiler {
+	p := thread.profiler.Load()
+	if p == nil {
+		p = &globalProfiler
+	}
+	if !p.running.Load() {
+		return nil
+	}
+	return p
 }
 
 func (thread *Thread) beginProfSpan() {
-	if profiler.events == nil {
+	thread.profAllocs = false
+	p := thread.activeProfiler()
+	if p == nil {
 		return // profiling not enabled
 	}
 
-	thread.frameAt(0).spanStart = nanotime()
+	fr := thread.frameAt(0)
+	fr.spanGen = p.gen.Load()
+	switch ProfileKind(p.kind.Load()) {
+	case WallTimeProfile:
+		fr.spanStart = nanotime()
+	case StepProfile:
+		fr.spanStart = int64(thread.Steps)
+	case AllocProfile:
+		thread.profAllocs = true
+	}
 }
 
 // TODO(adonovan): experiment with smaller values,
@@ -138,42 +274,170 @@ func (thread *Thread) beginProfSpan() {
 const quantum = 10 * time.Millisecond
 
 func (thread *Thread) endProfSpan() {
-	if profiler.events == nil {
+	allocs := thread.allocSites
+	thread.allocSites = allocs[:0]
+	thread.profAllocs = false
+
+	p := thread.activeProfiler()
+	if p == nil {
 		return // profiling not enabled
 	}
 
-	// Add the span to the thread's accumulator.
-	thread.proftime += time.Duration(nanotime() - thread.frameAt(0).spanStart)
-	if thread.proftime < quantum {
-		return
-	}
+	fr := thread.frameAt(0)
+	gen := p.gen.Load()
+	switch ProfileKind(p.kind.Load()) {
+	case WallTimeProfile:
+		// Add the span to the thread's accumulator,
+		// ignoring any part of it before the profiler started.
+		now := nanotime()
+		start := max(fr.spanStart, p.start.Load())
+		thread.proftime += time.Duration(now - start)
+		if thread.proftime < quantum {
+			return
+		}
 
-	// Only record complete quanta.
-	n := thread.proftime / quantum
-	thread.proftime -= n * quantum
+		// Only record complete quanta.
+		n := thread.proftime / quantum
+		thread.proftime -= n * quantum
+		p.send(thread.profEvent(gen, -1, int64(n*quantum), 0))
 
+	case StepProfile:
+		if fr.spanGen != gen {
+			return // span began before the profiler started
+		}
+		steps := int64(thread.Steps) - fr.spanStart
+		if steps <= 0 {
+			return
+		}
+		// Don't count these steps again in the span of the
+		// caller, if it is a built-in whose span is still open.
+		if len(thread.stack) > 1 {
+			if caller := thread.frameAt(1); caller.spanGen == gen {
+				caller.spanStart += steps
+			}
+		}
+		p.send(thread.profEvent(gen, -1, steps, 0))
+
+	case AllocProfile:
+		if fr.spanGen != gen {
+			return // span began before the profiler started
+		}
+		for _, site := range allocs {
+			p.send(thread.profEvent(gen, int64(site.pc), site.count, site.bytes))
+		}
+	}
+}
+
+// profEvent returns a new profile event with the specified values
+// for the current call stack. If pc is non-negative, it replaces the
+// program counter of the topmost frame.
+func (thread *Thread) profEvent(gen uint64, pc int64, values ...int64) *profEvent {
 	// Copy the stack.
 	// (We can't save thread.frame because its pc will change.)
 	ev := &profEvent{
-		thread: thread,
-		time:   n * quantum,
+		thread: thread.Name,
+		gen:    gen,
 	}
+	copy(ev.values[:], values)
 	ev.stack = ev.stackSpace[:0]
 	for i := range thread.stack {
 		fr := thread.frameAt(i)
-		ev.stack = append(ev.stack, profFrame{
+		pf := profFrame{
 			pos: fr.Position(),
 			fn:  fr.Callable(),
 			pc:  fr.pc,
-		})
+		}
+		if i == 0 && pc >= 0 {
+			// Allocation sites are in Starlark functions.
+			pf.pc = uint32(pc)
+			pf.pos = fr.callable.(*Function).funcode.Position(pf.pc)
+		}
+		ev.stack = append(ev.stack, pf)
 	}
+	return ev
+}
 
-	profiler.events <- ev
+// An allocSite records the allocations of the current span
+// by one instruction of a Starlark function.
+type allocSite struct {
+	pc           uint32
+	count, bytes int64
 }
 
+// recordAlloc records the allocation of v by the instruction at pc
+// in the topmost frame. The interpreter calls it only when
+// thread.profAllocs is set.
+func (thread *Thread) recordAlloc(pc uint32, v Value) {
+	if size := allocSize(v); size > 0 {
+		thread.recordAllocBytes(pc, 1, size)
+	}
+}
+
+// recordAllocBytes records the allocation of count values and size
+// bytes by the instruction at pc in the topmost frame.
+func (thread *Thread) recordAllocBytes(pc uint32, count, size int64) {
+	for i := range thread.allocSites {
+		if site := &thread.allocSites[i]; site.pc == pc {
+			site.count += count
+			site.bytes += size
+			return
+		}
+	}
+	thread.allocSites = append(thread.allocSites, allocSite{pc, count, size})
+}
+
+// recordGrowth records the growth of the array of a list from
+// oldcap to newcap elements by the instruction at pc in the topmost
+// frame. It counts bytes but not values.
+func (thread *Thread) recordGrowth(pc uint32, oldcap, newcap int) {
+	if newcap > oldcap {
+		thread.recordAllocBytes(pc, 0, int64(newcap-oldcap)*int64(unsafe.Sizeof(Value(nil))))
+	}
+}
+
+// allocSize returns the estimated number of bytes allocated
+// for the value v, not including the values it refers to.
+func allocSize(v Value) int64 {
+	const (
+		wordSize  = int64(unsafe.Sizeof(uintptr(0)))
+		valueSize = int64(unsafe.Sizeof(Value(nil)))
+	)
+	switch v := v.(type) {
+	case NoneType, Bool:
+		return 0
+	case Int:
+		if _, big := v.get(); big != nil {
+			return int64(unsafe.Sizeof(*big)) + wordSize*int64(len(big.Bits()))
+		}
+		return 0
+	case Float:
+		return int64(unsafe.Sizeof(v))
+	case String:
+		return int64(unsafe.Sizeof(v)) + int64(len(v))
+	case Bytes:
+		return int64(unsafe.Sizeof(v)) + int64(len(v))
+	case Tuple:
+		return int64(unsafe.Sizeof(v)) + valueSize*int64(len(v))
+	case *List:
+		return int64(unsafe.Sizeof(*v)) + valueSize*int64(cap(v.elems))
+	case *Dict:
+		return int64(unsafe.Sizeof(*v)) + hashtableEntrySize*int64(v.Len())
+	case *Set:
+		return int64(unsafe.Sizeof(*v)) + hashtableEntrySize*int64(v.Len())
+	}
+	if t := reflect.TypeOf(v); t.Kind() == reflect.Pointer {
+		return int64(t.Elem().Size())
+	}
+	return int64(reflect.TypeOf(v).Size())
+}
+
+// hashtableEntrySize is the estimated size of an element of a Dict or Set.
+const hashtableEntrySize = int64(unsafe.Sizeof(entry{}))
+
 type profEvent struct {
-	thread     *Thread // currently unused
-	time       time.Duration
+	thread     string   // name of thread
+	gen        uint64   // generation of the profiler run
+	values     [2]int64 // sample values, which depend on the ProfileKind
 	stack      []profFrame
 	stackSpace [8]profFrame // initial space for stack
 }
@@ -185,8 +449,9 @@ type profFrame struct {
 }
 
 // profile is the profiler goroutine.
-// It runs until StopProfiler is called.
-func profile(w io.Writer) {
+// It runs until the events channel is closed by Profiler.Stop,
+// and then sends its result to done.
+func profile(w io.Writer, kind ProfileKind, events <-chan *profEvent, done chan<- error) {
 	// Field numbers from pprof protocol.
 	// See https://github.com/google/pprof/blob/master/proto/profile.proto
 	const (
@@ -322,28 +587,58 @@ func profile(w io.Writer) {
 		return id
 	}
 
-	wallNanos := new(bytes.Buffer)
-	wnenc := protoEncoder{w: wallNanos}
-	wnenc.int(ValueType_type, str("wall"))
-	wnenc.int(ValueType_unit, str("nanoseconds"))
+	valueType := func(typ, unit string) []byte {
+		vt := new(bytes.Buffer)
+		vtenc := protoEncoder{w: vt}
+		vtenc.int(ValueType_type, str(typ))
+		vtenc.int(ValueType_unit, str(unit))
+		return vt.Bytes()
+	}
 
 	// informational fields of Profile
-	enc.bytes(Profile_sample_type, wallNanos.Bytes())
-	enc.int(Profile_period, quantum.Nanoseconds())     // magnitude of sampling period
-	enc.bytes(Profile_period_type, wallNanos.Bytes())  // dimension and unit of period
+	var nvalues int
+	switch kind {
+	case WallTimeProfile:
+		wallNanos := valueType("wall", "nanoseconds")
+		nvalues = 1
+		enc.bytes(Profile_sample_type, wallNanos)
+		enc.int(Profile_period, quantum.Nanoseconds()) // magnitude of sampling period
+		enc.bytes(Profile_period_type, wallNanos)      // dimension and unit of period
+	case StepProfile:
+		steps := valueType("steps", "count")
+		nvalues = 1
+		enc.bytes(Profile_sample_type, steps)
+		enc.int(Profile_period, 1)
+		enc.bytes(Profile_period_type, steps)
+	case AllocProfile:
+		nvalues = 2
+		enc.bytes(Profile_sample_type, valueType("alloc_objects", "count"))
+		enc.bytes(Profile_sample_type, valueType("alloc_space", "bytes"))
+		enc.int(Profile_period, 1)
+		enc.bytes(Profile_period_type, valueType("space", "bytes"))
+	}
 	enc.int(Profile_time_nanos, time.Now().UnixNano()) // start (real) time of profile
 
 	startNano := nanotime()
 
 	// Read profile events from the channel
-	// until it is closed by StopProfiler.
-	for e := range profiler.events {
+	// until it is closed by Profiler.Stop.
+	for e := range events {
 		sample := new(bytes.Buffer)
 		sampleenc := protoEncoder{w: sample}
-		sampleenc.int(Sample_value, e.time.Nanoseconds()) // wall nanoseconds
+		for _, v := range e.values[:nvalues] {
+			sampleenc.int(Sample_value, v)
+		}
 		for _, fr := range e.stack {
 			sampleenc.uint(Sample_location_id, location(fr))
 		}
+		if e.thread != "" {
+			label := new(bytes.Buffer)
+			labelenc := protoEncoder{w: label}
+			labelenc.int(Label_key, str("thread"))
+			labelenc.int(Label_str, str(e.thread))
+			sampleenc.bytes(Sample_label, label.Bytes())
+		}
 		enc.bytes(Profile_sample, sample.Bytes())
 	}
 
@@ -354,7 +649,7 @@ func profile(w io.Writer) {
 	if flushErr := bufw.Flush(); err == nil {
 		err = flushErr
 	}
-	profiler.done <- err
+	done <- err
 }
 
 // nanotime returns the time in nanoseconds since process start.
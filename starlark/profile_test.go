@@ -10,7 +10,10 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"go.starlark.net/starlark"
 )
@@ -79,3 +82,173 @@ fibonacci(100000)
 		t.Logf("stdout=%v", cmd.Stdout)
 	}
 }
+
+// TestProfiler checks that a Profiler may be started and stopped
+// while the threads to which it is attached are executing, and that
+// its profile aggregates them, labeled by thread name.
+func TestProfiler(t *testing.T) {
+	prof, err := os.CreateTemp(t.TempDir(), "profiler_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prof.Close()
+
+	const src = `
+def loop():
+	x = 0
+	for i in range(100000000):
+		x += i
+		if stop():
+			return x
+
+loop()
+`
+	var stopped atomic.Bool
+	predeclared := starlark.StringDict{
+		"stop": starlark.NewBuiltin("stop", func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+			return starlark.Bool(stopped.Load()), nil
+		}),
+	}
+
+	var p starlark.Profiler
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "unprofiled"} {
+		thread := &starlark.Thread{Name: name}
+		if name != "unprofiled" {
+			thread.SetProfiler(&p)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := starlark.ExecFile(thread, name+".star", src, predeclared); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if err := p.Start(prof); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Start(prof); err == nil {
+		t.Error("second Start succeeded")
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err == nil {
+		t.Error("second Stop succeeded")
+	}
+	stopped.Store(true)
+	wg.Wait()
+
+	prof.Sync()
+	cmd := exec.Command("go", "tool", "pprof", "-traces", prof.Name())
+	cmd.Stderr = new(bytes.Buffer)
+	cmd.Stdout = new(bytes.Buffer)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("pprof failed: %v; output=<<%s>>", err, cmd.Stderr)
+	}
+	got := fmt.Sprint(cmd.Stdout)
+	for _, want := range []string{"thread:  a", "thread:  b", "a.star", "b.star", "loop"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output did not contain %q", want)
+		}
+	}
+	if strings.Contains(got, "unprofiled") {
+		t.Errorf("output contains unprofiled thread")
+	}
+	if t.Failed() {
+		t.Logf("stdout=%v", cmd.Stdout)
+	}
+}
+
+// runProfile executes src in a thread with a profiler of the specified
+// kind, and returns the thread and the output of pprof -top, with
+// additional arguments args.
+func runProfile(t *testing.T, kind starlark.ProfileKind, src string, args ...string) (*starlark.Thread, string) {
+	t.Helper()
+	prof, err := os.CreateTemp(t.TempDir(), "profile_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer prof.Close()
+
+	p := &starlark.Profiler{Kind: kind}
+	thread := &starlark.Thread{Name: "main"}
+	thread.SetProfiler(p)
+	if err := p.Start(prof); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := starlark.ExecFile(thread, "foo.star", src, nil); err != nil {
+		_ = p.Stop()
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	prof.Sync()
+
+	cmd := exec.Command("go", append(append([]string{"tool", "pprof", "-top"}, args...), prof.Name())...)
+	cmd.Stderr = new(bytes.Buffer)
+	cmd.Stdout = new(bytes.Buffer)
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("pprof failed: %v; output=<<%s>>", err, cmd.Stderr)
+	}
+	return thread, fmt.Sprint(cmd.Stdout)
+}
+
+func TestStepProfile(t *testing.T) {
+	const src = `
+def fib(n):
+	x, y = 1, 1
+	for i in range(n):
+		x, y = y, x+y
+	return y
+
+def sort(n):
+	return sorted(range(n), key=lambda x: -x)
+
+fib(1000)
+sort(100)
+`
+	thread, got := runProfile(t, starlark.StepProfile, src)
+
+	// Every step is attributed to exactly one stack.
+	want := fmt.Sprintf("Total samples = %d", thread.ExecutionSteps())
+	for _, want := range []string{"Type: steps", want, "fib", "sort", "lambda"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output did not contain %q", want)
+		}
+	}
+	if t.Failed() {
+		t.Logf("stdout=%v", got)
+	}
+}
+
+func TestAllocProfile(t *testing.T) {
+	const src = `
+def f(n):
+	return [[i] for i in range(n)] # 1 + n lists, and a range
+
+def g():
+	return 1 + 2 == 3 # no allocation
+
+f(100)
+g()
+`
+	// The top level allocates the two functions.
+	_, got := runProfile(t, starlark.AllocProfile, src, "-sample_index=alloc_objects")
+	for _, want := range []string{"Type: alloc_objects", "Total samples = 104", "102 ", " f\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output did not contain %q", want)
+		}
+	}
+	if strings.Contains(got, " g\n") {
+		t.Errorf("output contains g, which does not allocate")
+	}
+	if t.Failed() {
+		t.Logf("stdout=%v", got)
+	}
+}
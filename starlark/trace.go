@@ -0,0 +1,231 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark
+
+// This file defines the Tracer hook and a Tracer that writes
+// Chrome trace_event JSON, which may be viewed in Perfetto
+// (ui.perfetto.dev) or chrome://tracing.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"go.starlark.net/internal/compile"
+	"go.starlark.net/syntax"
+)
+
+// A Tracer observes the execution of the threads to which it is
+// attached by the Thread.Tracer field. The interpreter calls Begin
+// at the start of each span of execution, and End at its end, with
+// the same TraceSpan. Spans of a thread are properly nested.
+//
+// A Tracer shared by several threads must be safe for concurrent use.
+type Tracer interface {
+	Begin(thread *Thread, span TraceSpan)
+	End(thread *Thread, span TraceSpan, err error)
+}
+
+// A TraceSpan describes a span of execution observed by a Tracer.
+type TraceSpan struct {
+	Kind     TraceKind
+	Name     string          // name of function, module, or file:line of statement
+	Pos      syntax.Position // position of call, load, or statement
+	Callable Callable        // the called function (TraceCall only)
+}
+
+// A TraceKind is the kind of a TraceSpan.
+type TraceKind int8
+
+const (
+	TraceCall TraceKind = iota // a call of a function or built-in
+	TraceLoad                  // a call of Thread.Load by a load statement
+	TraceStmt                  // a top-level statement of a module
+)
+
+func (k TraceKind) String() string {
+	switch k {
+	case TraceCall:
+		return "call"
+	case TraceLoad:
+		return "load"
+	case TraceStmt:
+		return "stmt"
+	}
+	return fmt.Sprintf("TraceKind(%d)", int(k))
+}
+
+// traceCall reports to the thread's tracer the beginning of a call
+// of c from the frame below the top of the stack, if any, and returns
+// the span.
+func (thread *Thread) traceCall(c Callable) TraceSpan {
+	span := TraceSpan{Kind: TraceCall, Name: c.Name(), Callable: c}
+	if len(thread.stack) > 1 {
+		span.Pos = thread.frameAt(1).Position()
+	}
+	thread.Tracer.Begin(thread, span)
+	return span
+}
+
+// traceStmt reports to the tracer the end of the current top-level
+// statement, whose index is cur (or -1 if none), and the beginning of
+// the statement containing the instruction at pc, if they differ.
+// It returns the index of the statement containing pc, or -1 if none.
+//
+// The statements of a module do not appear in the code of its
+// toplevel function, so traceStmt identifies them by the line of
+// each instruction.
+func (thread *Thread) traceStmt(tracer Tracer, f *compile.Funcode, cur int, pc uint32) int {
+	pos := f.Position(pc)
+	next := sort.Search(len(f.StmtLines), func(i int) bool { return f.StmtLines[i][1] >= pos.Line })
+	if next == len(f.StmtLines) || f.StmtLines[next][0] > pos.Line {
+		next = -1
+	}
+	if next != cur {
+		if cur >= 0 {
+			tracer.End(thread, stmtSpan(f, cur), nil)
+		}
+		if next >= 0 {
+			tracer.Begin(thread, stmtSpan(f, next))
+		}
+	}
+	return next
+}
+
+// stmtSpan returns the span of the ith top-level statement of f.
+func stmtSpan(f *compile.Funcode, i int) TraceSpan {
+	pos := f.Pos // copy the filename
+	pos.Line, pos.Col = f.StmtLines[i][0], 1
+	return TraceSpan{
+		Kind: TraceStmt,
+		Name: fmt.Sprintf("%s:%d", pos.Filename(), pos.Line),
+		Pos:  pos,
+	}
+}
+
+// A ChromeTracer is a Tracer that writes the spans of its threads as
+// begin and end events in the JSON format of Chrome's trace_event
+// profiling system, which may be viewed in Perfetto
+// (ui.perfetto.dev) or chrome://tracing. Each thread is shown as a
+// separate track, labeled by its name.
+//
+// A ChromeTracer may be shared by many threads. Call Close to
+// complete the trace after they have finished.
+type ChromeTracer struct {
+	start time.Time
+
+	mu   sync.Mutex
+	out  *bufio.Writer
+	err  error           // first write error
+	n    int             // number of events written
+	tids map[*Thread]int // track of each thread
+}
+
+// NewChromeTracer returns a ChromeTracer that writes its trace to w.
+func NewChromeTracer(w io.Writer) *ChromeTracer {
+	t := &ChromeTracer{
+		start: time.Now(),
+		out:   bufio.NewWriter(w),
+		tids:  make(map[*Thread]int),
+	}
+	t.out.WriteString(`{"displayTimeUnit":"ms","traceEvents":[`)
+	return t
+}
+
+// chromeEvent is an event in trace_event format.
+type chromeEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Phase string         `json:"ph"`
+	Time  float64        `json:"ts"` // microseconds
+	Pid   int            `json:"pid"`
+	Tid   int            `json:"tid"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+func (t *ChromeTracer) Begin(thread *Thread, span TraceSpan) {
+	args := make(map[string]any)
+	if span.Pos.IsValid() {
+		args["pos"] = span.Pos.String()
+	}
+	if fn, ok := span.Callable.(callableWithPosition); ok {
+		args["def"] = fn.Position().String()
+	}
+	t.emit(thread, "B", span, args)
+}
+
+func (t *ChromeTracer) End(thread *Thread, span TraceSpan, err error) {
+	var args map[string]any
+	if err != nil {
+		args = map[string]any{"error": err.Error()}
+	}
+	t.emit(thread, "E", span, args)
+}
+
+func (t *ChromeTracer) emit(thread *Thread, phase string, span TraceSpan, args map[string]any) {
+	now := time.Since(t.start)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.out == nil {
+		return // closed
+	}
+	tid, ok := t.tids[thread]
+	if !ok {
+		tid = len(t.tids) + 1
+		t.tids[thread] = tid
+		name := thread.Name
+		if name == "" {
+			name = fmt.Sprintf("thread %d", tid)
+		}
+		t.write(chromeEvent{Name: "thread_name", Phase: "M", Pid: 1, Tid: tid, Args: map[string]any{"name": name}})
+	}
+	t.write(chromeEvent{
+		Name:  span.Name,
+		Cat:   span.Kind.String(),
+		Phase: phase,
+		Time:  float64(now.Nanoseconds()) / 1e3,
+		Pid:   1,
+		Tid:   tid,
+		Args:  args,
+	})
+}
+
+func (t *ChromeTracer) write(ev chromeEvent) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		panic(err) // can't happen
+	}
+	if t.n > 0 {
+		t.out.WriteString(",")
+	}
+	t.out.WriteString("\n")
+	if _, err := t.out.Write(data); err != nil && t.err == nil {
+		t.err = err
+	}
+	t.n++
+}
+
+// Close completes the trace, and returns the first error, if any,
+// encountered while writing it. Events reported after Close are
+// discarded.
+func (t *ChromeTracer) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.out == nil {
+		return fmt.Errorf("tracer already closed")
+	}
+	t.out.WriteString("\n]}\n")
+	if err := t.out.Flush(); err != nil && t.err == nil {
+		t.err = err
+	}
+	t.out = nil
+	t.tids = nil
+	return t.err
+}
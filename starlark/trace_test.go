@@ -0,0 +1,165 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// recorder is a Tracer that records each span as an indented line.
+type recorder struct {
+	lines []string
+	depth int
+}
+
+func (r *recorder) Begin(thread *starlark.Thread, span starlark.TraceSpan) {
+	r.lines = append(r.lines, fmt.Sprintf("%s%s %s @%s", strings.Repeat("  ", r.depth), span.Kind, span.Name, span.Pos))
+	r.depth++
+}
+
+func (r *recorder) End(thread *starlark.Thread, span starlark.TraceSpan, err error) {
+	r.depth--
+	if err != nil {
+		r.lines = append(r.lines, fmt.Sprintf("%s%s %s failed", strings.Repeat("  ", r.depth), span.Kind, span.Name))
+	}
+}
+
+func TestTracer(t *testing.T) {
+	const src = `load("lib", "twice")
+
+def f(x):
+    return twice(x) + len("abc")
+
+x = [f(i)
+     for i in range(2)]
+
+fail("oops")
+`
+	want := `call <toplevel> @<invalid>
+  stmt main.star:1 @main.star:1:1
+    load lib @main.star:1:1
+  stmt main.star:3 @main.star:3:1
+  stmt main.star:6 @main.star:6:1
+    call range @main.star:7:20
+    call f @main.star:6:7
+      call twice @main.star:4:17
+      call len @main.star:4:26
+    call f @main.star:6:7
+      call twice @main.star:4:17
+      call len @main.star:4:26
+  stmt main.star:9 @main.star:9:1
+    call fail @main.star:9:5
+    call fail failed
+  stmt main.star:9 failed
+call <toplevel> failed`
+
+	// Trace the program compiled from source, and the same program
+	// after a round trip through its serialized form.
+	_, prog, err := starlark.SourceProgramOptions(&syntax.FileOptions{}, "main.star", src, starlark.StringDict(nil).Has)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := prog.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := starlark.CompiledProgram(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name string
+		prog *starlark.Program
+	}{
+		{"source", prog},
+		{"compiled", compiled},
+	} {
+		r := new(recorder)
+		thread := &starlark.Thread{
+			Tracer: r,
+			Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+				return starlark.ExecFile(&starlark.Thread{}, module+".star", "def twice(x): return 2*x", nil)
+			},
+		}
+		_, err := test.prog.Init(thread, nil)
+		if err == nil || !strings.Contains(err.Error(), "oops") {
+			t.Fatalf("%s: got error %v, want oops", test.name, err)
+		}
+		if got := strings.Join(r.lines, "\n"); got != want {
+			t.Errorf("%s: got:\n%s\nwant:\n%s", test.name, got, want)
+		}
+		if r.depth != 0 {
+			t.Errorf("%s: unbalanced spans: depth %d", test.name, r.depth)
+		}
+	}
+}
+
+func TestChromeTracer(t *testing.T) {
+	var buf strings.Builder
+	tracer := starlark.NewChromeTracer(&buf)
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			thread := &starlark.Thread{Name: name, Tracer: tracer}
+			if _, err := starlark.ExecFile(thread, name+".star", "def f(): return str(1)\nf()\n", nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := tracer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var trace struct {
+		TraceEvents []struct {
+			Name, Cat, Ph string
+			Tid           int
+			Args          map[string]string
+		}
+	}
+	if err := json.Unmarshal([]byte(buf.String()), &trace); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, buf.String())
+	}
+	depth := make(map[int]int)      // depth of each track
+	names := make(map[string]int)   // track of each thread name
+	calls := make(map[int][]string) // calls on each track
+	for _, ev := range trace.TraceEvents {
+		switch ev.Ph {
+		case "M":
+			names[ev.Args["name"]] = ev.Tid
+		case "B":
+			depth[ev.Tid]++
+			if ev.Cat == "call" {
+				calls[ev.Tid] = append(calls[ev.Tid], ev.Name)
+			}
+		case "E":
+			if depth[ev.Tid]--; depth[ev.Tid] < 0 {
+				t.Errorf("unbalanced end event %s on track %d", ev.Name, ev.Tid)
+			}
+		}
+	}
+	if len(names) != 2 || names["a"] == names["b"] {
+		t.Errorf("got tracks %v, want separate tracks for a and b", names)
+	}
+	for name, tid := range names {
+		if depth[tid] != 0 {
+			t.Errorf("track %s: unbalanced events", name)
+		}
+		if got := strings.Join(calls[tid], " "); got != "<toplevel> f str" {
+			t.Errorf("track %s: got calls %s", name, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlark
+
+// This file defines the run-time checking of the annotated types of
+// function parameters and results, enabled by FileOptions.CheckTypes.
+// See go.starlark.net/typecheck for the type language.
+
+import (
+	"fmt"
+
+	"go.starlark.net/internal/types"
+)
+
+// checkParamTypes reports an error if the value of an annotated
+// parameter of fn does not have the annotated type.
+// Precondition: fn.funcode.ParamTypes != nil.
+func checkParamTypes(locals []Value, fn *Function) error {
+	f := fn.funcode
+	varargs, kwargs := -1, -1
+	if f.HasKwargs {
+		kwargs = f.NumParams - 1
+	}
+	if f.HasVarargs {
+		varargs = f.NumParams - 1
+		if f.HasKwargs {
+			varargs--
+		}
+	}
+	for i, t := range f.ParamTypes {
+		if t == nil {
+			continue
+		}
+		switch i {
+		case varargs:
+			for _, v := range locals[i].(Tuple) {
+				if !hasType(v, t) {
+					return typeError(fn, "parameter *"+f.Locals[i].Name, v, t)
+				}
+			}
+		case kwargs:
+			for k, v := range locals[i].(*Dict).Entries() {
+				if !hasType(v, t) {
+					return typeError(fn, "parameter "+string(k.(String)), v, t)
+				}
+			}
+		default:
+			if v := locals[i]; !hasType(v, t) {
+				return typeError(fn, "parameter "+f.Locals[i].Name, v, t)
+			}
+		}
+	}
+	return nil
+}
+
+// checkResultType reports an error if the result of fn
+// does not have the annotated type.
+// Precondition: fn.funcode.ResultType != nil.
+func checkResultType(fn *Function, result Value) error {
+	if t := fn.funcode.ResultType; !hasType(result, t) {
+		return typeError(fn, "return value", result, t)
+	}
+	return nil
+}
+
+func typeError(fn *Function, what string, v Value, t types.Type) error {
+	return fmt.Errorf("%s: for %s: got %s, want %s", fn.Name(), what, v.Type(), t)
+}
+
+// hasType reports whether the value v has type t.
+// It checks the elements of lists and dicts and the fields of records.
+func hasType(v Value, t types.Type) bool {
+	switch t := t.(type) {
+	case types.Named:
+		if t == types.Any {
+			return true
+		}
+		typ := v.Type()
+		return typ == string(t) || t == types.Float && typ == "int"
+
+	case *types.List:
+		list, ok := v.(*List)
+		if !ok {
+			return false
+		}
+		if t.Elem != types.Any {
+			for _, elem := range list.elems {
+				if !hasType(elem, t.Elem) {
+					return false
+				}
+			}
+		}
+		return true
+
+	case *types.Dict:
+		dict, ok := v.(*Dict)
+		if !ok {
+			return false
+		}
+		if t.Key != types.Any || t.Value != types.Any {
+			for k, v := range dict.Entries() {
+				if !hasType(k, t.Key) || !hasType(v, t.Value) {
+					return false
+				}
+			}
+		}
+		return true
+
+	case *types.Union:
+		for _, t := range t.Types {
+			if hasType(v, t) {
+				return true
+			}
+		}
+		return false
+
+	case *types.Record:
+		x, ok := v.(HasAttrs)
+		if !ok {
+			return false
+		}
+		for _, field := range t.Fields {
+			v, err := x.Attr(field.Name)
+			if err != nil || v == nil || !hasType(v, field.Type) {
+				return false
+			}
+		}
+		return true
+
+	case *types.Func:
+		_, ok := v.(Callable)
+		return ok
+	}
+	return false
+}
@@ -579,11 +579,11 @@ func (s String) Freeze()               {} // immutable
 func (s String) Truth() Bool           { return len(s) > 0 }
 func (s String) Hash() (uint32, error) { return hashString(string(s)), nil }
 func (s String) Len() int              { return len(s) } // bytes
-func (s String) Index(i int) Value     { return s[i : i+1] }
+func (s String) Index(i int) Value     { return byteStrings[s[i]] }
 
 func (s String) Slice(start, end, step int) Value {
 	if step == 1 {
-		return s[start:end]
+		return stringValue(string(s[start:end]))
 	}
 
 	sign := signum(step)
@@ -640,11 +640,9 @@ func (si stringElems) Iterate() Iterator     { return &stringElemsIterator{si, 0
 func (si stringElems) Len() int              { return len(si.s) }
 func (si stringElems) Index(i int) Value {
 	if si.ords {
-		return MakeInt(int(si.s[i]))
+		return intValue(int(si.s[i]))
 	} else {
-		// TODO(adonovan): opt: preallocate canonical 1-byte strings
-		// to avoid interface allocation.
-		return si.s[i : i+1]
+		return byteStrings[si.s[i]]
 	}
 }
 
@@ -702,10 +700,10 @@ func (it *stringCodepointsIterator) Next(p *Value) bool {
 		if r == utf8.RuneError {
 			*p = String(r)
 		} else {
-			*p = s[:sz]
+			*p = stringValue(string(s[:sz]))
 		}
 	} else {
-		*p = MakeInt(int(r))
+		*p = intValue(int(r))
 	}
 	it.i += sz
 	return true
@@ -1109,6 +1107,9 @@ func (t Tuple) Index(i int) Value { return t[i] }
 
 func (t Tuple) Slice(start, end, step int) Value {
 	if step == 1 {
+		if start >= end {
+			return emptyTuple
+		}
 		return t[start:end]
 	}
 
@@ -155,3 +155,44 @@ func TestParamDefault(t *testing.T) {
 		})
 	}
 }
+
+func TestInterner(t *testing.T) {
+	var in starlark.Interner
+	x := in.Intern("hello world"[:5])
+	y := in.InternBytes([]byte("hello"))
+	if x != starlark.String("hello") || y != x {
+		t.Errorf("Intern = %v, InternBytes = %v, want \"hello\"", x, y)
+	}
+	if n := testing.AllocsPerRun(100, func() { in.InternBytes([]byte("hello")) }); n > 0 {
+		t.Errorf("InternBytes of interned string: AllocsPerRun = %v, want none", n)
+	}
+	if in.Len() != 1 {
+		t.Errorf("Len = %d, want 1", in.Len())
+	}
+
+	// A nil Interner interns nothing.
+	var nilInterner *starlark.Interner
+	if got := nilInterner.Intern("abc"); got != starlark.String("abc") {
+		t.Errorf("nil Intern = %v, want \"abc\"", got)
+	}
+
+	// Strings longer than MaxLen are not interned.
+	in = starlark.Interner{MaxLen: 3}
+	in.Intern("abcd")
+	if in.Len() != 0 {
+		t.Errorf("Len = %d after interning long string, want 0", in.Len())
+	}
+}
+
+func TestStringIndexNoAlloc(t *testing.T) {
+	s := starlark.String("hello, 世界")
+	n := testing.AllocsPerRun(100, func() {
+		for i := range s.Len() {
+			_ = s.Index(i)
+		}
+		_ = s.Slice(1, 2, 1)
+	})
+	if n > 0 {
+		t.Errorf("AllocsPerRun = %v, want none", n)
+	}
+}
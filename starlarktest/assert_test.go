@@ -0,0 +1,69 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlarktest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarktest"
+)
+
+// errorLog is a Reporter that records the message of each error.
+type errorLog []string
+
+func (log *errorLog) Error(args ...any) {
+	msg := fmt.Sprint(args...)
+	msg = msg[strings.Index(msg, "Error: ")+len("Error: "):] // discard the backtrace
+	*log = append(*log, msg)
+}
+
+func TestAssertFailures(t *testing.T) {
+	for _, test := range []struct{ src, want string }{
+		{`assert.eq({"a": [1, 2, 3], "b": (1, "x"), "c": None}, {"a": [1, 2, 4], "b": (1, "y"), "d": True})`,
+			`values differ:
+  ["a"][2]: 3 != 4
+  ["b"][1]: "x" != "y"
+  ["c"]: None != (missing)
+  ["d"]: (missing) != True`},
+		{`assert.eq([1], [1, [2]])`, "values differ:\n  [1]: (missing) != [2]"},
+		{`assert.eq(1, "1")`, `1 != "1"`},
+		{`assert.eq(list(range(12)), [])`, "values differ:\n" +
+			"  [0]: 0 != (missing)\n  [1]: 1 != (missing)\n  [2]: 2 != (missing)\n" +
+			"  [3]: 3 != (missing)\n  [4]: 4 != (missing)\n  [5]: 5 != (missing)\n" +
+			"  [6]: 6 != (missing)\n  [7]: 7 != (missing)\n  [8]: 8 != (missing)\n" +
+			"  [9]: 9 != (missing)\n  ... and 2 more"},
+		{`assert.len({}, 1)`, "len({}) = 0, want 1"},
+		{`assert.is_none(0)`, "0 is not None"},
+		{`assert.approx(1.0, 1.1)`, "1.0 != 1.1 (difference 0.10000000000000009 exceeds tolerance)"},
+		{`assert.raises(lambda: None)`, "evaluation succeeded unexpectedly"},
+		{`assert.raises(lambda: fail("x"), "y")`, "regular expression (y) did not match error (fail: x)"},
+		{`assert.golden("assert.golden", {"k": [1, "three"], "empty": []})`,
+			`output differs from golden file testdata/assert.golden (run with -starlarktest.update to update it):
+@@ line 2 @@
+     "k": [
+         1,
+-        "two",
++        "three",
+     ],
+     "empty": [],`},
+		{`assert.golden("missing.golden", 1)`,
+			"golden file testdata/missing.golden does not exist (run with -starlarktest.update to create it)"},
+	} {
+		var log errorLog
+		thread := &starlark.Thread{Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return starlarktest.LoadAssertModule()
+		}}
+		starlarktest.SetReporter(thread, &log)
+		src := "load('assert.star', 'assert')\n" + test.src
+		if _, err := starlark.ExecFile(thread, "test.star", src, nil); err != nil {
+			t.Errorf("%s: %v", test.src, err)
+		} else if len(log) != 1 || log[0] != test.want {
+			t.Errorf("%s: got errors %q, want %q", test.src, log, test.want)
+		}
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlarktest
+
+// This file defines the generation of random Starlark values from
+// fuzzer input, and the shrinking of inputs that cause a Starlark
+// function to fail.
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+)
+
+// A Kind is a kind of value generated by Generate.
+type Kind int8
+
+const (
+	AnyKind    Kind = iota // any of the kinds below
+	IntKind                // starlark.Int
+	StringKind             // starlark.String
+	ListKind               // *starlark.List
+	DictKind               // *starlark.Dict
+)
+
+// A Schema constrains the values generated by Generate.
+// A nil *Schema, like the zero Schema, permits any value.
+type Schema struct {
+	Kind     Kind
+	Elem     *Schema // schema of list elements and dict values
+	Key      *Schema // schema of dict keys; only ints and strings are generated
+	MaxLen   int     // maximum length of strings, lists, and dicts; 0 means 8
+	Min, Max int64   // range of ints, if Min < Max
+}
+
+// maxDepth is the maximum depth of nesting of generated lists and dicts.
+const maxDepth = 4
+
+// fuzzSteps is the maximum number of execution steps of each call by FuzzFunction.
+const fuzzSteps = 1_000_000
+
+// A source is a deterministic source of choices decoded from fuzzer input.
+// When the input is exhausted, every choice is zero.
+type source []byte
+
+func (src *source) byte() byte {
+	if len(*src) == 0 {
+		return 0
+	}
+	b := (*src)[0]
+	*src = (*src)[1:]
+	return b
+}
+
+// uint64 returns an integer of up to eight bytes; small ones are likelier.
+func (src *source) uint64() uint64 {
+	var x uint64
+	for i := range int(src.byte() % 9) {
+		x |= uint64(src.byte()) << (8 * i)
+	}
+	return x
+}
+
+// len returns a length of at most max.
+func (src *source) len(max int) int {
+	return int(src.uint64() % uint64(max+1))
+}
+
+// Generate returns a Starlark value decoded from data, which is
+// typically provided by the Go fuzzer (see testing.F), and conforms
+// to the schema, which may be nil. The value is an int, a string,
+// or a list or dict of such values, possibly nested.
+// Generate is deterministic: the same input yields the same value.
+func Generate(data []byte, schema *Schema) starlark.Value {
+	src := source(data)
+	return src.generate(schema, 0)
+}
+
+func (src *source) generate(schema *Schema, depth int) starlark.Value {
+	if schema == nil {
+		schema = new(Schema)
+	}
+	kind := schema.Kind
+	if kind == AnyKind {
+		if depth < maxDepth {
+			kind = IntKind + Kind(src.byte()%4)
+		} else {
+			kind = IntKind + Kind(src.byte()%2)
+		}
+	}
+	maxLen := schema.MaxLen
+	if maxLen <= 0 {
+		maxLen = 8
+	}
+	switch kind {
+	case IntKind:
+		x := src.uint64()
+		if schema.Min < schema.Max {
+			span := uint64(schema.Max-schema.Min) + 1 // 0 => full range
+			if span != 0 {
+				x %= span
+			}
+			return starlark.MakeInt64(schema.Min + int64(x))
+		}
+		return starlark.MakeInt64(int64(x))
+	case StringKind:
+		n := src.len(maxLen)
+		var buf strings.Builder
+		for range n {
+			buf.WriteByte(src.byte())
+		}
+		return starlark.String(buf.String())
+	case ListKind:
+		n := src.len(maxLen)
+		elems := make([]starlark.Value, n)
+		for i := range elems {
+			elems[i] = src.generate(schema.Elem, depth+1)
+		}
+		return starlark.NewList(elems)
+	case DictKind:
+		n := src.len(maxLen)
+		dict := starlark.NewDict(n)
+		key := schema.Key
+		if key == nil || key.Kind != IntKind && key.Kind != StringKind {
+			kind := IntKind + Kind(src.byte()%2)
+			if key != nil {
+				key = &Schema{Kind: kind, MaxLen: key.MaxLen, Min: key.Min, Max: key.Max}
+			} else {
+				key = &Schema{Kind: kind}
+			}
+		}
+		for range n {
+			k := src.generate(key, depth+1)
+			dict.SetKey(k, src.generate(schema.Elem, depth+1))
+		}
+		return dict
+	}
+	panic(fmt.Sprintf("invalid kind %d", kind))
+}
+
+// CheckFunction calls FuzzFunction, and reports the minimal failing
+// call, if any, as an error of t. It is intended to be called from
+// the function passed to testing.F.Fuzz:
+//
+//	f.Fuzz(func(t *testing.T, data []byte) {
+//		starlarktest.CheckFunction(t, fn, data, schema)
+//	})
+func CheckFunction(t *testing.T, fn starlark.Callable, data []byte, schemas ...*Schema) {
+	t.Helper()
+	if args, err := FuzzFunction(fn, data, schemas...); err != nil {
+		t.Errorf("%s%s failed: %s", fn.Name(), args, backtrace(err))
+	}
+}
+
+// FuzzFunction calls fn with arguments generated from data, one for
+// each schema (or one of any kind if there are none), as if by
+// Generate. The call fails if it returns an error or False, reports
+// an error using the assert module, or exceeds a limit of execution
+// steps.
+//
+// If the call fails, FuzzFunction repeatedly shrinks the arguments,
+// by removing elements of strings, lists, and dicts, and moving ints
+// towards zero (or the schema's Min), until no smaller arguments
+// fail, and returns the smallest failing arguments and their error.
+// Otherwise it returns the arguments and a nil error.
+//
+// Each call has its own thread, and its own copy of the arguments.
+func FuzzFunction(fn starlark.Callable, data []byte, schemas ...*Schema) (starlark.Tuple, error) {
+	if len(schemas) == 0 {
+		schemas = []*Schema{nil}
+	}
+	src := source(data)
+	args := make(starlark.Tuple, len(schemas))
+	for i, schema := range schemas {
+		args[i] = src.generate(schema, 0)
+	}
+	err := fuzzCall(fn, args)
+	if err == nil {
+		return args, nil
+	}
+
+	// Greedily replace an argument by a smaller one that also fails.
+	const maxCalls = 1000
+	calls := 0
+shrink:
+	for calls < maxCalls {
+		for i := range args {
+			for _, x := range shrinkValue(args[i], schemas[i]) {
+				if calls++; calls > maxCalls {
+					break shrink
+				}
+				smaller := append(starlark.Tuple(nil), args...)
+				smaller[i] = x
+				if e := fuzzCall(fn, smaller); e != nil {
+					args, err = smaller, e
+					continue shrink
+				}
+			}
+		}
+		break // no smaller arguments fail
+	}
+	return args, err
+}
+
+// fuzzCall calls fn with copies of args, and returns the failure, if any.
+func fuzzCall(fn starlark.Callable, args starlark.Tuple) error {
+	var errs fuzzErrors
+	thread := &starlark.Thread{Name: "fuzz", Print: func(*starlark.Thread, string) {}}
+	thread.SetMaxExecutionSteps(fuzzSteps)
+	SetReporter(thread, &errs)
+	copied := make(starlark.Tuple, len(args))
+	for i, arg := range args {
+		copied[i] = copyValue(arg)
+	}
+	result, err := starlark.Call(thread, fn, copied, nil)
+	if err != nil {
+		return err
+	} else if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "\n"))
+	} else if result == starlark.False {
+		return errors.New("returned False")
+	}
+	return nil
+}
+
+// fuzzErrors is a Reporter that records the errors of a fuzzed call.
+type fuzzErrors []string
+
+func (errs *fuzzErrors) Error(args ...any) { *errs = append(*errs, fmt.Sprint(args...)) }
+
+// copyValue returns a deep copy of a generated value.
+func copyValue(v starlark.Value) starlark.Value {
+	switch v := v.(type) {
+	case *starlark.List:
+		elems := make([]starlark.Value, v.Len())
+		for i := range elems {
+			elems[i] = copyValue(v.Index(i))
+		}
+		return starlark.NewList(elems)
+	case *starlark.Dict:
+		dict := starlark.NewDict(v.Len())
+		for _, item := range v.Items() {
+			dict.SetKey(item[0], copyValue(item[1]))
+		}
+		return dict
+	}
+	return v
+}
+
+// shrinkValue returns a list of values smaller than the generated
+// value v, which conform to its schema, roughly in decreasing order
+// of the reduction.
+func shrinkValue(v starlark.Value, schema *Schema) []starlark.Value {
+	if schema == nil {
+		schema = new(Schema)
+	}
+	var smaller []starlark.Value
+	switch v := v.(type) {
+	case starlark.Int:
+		x, ok := v.Int64()
+		if !ok {
+			break
+		}
+		target := int64(0)
+		if schema.Min < schema.Max {
+			target = max(schema.Min, min(schema.Max, 0))
+		}
+		if x == target {
+			break
+		}
+		smaller = append(smaller, starlark.MakeInt64(target))
+		if mid := target + (x-target)/2; mid != target && mid != x {
+			smaller = append(smaller, starlark.MakeInt64(mid))
+		}
+		if x > target {
+			smaller = append(smaller, starlark.MakeInt64(x-1))
+		} else {
+			smaller = append(smaller, starlark.MakeInt64(x+1))
+		}
+	case starlark.String:
+		s := string(v)
+		if s == "" {
+			break
+		}
+		smaller = append(smaller, starlark.String(""))
+		if len(s) > 1 {
+			smaller = append(smaller, starlark.String(s[:len(s)/2]), starlark.String(s[len(s)/2:]))
+			for i := range s {
+				smaller = append(smaller, starlark.String(s[:i]+s[i+1:]))
+			}
+		}
+	case *starlark.List:
+		n := v.Len()
+		elems := func(i int, x starlark.Value) starlark.Value {
+			list := make([]starlark.Value, 0, n)
+			for j := range n {
+				switch {
+				case j != i:
+					list = append(list, v.Index(j))
+				case x != nil:
+					list = append(list, x)
+				}
+			}
+			return starlark.NewList(list)
+		}
+		if n == 0 {
+			break
+		}
+		smaller = append(smaller, starlark.NewList(nil))
+		if n > 1 {
+			for i := range n {
+				smaller = append(smaller, elems(i, nil))
+			}
+		}
+		for i := range n {
+			for _, x := range shrinkValue(v.Index(i), schema.Elem) {
+				smaller = append(smaller, elems(i, x))
+			}
+		}
+	case *starlark.Dict:
+		items := v.Items()
+		dict := func(i int, x starlark.Value) starlark.Value {
+			dict := starlark.NewDict(len(items))
+			for j, item := range items {
+				switch {
+				case j != i:
+					dict.SetKey(item[0], item[1])
+				case x != nil:
+					dict.SetKey(item[0], x)
+				}
+			}
+			return dict
+		}
+		if len(items) == 0 {
+			break
+		}
+		smaller = append(smaller, starlark.NewDict(0))
+		if len(items) > 1 {
+			for i := range items {
+				smaller = append(smaller, dict(i, nil))
+			}
+		}
+		for i, item := range items {
+			for _, x := range shrinkValue(item[1], schema.Elem) {
+				smaller = append(smaller, dict(i, x))
+			}
+		}
+	}
+	return smaller
+}
@@ -0,0 +1,107 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlarktest_test
+
+import (
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarktest"
+)
+
+func TestGenerate(t *testing.T) {
+	schema := &starlarktest.Schema{
+		Kind: starlarktest.DictKind,
+		Key:  &starlarktest.Schema{Kind: starlarktest.StringKind, MaxLen: 3},
+		Elem: &starlarktest.Schema{
+			Kind: starlarktest.ListKind,
+			Elem: &starlarktest.Schema{Kind: starlarktest.IntKind, Min: -5, Max: 5},
+		},
+	}
+	data := []byte("the quick brown fox jumps over the lazy dog")
+	v := starlarktest.Generate(data, schema)
+	if got := starlarktest.Generate(data, schema); got.String() != v.String() {
+		t.Errorf("Generate is not deterministic: %s, then %s", v, got)
+	}
+	dict, ok := v.(*starlark.Dict)
+	if !ok || dict.Len() == 0 {
+		t.Fatalf("got %s, want non-empty dict", v)
+	}
+	for _, item := range dict.Items() {
+		if k, ok := item[0].(starlark.String); !ok || len(k) > 3 {
+			t.Errorf("invalid key %s", item[0])
+		}
+		list, ok := item[1].(*starlark.List)
+		if !ok {
+			t.Fatalf("invalid value %s", item[1])
+		}
+		for i := range list.Len() {
+			if x, ok := starlark.AsInt32(list.Index(i)); ok != nil || x < -5 || x > 5 {
+				t.Errorf("invalid element %s", list.Index(i))
+			}
+		}
+	}
+
+	// Empty input yields the zero value of the schema's kind.
+	if got := starlarktest.Generate(nil, schema); got.String() != "{}" {
+		t.Errorf("Generate(nil) = %s, want {}", got)
+	}
+}
+
+func TestFuzzFunction(t *testing.T) {
+	globals, err := starlark.ExecFile(new(starlark.Thread), "fuzz.star", `
+def has_no_negative_third(xs, s):
+    return len(xs) < 3 or xs[2] >= 0 or "a" not in s
+
+def ok(x):
+    return True
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ints := &starlarktest.Schema{Kind: starlarktest.ListKind, Elem: &starlarktest.Schema{Kind: starlarktest.IntKind, Min: -100, Max: 100}}
+	strs := &starlarktest.Schema{Kind: starlarktest.StringKind}
+
+	// The input encodes the arguments ([-50, -50, -50], "xaay"):
+	// each length or int is a count of bytes, then the bytes.
+	data := []byte{1, 3, 1, 50, 1, 50, 1, 50, 1, 4, 'x', 'a', 'a', 'y'}
+	fn := globals["has_no_negative_third"].(starlark.Callable)
+	args, err := starlarktest.FuzzFunction(fn, data, ints, strs)
+	if err == nil || !strings.Contains(err.Error(), "returned False") {
+		t.Errorf("got error %v, want returned False", err)
+	}
+	// The failing input is shrunk to the minimal one.
+	if got, want := args.String(), `([0, 0, -1], "a")`; got != want {
+		t.Errorf("shrunk arguments: got %s, want %s", got, want)
+	}
+
+	if args, err := starlarktest.FuzzFunction(globals["ok"].(starlark.Callable), []byte("xyz")); err != nil {
+		t.Errorf("ok%s failed: %v", args, err)
+	}
+}
+
+func FuzzSorted(f *testing.F) {
+	thread := &starlark.Thread{Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+		return starlarktest.LoadAssertModule()
+	}}
+	globals, err := starlark.ExecFile(thread, "sorted.star", `
+load("assert.star", "assert")
+
+def check(xs):
+    ys = sorted(xs)
+    assert.eq(len(ys), len(xs))
+    assert.eq(sorted(ys), ys)
+`, nil)
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add([]byte{})
+	f.Add([]byte("\x03\x01\x05\x01\x02\x01\x07"))
+	schema := &starlarktest.Schema{Kind: starlarktest.ListKind, Elem: &starlarktest.Schema{Kind: starlarktest.IntKind}}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		starlarktest.CheckFunction(t, globals["check"].(starlark.Callable), data, schema)
+	})
+}
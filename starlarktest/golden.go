@@ -0,0 +1,308 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlarktest
+
+// This file defines golden-file comparison.
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// update is the value of the -starlarktest.update flag, which causes
+// golden files to be written instead of compared.
+var update = flag.Bool("starlarktest.update", false, "write golden files instead of comparing them")
+
+const goldenDirKey = "starlarktest.goldenDir"
+
+// SetGoldenDir sets the directory of the golden files of the thread's
+// calls of assert.golden. By default it is "testdata"; RunFiles sets
+// it to the directory of each test file.
+func SetGoldenDir(thread *starlark.Thread, dir string) {
+	thread.SetLocal(goldenDirKey, dir)
+}
+
+// golden(name, value) compares the text of value with the golden file
+// of the specified name, or writes the file if the -starlarktest.update
+// flag is set. It returns a description of the difference, or None.
+func golden(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var value starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &name, &value); err != nil {
+		return nil, err
+	}
+	dir, ok := thread.Local(goldenDirKey).(string)
+	if !ok {
+		dir = "testdata"
+	}
+	var text string
+	if s, ok := value.(starlark.String); ok {
+		text = string(s)
+	} else {
+		text = Format(value) + "\n"
+	}
+	msg, err := checkGolden(filepath.Join(dir, name), []byte(text))
+	if err != nil {
+		return nil, err
+	}
+	if msg == "" {
+		return starlark.None, nil
+	}
+	return starlark.String(msg), nil
+}
+
+// An Encoding is a serialization of Starlark values used by CheckGolden.
+type Encoding int8
+
+const (
+	StarlarkEncoding Encoding = iota // Starlark literals, as by Format
+	JSONEncoding                     // indented JSON
+)
+
+// CheckGolden executes the Starlark file, and compares a serialization
+// of its frozen globals with the content of the golden file, reporting
+// the lines that differ as an error of t. If the -starlarktest.update
+// flag is set, it writes the golden file instead.
+//
+// The serialization, whose encoding is opts.Encoding, is deterministic:
+// globals whose names begin with "_" are omitted, and the others appear
+// in order of their names. In StarlarkEncoding, each global is a line
+// "name = value", in which value is formatted as by Format. In
+// JSONEncoding, the globals are the members of an object, in which lists,
+// tuples, and sets are arrays, dicts with string keys are objects, and
+// values that have no JSON equivalent, such as functions, are
+// represented by the strings of their Starlark representations.
+//
+// The file is executed as by RunFiles, with the predeclared names and
+// loader of opts, which may be nil.
+func CheckGolden(t *testing.T, filename, golden string, opts *Options) {
+	t.Helper()
+	if opts == nil {
+		opts = new(Options)
+	}
+	fileOpts := opts.FileOptions
+	if fileOpts == nil {
+		fileOpts = syntax.LegacyFileOptions()
+	}
+	_, prog, err := starlark.SourceProgramOptions(fileOpts, filename, nil, opts.Predeclared.Has)
+	if err != nil {
+		t.Fatal(err)
+	}
+	globals, err := prog.Init(newTestThread(t, filename, opts), opts.Predeclared)
+	if err != nil {
+		t.Fatal(backtrace(err))
+	}
+	globals.Freeze()
+
+	var buf bytes.Buffer
+	switch opts.Encoding {
+	case StarlarkEncoding:
+		for _, name := range globals.Keys() {
+			if !strings.HasPrefix(name, "_") {
+				fmt.Fprintf(&buf, "%s = %s\n", name, Format(globals[name]))
+			}
+		}
+	case JSONEncoding:
+		object := make(map[string]any)
+		for name, v := range globals {
+			if !strings.HasPrefix(name, "_") {
+				object[name] = toJSON(v)
+			}
+		}
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(object); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("invalid encoding %d", opts.Encoding)
+	}
+
+	msg, err := checkGolden(golden, buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg != "" {
+		t.Error(msg)
+	}
+}
+
+// toJSON returns the value that encoding/json encodes as the JSON
+// equivalent of a Starlark value; see CheckGolden.
+func toJSON(v starlark.Value) any {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return nil
+	case starlark.Bool:
+		return bool(v)
+	case starlark.Int:
+		return json.Number(v.String())
+	case starlark.Float:
+		if f := float64(v); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f
+		}
+	case starlark.String:
+		return string(v)
+	case *starlark.Dict:
+		object := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			k, ok := starlark.AsString(item[0])
+			if !ok {
+				return v.String()
+			}
+			object[k] = toJSON(item[1])
+		}
+		return object
+	case starlark.Iterable:
+		switch v.(type) {
+		case *starlark.List, starlark.Tuple, *starlark.Set:
+			array := []any{}
+			iter := v.Iterate()
+			defer iter.Done()
+			var x starlark.Value
+			for iter.Next(&x) {
+				array = append(array, toJSON(x))
+			}
+			return array
+		}
+	}
+	return v.String()
+}
+
+// checkGolden compares got with the content of the golden file,
+// or writes the file if the -starlarktest.update flag is set.
+// It returns a description of the difference, or "" if none.
+func checkGolden(filename string, got []byte) (string, error) {
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			return "", err
+		}
+		return "", os.WriteFile(filename, got, 0666)
+	}
+	want, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return fmt.Sprintf("golden file %s does not exist (run with -starlarktest.update to create it)", filename), nil
+	} else if err != nil {
+		return "", err
+	}
+	if bytes.Equal(got, want) {
+		return "", nil
+	}
+	return fmt.Sprintf("output differs from golden file %s (run with -starlarktest.update to update it):\n%s",
+		filename, lineDiff(string(want), string(got))), nil
+}
+
+// lineDiff returns a description of the lines that differ between
+// want and got: the differing region, after the removal of the lines
+// they have in common at the start and end, prefixed by "-" (want)
+// and "+" (got), with up to two lines of context.
+func lineDiff(want, got string) string {
+	x := strings.SplitAfter(want, "\n")
+	y := strings.SplitAfter(got, "\n")
+	i := 0
+	for i < len(x) && i < len(y) && x[i] == y[i] {
+		i++
+	}
+	j := 0
+	for j < len(x)-i && j < len(y)-i && x[len(x)-1-j] == y[len(y)-1-j] {
+		j++
+	}
+	const context = 2
+	var buf strings.Builder
+	line := func(prefix, s string) {
+		buf.WriteString(prefix)
+		buf.WriteString(strings.TrimSuffix(s, "\n"))
+		if !strings.HasSuffix(s, "\n") {
+			buf.WriteString(" (no newline)")
+		}
+		buf.WriteString("\n")
+	}
+	start := max(i-context, 0)
+	fmt.Fprintf(&buf, "@@ line %d @@\n", start+1)
+	for _, s := range x[start:i] {
+		line(" ", s)
+	}
+	for _, s := range x[i : len(x)-j] {
+		line("-", s)
+	}
+	for _, s := range y[i : len(y)-j] {
+		line("+", s)
+	}
+	for _, s := range x[len(x)-j : min(len(x)-j+context, len(x))] {
+		if s != "" {
+			line(" ", s)
+		}
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Format returns a deterministic multi-line representation of a
+// Starlark value, in the syntax of a Starlark literal where possible:
+// each element of a non-empty list, tuple, dict, or set appears
+// indented on a line of its own, in order. Other values are
+// represented by their String method.
+func Format(v starlark.Value) string {
+	var buf strings.Builder
+	format(&buf, v, "")
+	return buf.String()
+}
+
+func format(buf *strings.Builder, v starlark.Value, indent string) {
+	elems := func(open, close string, n int, elem func(i int)) {
+		buf.WriteString(open)
+		for i := range n {
+			buf.WriteString("\n" + indent + "    ")
+			elem(i)
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n" + indent + close)
+	}
+	switch v := v.(type) {
+	case *starlark.List:
+		if v.Len() > 0 {
+			elems("[", "]", v.Len(), func(i int) { format(buf, v.Index(i), indent+"    ") })
+			return
+		}
+	case starlark.Tuple:
+		if len(v) > 0 {
+			elems("(", ")", len(v), func(i int) { format(buf, v[i], indent+"    ") })
+			return
+		}
+	case *starlark.Dict:
+		if v.Len() > 0 {
+			items := v.Items()
+			elems("{", "}", len(items), func(i int) {
+				format(buf, items[i][0], indent+"    ")
+				buf.WriteString(": ")
+				format(buf, items[i][1], indent+"    ")
+			})
+			return
+		}
+	case *starlark.Set:
+		if v.Len() > 0 {
+			var set []starlark.Value
+			iter := v.Iterate()
+			defer iter.Done()
+			var x starlark.Value
+			for iter.Next(&x) {
+				set = append(set, x)
+			}
+			elems("set([", "])", len(set), func(i int) { format(buf, set[i], indent+"    ") })
+			return
+		}
+	}
+	buf.WriteString(v.String())
+}
@@ -0,0 +1,220 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlarktest
+
+// This file defines RunFiles, which runs Starlark test files as Go subtests.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Options configures RunFiles and CheckGolden. The zero value is valid.
+type Options struct {
+	// Predeclared holds the predeclared names of each test file,
+	// in addition to the universal ones.
+	Predeclared starlark.StringDict
+
+	// Load, if non-nil, loads the modules other than "assert.star"
+	// that test files load. It is called by the test's thread.
+	Load func(thread *starlark.Thread, module string) (starlark.StringDict, error)
+
+	// FileOptions are the options used to compile test files.
+	// If nil, syntax.LegacyFileOptions() is used.
+	FileOptions *syntax.FileOptions
+
+	// Encoding is the serialization of globals used by CheckGolden.
+	Encoding Encoding
+}
+
+// RunFiles runs the tests of the Starlark files whose names match the
+// glob pattern (see filepath.Glob), each as a subtest of t named by
+// its file name relative to the directory of the pattern, with any
+// separators replaced by underscores: the pattern "testdata/*_test.star"
+// yields subtests such as "x_test.star". Each test of a file is a
+// global function whose name begins with "test_", and is run as a
+// subtest of the file's subtest named by the function, in order of
+// their definitions. The usual -run flag selects files and tests by
+// those names, as in -run=TestFoo/x_test.star/test_bar.
+//
+// Each test runs in its own thread, in its own execution of the file,
+// so that tests cannot observe each other's effects on the module's
+// globals. If the file defines a function named setup, it is called
+// before each test, and one named teardown is called after each
+// test, even if the test failed.
+//
+// A test fails if it reports errors by the functions of the assert
+// module, which a test file may load from "assert.star", or if it
+// fails with an error, which is reported with its Starlark backtrace.
+// A test may skip itself by calling assert.skip(reason).
+// The golden files of assert.golden are relative to the directory of
+// the test file.
+// The output of print is logged by t.Log.
+func RunFiles(t *testing.T, pattern string, opts *Options) {
+	t.Helper()
+	if opts == nil {
+		opts = new(Options)
+	}
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no files match %s", pattern)
+	}
+	dir := globDir(pattern)
+	for _, filename := range files {
+		name, err := filepath.Rel(dir, filename)
+		if err != nil {
+			name = filename
+		}
+		name = strings.ReplaceAll(filepath.ToSlash(name), "/", "_")
+		t.Run(name, func(t *testing.T) {
+			runFile(t, filename, opts)
+		})
+	}
+}
+
+// globDir returns the directory of the glob pattern, which contains
+// all the files it matches: the directory of its longest prefix that
+// has no metacharacters.
+func globDir(pattern string) string {
+	meta := `*?[\`
+	if runtime.GOOS == "windows" {
+		meta = `*?[` // \ is a separator
+	}
+	if i := strings.IndexAny(pattern, meta); i >= 0 {
+		pattern = pattern[:i] + "x" // a file name within the prefix's directory
+	}
+	return filepath.Dir(pattern)
+}
+
+// runFile runs the tests of a single file.
+func runFile(t *testing.T, filename string, opts *Options) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileOpts := opts.FileOptions
+	if fileOpts == nil {
+		fileOpts = syntax.LegacyFileOptions()
+	}
+	_, prog, err := starlark.SourceProgramOptions(fileOpts, filename, src, opts.Predeclared.Has)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Execute the file once to discover its tests.
+	globals, err := prog.Init(newTestThread(t, filename, opts), opts.Predeclared)
+	if err != nil {
+		t.Fatal(backtrace(err))
+	}
+	var tests []*starlark.Function
+	for name, v := range globals {
+		if fn, ok := v.(*starlark.Function); ok && strings.HasPrefix(name, "test_") && fn.Name() == name {
+			tests = append(tests, fn)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		x, y := tests[i].Position(), tests[j].Position()
+		return x.Line < y.Line || x.Line == y.Line && x.Col < y.Col
+	})
+	if len(tests) == 0 {
+		t.Logf("%s: no tests", filename)
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name(), func(t *testing.T) {
+			thread := newTestThread(t, test.Name(), opts)
+			SetGoldenDir(thread, filepath.Dir(filename))
+			globals, err := prog.Init(thread, opts.Predeclared)
+			if err != nil {
+				t.Fatal(backtrace(err))
+			}
+			call := func(name string) bool {
+				fn, ok := globals[name].(starlark.Callable)
+				if !ok {
+					return true
+				}
+				if _, err := starlark.Call(thread, fn, nil, nil); err != nil {
+					if reason, ok := SkipReason(err); ok {
+						t.Skip(reason)
+					}
+					t.Error(backtrace(err))
+					return false
+				}
+				return true
+			}
+			if _, ok := globals["teardown"]; ok {
+				defer call("teardown")
+			}
+			if call("setup") {
+				call(test.Name())
+			}
+		})
+	}
+}
+
+// newTestThread returns a new thread for a test.
+func newTestThread(t *testing.T, name string, opts *Options) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  name,
+		Print: func(_ *starlark.Thread, msg string) { t.Log(msg) },
+		Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+			if module == "assert.star" {
+				return LoadAssertModule()
+			}
+			if opts.Load == nil {
+				return nil, fmt.Errorf("load not implemented")
+			}
+			return opts.Load(thread, module)
+		},
+	}
+	SetReporter(thread, t)
+	return thread
+}
+
+// backtrace returns the message of err, with its Starlark backtrace if any.
+func backtrace(err error) string {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return evalErr.Backtrace()
+	}
+	return err.Error()
+}
+
+// A skipError is the error of assert.skip, which causes RunFiles to skip a test.
+type skipError struct{ reason string }
+
+func (e *skipError) Error() string { return "test skipped: " + e.reason }
+
+// SkipReason reports whether err is, or wraps, the error of a call
+// of assert.skip, and if so returns its reason. It allows test
+// runners other than RunFiles to skip tests.
+func SkipReason(err error) (reason string, ok bool) {
+	var skip *skipError
+	if errors.As(err, &skip) {
+		return skip.reason, true
+	}
+	return "", false
+}
+
+// skip(reason) fails with a skipError.
+func skip(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var reason string
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "reason?", &reason); err != nil {
+		return nil, err
+	}
+	return nil, &skipError{reason}
+}
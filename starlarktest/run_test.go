@@ -0,0 +1,70 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package starlarktest_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarktest"
+)
+
+func TestRunFiles(t *testing.T) {
+	// The record built-in logs a message under the name of the
+	// subtest that called it.
+	var (
+		mu  sync.Mutex
+		log = make(map[string][]string)
+	)
+	record := func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var msg string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &msg); err != nil {
+			return nil, err
+		}
+		name := starlarktest.GetReporter(thread).(*testing.T).Name()
+		mu.Lock()
+		log[name] = append(log[name], msg)
+		mu.Unlock()
+		return starlark.None, nil
+	}
+	starlarktest.RunFiles(t, "testdata/*_test.star", &starlarktest.Options{
+		Predeclared: starlark.StringDict{"record": starlark.NewBuiltin("record", record)},
+		Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+			return starlark.ExecFile(thread, module, "def double(x): return 2 * x", nil)
+		},
+	})
+
+	// Each test of example_test.star that ran (perhaps not all,
+	// given -run) records its setup, body, and teardown.
+	for name, msgs := range log {
+		file, test, ok := strings.Cut(strings.TrimPrefix(name, t.Name()+"/"), "/")
+		if !ok || file != "example_test.star" {
+			t.Errorf("unexpected subtest %s", name)
+			continue
+		}
+		if got, want := strings.Join(msgs, " "), "setup "+test+" teardown"; got != want {
+			t.Errorf("%s: got %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestCheckGolden(t *testing.T) {
+	for _, test := range []struct {
+		encoding starlarktest.Encoding
+		golden   string
+	}{
+		{starlarktest.StarlarkEncoding, "testdata/globals.star.golden"},
+		{starlarktest.JSONEncoding, "testdata/globals.json.golden"},
+	} {
+		starlarktest.CheckGolden(t, "testdata/globals.star", test.golden, &starlarktest.Options{
+			Load: func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+				return starlark.ExecFile(thread, module, "def double(x): return 2 * x", nil)
+			},
+			Encoding: test.encoding,
+		})
+	}
+}
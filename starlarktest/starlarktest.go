@@ -10,6 +10,11 @@
 //
 // The assert.error function, which reports errors to the current Go
 // testing.T, requires that clients call SetReporter(thread, t) before use.
+//
+// RunFiles runs the test functions of Starlark files as Go subtests.
+// CheckGolden compares the globals of a Starlark file with a golden file.
+// Generate, FuzzFunction, and CheckFunction support fuzz tests of
+// Starlark functions.
 package starlarktest // import "go.starlark.net/starlarktest"
 
 import (
@@ -64,12 +69,15 @@ var (
 func LoadAssertModule() (starlark.StringDict, error) {
 	once.Do(func() {
 		predeclared := starlark.StringDict{
-			"error":    starlark.NewBuiltin("error", error_),
-			"catch":    starlark.NewBuiltin("catch", catch),
-			"matches":  starlark.NewBuiltin("matches", matches),
-			"module":   starlark.NewBuiltin("module", starlarkstruct.MakeModule),
-			"_freeze":  starlark.NewBuiltin("freeze", freeze),
-			"_floateq": starlark.NewBuiltin("floateq", floateq),
+			"error":        starlark.NewBuiltin("error", error_),
+			"catch":        starlark.NewBuiltin("catch", catch),
+			"matches":      starlark.NewBuiltin("matches", matches),
+			"module":       starlark.NewBuiltin("module", starlarkstruct.MakeModule),
+			"_freeze":      starlark.NewBuiltin("freeze", freeze),
+			"_floateq":     starlark.NewBuiltin("floateq", floateq),
+			"_skip":        starlark.NewBuiltin("skip", skip),
+			"_diff":        starlark.NewBuiltin("diff", diff),
+			"_golden_diff": starlark.NewBuiltin("golden", golden),
 		}
 		thread := new(starlark.Thread)
 		assert, assertErr = starlark.ExecFile(thread, "assert.star", assertFileSrc, predeclared)
@@ -121,6 +129,91 @@ func error_(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, k
 	return starlark.None, nil
 }
 
+// diff(x, y) returns a message describing the differences between
+// unequal values x and y. If both are lists, tuples, or dicts, it
+// lists the paths of their differing elements, such as
+// `["a"][2]: 3 != 4`; otherwise it is "x != y".
+func diff(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x, y starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &x, &y); err != nil {
+		return nil, err
+	}
+	var diffs []string
+	if err := diffValues("", x, y, &diffs); err != nil {
+		return nil, err
+	}
+	if len(diffs) == 1 && !strings.HasPrefix(diffs[0], "[") {
+		return starlark.String(diffs[0]), nil // not a path
+	}
+	const max = 10
+	msg := "values differ:"
+	for i, d := range diffs {
+		if i == max {
+			msg += fmt.Sprintf("\n  ... and %d more", len(diffs)-max)
+			break
+		}
+		msg += "\n  " + d
+	}
+	return starlark.String(msg), nil
+}
+
+// diffValues appends to diffs a description of each difference
+// between x and y at the specified path.
+func diffValues(path string, x, y starlark.Value, diffs *[]string) error {
+	if eq, err := starlark.Equal(x, y); err != nil {
+		return err
+	} else if eq {
+		return nil
+	}
+	prefix := ""
+	if path != "" {
+		prefix = path + ": "
+	}
+	const missing = "(missing)"
+	switch x := x.(type) {
+	case *starlark.Dict:
+		if y, ok := y.(*starlark.Dict); ok {
+			for _, item := range x.Items() {
+				k, xv := item[0], item[1]
+				elem := fmt.Sprintf("%s[%s]", path, k)
+				if yv, found, err := y.Get(k); err != nil {
+					return err
+				} else if !found {
+					*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", elem, xv, missing))
+				} else if err := diffValues(elem, xv, yv, diffs); err != nil {
+					return err
+				}
+			}
+			for _, item := range y.Items() {
+				if _, found, _ := x.Get(item[0]); !found {
+					*diffs = append(*diffs, fmt.Sprintf("%s[%s]: %s != %s", path, item[0], missing, item[1]))
+				}
+			}
+			return nil
+		}
+	case *starlark.List, starlark.Tuple:
+		if y.Type() == x.Type() {
+			xs, ys := x.(starlark.Indexable), y.(starlark.Indexable)
+			for i := 0; i < max(xs.Len(), ys.Len()); i++ {
+				elem := fmt.Sprintf("%s[%d]", path, i)
+				switch {
+				case i >= ys.Len():
+					*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", elem, xs.Index(i), missing))
+				case i >= xs.Len():
+					*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", elem, missing, ys.Index(i)))
+				default:
+					if err := diffValues(elem, xs.Index(i), ys.Index(i), diffs); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+	}
+	*diffs = append(*diffs, fmt.Sprintf("%s%s != %s", prefix, x, y))
+	return nil
+}
+
 // freeze(x) freezes its operand.
 func freeze(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	if len(kwargs) > 0 {
@@ -27,9 +27,13 @@ type FileOptions struct {
 	TopLevelControl   bool // allow if/for/while statements at top-level
 	GlobalReassign    bool // allow reassignment to top-level names
 	LoadBindsGlobally bool // load creates global not file-local bindings (deprecated)
+	TypeAnnotations   bool // allow type annotations such as def f(x: int) -> str and x: int = 0
+	FStrings          bool // allow f-strings such as f"hello {name}"
+	Exceptions        bool // allow try/except/finally statements
 
 	// compiler
-	Recursion bool // disable recursion check for functions in this file
+	Recursion  bool // disable recursion check for functions in this file
+	CheckTypes bool // check the annotated types of parameters and results at run time
 }
 
 // TODO(adonovan): provide a canonical flag parser for FileOptions.
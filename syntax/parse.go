@@ -12,8 +12,10 @@ package syntax
 // chunkedfile mechanism.
 
 import (
+	"fmt"
 	"log"
 	"slices"
+	"strings"
 )
 
 // Enable this flag to print the token stream and log.Fatal on the first error.
@@ -24,6 +26,7 @@ type Mode uint
 
 const (
 	RetainComments Mode = 1 << iota // retain comments in AST; see Node.Comments
+	RecoverErrors                   // report all syntax errors, and return a partial AST; see FileOptions.Parse
 )
 
 // Parse calls the Parse method of LegacyFileOptions().
@@ -41,20 +44,34 @@ func Parse(filename string, src any, mode Mode) (f *File, err error) {
 // The type of the argument for the src parameter must be string,
 // []byte, io.Reader, or FilePortion.
 // If src == nil, Parse parses the file specified by filename.
+//
+// Ordinarily, Parse stops at the first syntax error and returns it as
+// an Error. In RecoverErrors mode, the parser instead resynchronizes
+// at the next statement after each error, and returns a syntax tree
+// for the entire file along with an ErrorList of all the errors,
+// if any. Statements and expressions that could not be parsed are
+// represented in the tree by BadStmt and BadExpr nodes.
 func (opts *FileOptions) Parse(filename string, src any, mode Mode) (f *File, err error) {
 	in, err := newScanner(filename, src, mode&RetainComments != 0)
 	if err != nil {
 		return nil, err
 	}
-	p := parser{options: opts, in: in}
+	p := parser{options: opts, in: in, recoverErrors: mode&RecoverErrors != 0}
 	defer p.in.recover(&err)
 
-	p.nextToken() // read first lookahead token
+	if p.recoverErrors {
+		p.skipToken() // read first lookahead token
+	} else {
+		p.nextToken() // read first lookahead token
+	}
 	f = p.parseFile()
 	if f != nil {
 		f.Path = filename
 	}
 	p.assignComments(f)
+	if p.errors != nil {
+		return f, p.errors
+	}
 	return f, nil
 }
 
@@ -143,6 +160,9 @@ type parser struct {
 	in      *scanner
 	tok     Token
 	tokval  tokenValue
+
+	recoverErrors bool      // RecoverErrors mode
+	errors        ErrorList // errors reported in RecoverErrors mode
 }
 
 // nextToken advances the scanner and returns the position of the
@@ -162,14 +182,141 @@ func (p *parser) parseFile() *File {
 	var stmts []Stmt
 	for p.tok != EOF {
 		if p.tok == NEWLINE {
-			p.nextToken()
+			p.skipToken()
 			continue
 		}
-		stmts = p.parseStmt(stmts)
+		stmts = p.parseStmtRecover(stmts)
 	}
 	return &File{Options: p.options, Stmts: stmts}
 }
 
+// -- error recovery --
+
+// parseStmtRecover calls parseStmt. In RecoverErrors mode, if the
+// statement contains a syntax error, it records the error, skips to
+// the start of the next statement, and appends a BadStmt instead.
+func (p *parser) parseStmtRecover(stmts []Stmt) []Stmt {
+	if !p.recoverErrors {
+		return p.parseStmt(stmts)
+	}
+	n := len(stmts)
+	from := p.tokval.pos
+	var parsed []Stmt
+	if p.try(func() { parsed = p.parseStmt(stmts) }) {
+		return parsed
+	}
+	bad := p.skipStmt(from)
+	if p.tokval.pos == from && p.tok != EOF {
+		p.skipToken() // ensure progress, e.g. past an unexpected OUTDENT
+	}
+	return append(stmts[:n], bad)
+}
+
+// try calls f, and reports whether it completed without a syntax error.
+// A syntax error is recorded.
+func (p *parser) try(f func()) (ok bool) {
+	defer func() {
+		if x := recover(); x != nil {
+			e, isError := x.(Error)
+			if !isError {
+				panic(x) // not a syntax error
+			}
+			p.addError(e)
+			ok = false
+		}
+	}()
+	f()
+	return true
+}
+
+// addError records a syntax error in RecoverErrors mode.
+// To reduce the number of spurious errors caused by a prior one,
+// it discards all but the first error on each line.
+func (p *parser) addError(e Error) {
+	if n := len(p.errors); n > 0 && p.errors[n-1].Pos.Line == e.Pos.Line {
+		return
+	}
+	p.errors = append(p.errors, e)
+}
+
+// skipToken is like nextToken, but in RecoverErrors mode it records
+// scanner errors, skipping the offending input, instead of panicking.
+func (p *parser) skipToken() {
+	if !p.recoverErrors {
+		p.nextToken()
+		return
+	}
+	for {
+		pos := p.in.pos
+		if p.try(func() { p.nextToken() }) {
+			return
+		}
+		// If the scanner rejected a character without consuming
+		// any input, skip the character to ensure progress.
+		if p.in.pos == pos && !p.in.eof() {
+			p.in.readRune()
+		}
+	}
+}
+
+// skipStmt skips the remainder of a statement containing a syntax
+// error, that began at position from, and returns a BadStmt.
+// It stops after the next NEWLINE (outside brackets), and any
+// indented block that follows it, or before an OUTDENT or EOF.
+func (p *parser) skipStmt(from Position) *BadStmt {
+	to := p.tokval.pos
+	line := from.Line
+	for p.tok != EOF && p.tok != OUTDENT {
+		// If the statement has unbalanced brackets, the scanner
+		// does not report NEWLINEs. Assume a keyword that begins
+		// a statement at the start of a line ends the bad statement,
+		// unless the line is indented relative to the bad statement,
+		// in which case it begins the body of a compound statement
+		// whose header is bad, as in "def f(a, b:", which we skip.
+		if p.in.depth > 0 && p.tokval.pos.Line > line && startsStmt(p.tok) {
+			p.in.depth = 0
+			if p.tokval.pos.Col <= from.Col {
+				return &BadStmt{From: from, To: to}
+			}
+		}
+		line = p.tokval.pos.Line
+		to = p.tokval.pos
+		tok := p.tok
+		p.skipToken()
+		if tok == NEWLINE {
+			break
+		}
+	}
+
+	// Skip the indented block (if any) following the statement,
+	// as in "def f(: ...".
+	if p.tok == INDENT {
+		for depth := 0; p.tok != EOF; {
+			switch p.tok {
+			case INDENT:
+				depth++
+			case OUTDENT:
+				depth--
+			}
+			to = p.tokval.pos
+			p.skipToken()
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	return &BadStmt{From: from, To: to}
+}
+
+// startsStmt reports whether tok can begin only a statement.
+func startsStmt(tok Token) bool {
+	switch tok {
+	case DEF, IF, FOR, WHILE, LOAD, RETURN, PASS, BREAK, CONTINUE:
+		return true
+	}
+	return false
+}
+
 func (p *parser) parseStmt(stmts []Stmt) []Stmt {
 	if p.tok == DEF {
 		return append(stmts, p.parseDefStmt())
@@ -179,6 +326,8 @@ func (p *parser) parseStmt(stmts []Stmt) []Stmt {
 		return append(stmts, p.parseForStmt())
 	} else if p.tok == WHILE {
 		return append(stmts, p.parseWhileStmt())
+	} else if p.tok == TRY {
+		return append(stmts, p.parseTryStmt())
 	}
 	return p.parseSimpleStmt(stmts, true)
 }
@@ -187,17 +336,26 @@ func (p *parser) parseDefStmt() Stmt {
 	defpos := p.nextToken() // consume DEF
 	id := p.parseIdent()
 	lparen := p.consume(LPAREN)
-	params := p.parseParams()
+	params, types := p.parseParams(true)
 	rparen := p.consume(RPAREN)
+	var arrow Position
+	var result Expr
+	if p.tok == ARROW {
+		arrow = p.nextToken() // consume ARROW
+		result = p.parseTest()
+	}
 	p.consume(COLON)
 	body := p.parseSuite()
 	return &DefStmt{
-		Def:    defpos,
-		Name:   id,
-		Lparen: lparen,
-		Params: params,
-		Rparen: rparen,
-		Body:   body,
+		Def:        defpos,
+		Name:       id,
+		Lparen:     lparen,
+		Params:     params,
+		Rparen:     rparen,
+		Body:       body,
+		ParamTypes: types,
+		Arrow:      arrow,
+		ResultType: result,
 	}
 }
 
@@ -261,6 +419,32 @@ func (p *parser) parseWhileStmt() Stmt {
 	}
 }
 
+func (p *parser) parseTryStmt() Stmt {
+	trypos := p.nextToken() // consume TRY
+	p.consume(COLON)
+	stmt := &TryStmt{
+		Try:  trypos,
+		Body: p.parseSuite(),
+	}
+	if p.tok == EXCEPT {
+		stmt.ExceptPos = p.nextToken() // consume EXCEPT
+		if p.tok == IDENT {
+			stmt.Name = p.parseIdent()
+		}
+		p.consume(COLON)
+		stmt.Except = p.parseSuite()
+	}
+	if p.tok == FINALLY {
+		stmt.FinallyPos = p.nextToken() // consume FINALLY
+		p.consume(COLON)
+		stmt.Finally = p.parseSuite()
+	}
+	if stmt.Except == nil && stmt.Finally == nil {
+		p.in.errorf(p.tokval.pos, "got %#v, want 'except' or 'finally'", p.tok)
+	}
+	return stmt
+}
+
 // Equivalent to 'exprlist' production in Python grammar.
 //
 // loop_variables = primary_with_suffix (COMMA primary_with_suffix)* COMMA?
@@ -309,6 +493,7 @@ func (p *parser) parseSimpleStmt(stmts []Stmt, consumeNL bool) []Stmt {
 //	| PASS | BREAK | CONTINUE
 //	| LOAD ...
 //	| expr ('=' | '+=' | '-=' | '*=' | '/=' | '%=' | '&=' | '|=' | '^=' | '<<=' | '>>=') expr   // assign
+//	| IDENT ':' test '=' expr                                                                    // annotated assign
 //	| expr
 func (p *parser) parseSmallStmt() Stmt {
 	switch p.tok {
@@ -337,6 +522,16 @@ func (p *parser) parseSmallStmt() Stmt {
 		pos := p.nextToken() // consume op
 		rhs := p.parseExpr(false)
 		return &AssignStmt{OpPos: pos, Op: op, LHS: x, RHS: rhs}
+
+	case COLON:
+		// Annotated assignment: x: T = y
+		if _, ok := x.(*Ident); ok {
+			colon := p.nextToken() // consume COLON
+			typ := p.parseTest()
+			pos := p.consume(EQ)
+			rhs := p.parseExpr(false)
+			return &AssignStmt{OpPos: pos, Op: EQ, LHS: x, Colon: colon, Type: typ, RHS: rhs}
+		}
 	}
 
 	// Expression statement (e.g. function call, doc string).
@@ -414,7 +609,7 @@ func (p *parser) parseSuite() []Stmt {
 		p.consume(INDENT)
 		var stmts []Stmt
 		for p.tok != OUTDENT && p.tok != EOF {
-			stmts = p.parseStmt(stmts)
+			stmts = p.parseStmtRecover(stmts)
 		}
 		p.consume(OUTDENT)
 		return stmts
@@ -460,8 +655,23 @@ func (p *parser) consume(t Token) Position {
 //	*Unary{Op: STAR}                                *
 //	*Unary{Op: STAR, X: *Ident}                     *args
 //	*Unary{Op: STARSTAR, X: *Ident}                 **kwargs
-func (p *parser) parseParams() []Expr {
-	var params []Expr
+//
+// If annotated, each IDENT may be followed by a type annotation
+// (COLON test), as in "x: int = 0". The types slice, if non-nil,
+// holds the annotation of each parameter, or nil.
+func (p *parser) parseParams(annotated bool) (params, types []Expr) {
+	// parseType parses the optional annotation of the ith parameter.
+	parseType := func(i int) {
+		if annotated && p.tok == COLON {
+			p.nextToken() // consume COLON
+			if types == nil {
+				types = make([]Expr, i, i+1)
+			}
+			types = append(types, p.parseTest())
+		} else if types != nil {
+			types = append(types, nil)
+		}
+	}
 	for p.tok != RPAREN && p.tok != COLON && p.tok != EOF {
 		if len(params) > 0 {
 			p.consume(COMMA)
@@ -478,17 +688,24 @@ func (p *parser) parseParams() []Expr {
 			if op == STARSTAR || p.tok == IDENT {
 				x = p.parseIdent()
 			}
+			i := len(params)
 			params = append(params, &UnaryExpr{
 				OpPos: pos,
 				Op:    op,
 				X:     x,
 			})
+			if x != nil {
+				parseType(i)
+			} else if types != nil {
+				types = append(types, nil)
+			}
 			continue
 		}
 
 		// IDENT
 		// IDENT = test
 		id := p.parseIdent()
+		parseType(len(params))
 		if p.tok == EQ { // default value
 			eq := p.nextToken()
 			dflt := p.parseTest()
@@ -503,7 +720,7 @@ func (p *parser) parseParams() []Expr {
 
 		params = append(params, id)
 	}
-	return params
+	return params, types
 }
 
 // parseExpr parses an expression, possible consisting of a
@@ -577,7 +794,7 @@ func (p *parser) parseLambda(allowCond bool) Expr {
 	lambda := p.nextToken()
 	var params []Expr
 	if p.tok != COLON {
-		params = p.parseParams()
+		params, _ = p.parseParams(false)
 	}
 	p.consume(COLON)
 
@@ -804,6 +1021,7 @@ func (p *parser) parseArgs() []Expr {
 // primary = IDENT
 //
 //	| INT | FLOAT | STRING | BYTES
+//	| FSTRING                    // f-string literal
 //	| '[' ...                    // list literal or comprehension
 //	| '{' ...                    // dict literal or comprehension
 //	| '(' ...                    // tuple or parenthesized expression
@@ -832,6 +1050,9 @@ func (p *parser) parsePrimary() Expr {
 		pos := p.nextToken()
 		return &Literal{Token: tok, TokenPos: pos, Raw: raw, Value: val}
 
+	case FSTRING:
+		return p.parseFString()
+
 	case LBRACK:
 		return p.parseList()
 
@@ -865,10 +1086,181 @@ func (p *parser) parsePrimary() Expr {
 	}
 
 	// Report start pos of final token as it may be a NEWLINE (#532).
+	if p.recoverErrors {
+		// Record the error and continue parsing the statement.
+		p.addError(Error{p.tokval.pos, fmt.Sprintf("got %#v, want primary expression", p.tok)})
+		return &BadExpr{From: p.tokval.pos, To: p.tokval.pos}
+	}
 	p.in.errorf(p.tokval.pos, "got %#v, want primary expression", p.tok)
 	panic("unreachable")
 }
 
+// parseFString parses an f-string literal, splitting it into literal
+// parts and replacement fields, and parsing the expression of each field.
+func (p *parser) parseFString() Expr {
+	raw := p.tokval.raw
+	pos := p.nextToken()
+	x := &FStringExpr{TokenPos: pos, Raw: raw}
+
+	// Split the literal into prefix (less f), quotes, and content.
+	q := strings.IndexAny(raw, `'"`)
+	prefix := strings.Replace(raw[:q], "f", "", 1)
+	quote := raw[q : q+1]
+	if len(raw)-q >= 6 && raw[q+1] == raw[q] && raw[q+2] == raw[q] {
+		quote = raw[q : q+3]
+	}
+	content := raw[q+len(quote) : len(raw)-len(quote)]
+	contentPos := pos.add(raw[:q+len(quote)])
+	posAt := func(i int) Position { return contentPos.add(content[:i]) }
+
+	// addLiteral decodes the literal part lit, which starts at content[start].
+	var lit strings.Builder
+	addLiteral := func(start int) {
+		s := lit.String()
+		lit.Reset()
+		if s != "" {
+			var err error
+			s, _, _, err = unquote(prefix + quote + s + quote)
+			if err != nil {
+				p.in.error(posAt(start), err.Error())
+			}
+		}
+		x.Literals = append(x.Literals, s)
+	}
+
+	start := 0 // start of current literal part
+	for i := 0; i < len(content); {
+		switch c := content[i]; {
+		case c == '\\' && i+1 < len(content) && content[i+1] != '{' && content[i+1] != '}':
+			// Escape sequences are decoded by unquote.
+			lit.WriteString(content[i : i+2])
+			i += 2
+
+		case c == '{' && i+1 < len(content) && content[i+1] == '{',
+			c == '}' && i+1 < len(content) && content[i+1] == '}':
+			lit.WriteByte(c)
+			i += 2
+
+		case c == '}':
+			p.in.error(posAt(i), "f-string: single '}' is not allowed")
+
+		case c == '{':
+			addLiteral(start)
+			field, end := p.parseFStringField(content, i, posAt)
+			x.Fields = append(x.Fields, field)
+			i = end
+			start = i
+
+		default:
+			lit.WriteByte(c)
+			i++
+		}
+	}
+	addLiteral(start)
+	return x
+}
+
+// parseFStringField parses the replacement field {X!Conv:Spec} that
+// starts at content[lbrace], and returns it along with the index of
+// the byte following its closing brace.
+func (p *parser) parseFStringField(content string, lbrace int, posAt func(int) Position) (*FStringField, int) {
+	field := &FStringField{Lbrace: posAt(lbrace)}
+
+	// Find the end of the expression, skipping over
+	// brackets and quoted strings.
+	i := lbrace + 1
+	depth := 0
+loop:
+	for ; i < len(content); i++ {
+		switch c := content[i]; c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']':
+			depth--
+		case '}':
+			if depth == 0 {
+				break loop
+			}
+			depth--
+		case '\'', '"':
+			for i++; i < len(content) && content[i] != c; i++ {
+				if content[i] == '\\' {
+					i++
+				}
+			}
+		case '!':
+			if depth == 0 && (i+1 >= len(content) || content[i+1] != '=') {
+				break loop
+			}
+		case ':':
+			if depth == 0 {
+				break loop
+			}
+		}
+	}
+	if i >= len(content) {
+		p.in.error(field.Lbrace, "f-string: missing '}'")
+	}
+	text := content[lbrace+1 : i]
+	if strings.TrimSpace(text) == "" {
+		p.in.error(field.Lbrace, "f-string: empty expression not allowed")
+	}
+	field.X = p.parseFStringExpr(text, posAt(lbrace+1))
+
+	// Parse the optional conversion and format specifier.
+	if content[i] == '!' {
+		j := i + 1
+		for j < len(content) && content[j] != ':' && content[j] != '}' {
+			j++
+		}
+		field.Conv = content[i+1 : j]
+		if field.Conv != "s" && field.Conv != "r" {
+			p.in.errorf(posAt(i), "f-string: invalid conversion %q (want 's' or 'r')", field.Conv)
+		}
+		i = j
+	}
+	if i < len(content) && content[i] == ':' {
+		j := i + 1
+		for j < len(content) && content[j] != '}' {
+			if content[j] == '{' {
+				p.in.error(posAt(j), "f-string: nested replacement fields are not supported")
+			}
+			j++
+		}
+		field.Spec = content[i+1 : j]
+		i = j
+	}
+	if i >= len(content) {
+		p.in.error(field.Lbrace, "f-string: missing '}'")
+	}
+	field.Rbrace = posAt(i)
+	return field, i + 1
+}
+
+// parseFStringExpr parses the expression of an f-string replacement
+// field, whose text starts at pos.
+func (p *parser) parseFStringExpr(text string, pos Position) Expr {
+	// The scanner treats the expression as if within brackets,
+	// so that it may span lines.
+	in, err := newScanner(pos.Filename(), FilePortion{
+		Content:   []byte(text),
+		FirstLine: pos.Line,
+		FirstCol:  pos.Col,
+	}, false)
+	if err != nil {
+		p.in.error(pos, err.Error())
+	}
+	in.pos.file = pos.file
+	in.depth = 1
+	sub := parser{options: p.options, in: in}
+	sub.nextToken()
+	x := sub.parseExpr(false)
+	if sub.tok != EOF {
+		sub.in.errorf(sub.tokval.pos, "got %#v in f-string expression, want '}'", sub.tok)
+	}
+	return x
+}
+
 // list = '[' ']'
 //
 //	| '[' expr ']'
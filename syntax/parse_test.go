@@ -118,6 +118,10 @@ func TestExprParseTrees(t *testing.T) {
 			`(BinaryExpr X=a Op=and Y=(UnaryExpr Op=not X=b))`},
 		{`[e for x in y if cond1 if cond2]`,
 			`(Comprehension Body=e Clauses=((ForClause Vars=x X=y) (IfClause Cond=cond1) (IfClause Cond=cond2)))`}, // github.com/google/skylark/issues/53
+		{`f"a{x!r:>3}b{{c}}{y + 1}"`,
+			`(FStringExpr Raw=f"a{x!r:>3}b{{c}}{y + 1}" Literals=(a b{c} ) Fields=((FStringField X=x Conv=r Spec=>3) (FStringField X=(BinaryExpr X=y Op=+ Y=1) Conv= Spec=)))`},
+		{`rf'\d{n}'`,
+			`(FStringExpr Raw=rf'\d{n}' Literals=(\d ) Fields=((FStringField X=n Conv= Spec=)))`},
 	} {
 		e, err := syntax.ParseExpr("foo.star", test.input, 0)
 		var got string
@@ -160,6 +164,12 @@ else:
 			`(IfStmt Cond=True True=((BranchStmt Token=pass)) False=((BranchStmt Token=pass)))`},
 		{"if a: pass\nelif b: pass\nelse: pass",
 			`(IfStmt Cond=a True=((BranchStmt Token=pass)) False=((IfStmt Cond=b True=((BranchStmt Token=pass)) False=((BranchStmt Token=pass)))))`},
+		{"try: f()\nexcept e: pass",
+			`(TryStmt Body=((ExprStmt X=(CallExpr Fn=f))) Name=e Except=((BranchStmt Token=pass)))`},
+		{"try: pass\nexcept: pass\nfinally: g()",
+			`(TryStmt Body=((BranchStmt Token=pass)) Except=((BranchStmt Token=pass)) Finally=((ExprStmt X=(CallExpr Fn=g))))`},
+		{"try: pass\nfinally: pass",
+			`(TryStmt Body=((BranchStmt Token=pass)) Finally=((BranchStmt Token=pass)))`},
 		{`x, y = 1, 2`,
 			`(AssignStmt Op== LHS=(TupleExpr List=(x y)) RHS=(TupleExpr List=(1 2)))`},
 		{`x[i] = 1`,
@@ -181,6 +191,12 @@ else:
 			`(DefStmt Name=f Params=(a b (BinaryExpr X=c Op== Y=d)) Body=((BranchStmt Token=pass)))`},
 		{`def f(a, b=c, d): pass`,
 			`(DefStmt Name=f Params=(a (BinaryExpr X=b Op== Y=c) d) Body=((BranchStmt Token=pass)))`}, // TODO(adonovan): fix this
+		{`def f(x: int, y, *args: str, z: list[str] = [], **kwargs) -> dict: pass`,
+			`(DefStmt Name=f Params=(x y (UnaryExpr Op=* X=args) (BinaryExpr X=z Op== Y=(ListExpr)) (UnaryExpr Op=** X=kwargs)) Body=((BranchStmt Token=pass)) ParamTypes=(int nil str (IndexExpr X=list Y=str) nil) ResultType=dict)`},
+		{`def f(x, *, y) -> int | None: pass`,
+			`(DefStmt Name=f Params=(x (UnaryExpr Op=*) y) Body=((BranchStmt Token=pass)) ResultType=(BinaryExpr X=int Op=| Y=None))`},
+		{`x: dict[str, int] = {}`,
+			`(AssignStmt Op== LHS=x Type=(IndexExpr X=dict Y=(TupleExpr List=(str int))) RHS=(DictExpr))`},
 		{`def f():
 	def g():
 		pass
@@ -485,3 +501,128 @@ func BenchmarkParse(b *testing.B) {
 		}
 	}
 }
+
+func TestRecoverErrors(t *testing.T) {
+	const src = `
+x = 1 +
+def f(a, b:
+    return a
+y = [1, 2
+def g():
+    pass
+    z = $
+    return 1
+h(,)
+w = 2
+`
+	f, err := syntax.Parse("foo.star", src, syntax.RecoverErrors)
+	list, ok := err.(syntax.ErrorList)
+	if !ok {
+		t.Fatalf("Parse returned %T (%v), want ErrorList", err, err)
+	}
+	var got []string
+	for _, e := range list {
+		got = append(got, fmt.Sprintf("%d: %s", e.Pos.Line, e.Msg))
+	}
+	want := []string{
+		`2: got newline, want primary expression`,
+		`4: got return, want primary expression`,
+		`6: got def, want ']'`,
+		`8: unexpected input character '$'`,
+		`10: got ',', want primary expression`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// The partial tree contains placeholders for the erroneous parts.
+	var buf bytes.Buffer
+	for i, stmt := range f.Stmts {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		writeTree(&buf, reflect.ValueOf(stmt))
+	}
+	const wantTree = `(AssignStmt Op== LHS=x RHS=(BinaryExpr X=1 Op=+ Y=(BadExpr)))
+(BadStmt)
+(BadStmt)
+(DefStmt Name=g Body=((BranchStmt Token=pass) (BadStmt) (ReturnStmt Result=1)))
+(ExprStmt X=(CallExpr Fn=h Args=((BadExpr))))
+(AssignStmt Op== LHS=w RHS=2)`
+	if got := buf.String(); got != wantTree {
+		t.Errorf("tree:\n%s\nwant:\n%s", got, wantTree)
+	}
+}
+
+// TestRecoverErrorsDef checks that the recovery from an error in the
+// header of a compound statement, such as a def, skips its body.
+func TestRecoverErrorsDef(t *testing.T) {
+	for _, test := range []struct{ src, err, tree string }{
+		{
+			"def f(a, b:\n    return a\nw = 2\n",
+			`2: got return, want primary expression`,
+			"(BadStmt)\n(AssignStmt Op== LHS=w RHS=2)",
+		},
+		{
+			"def f(a, b:\n    if a:\n        return b\n    return a\nw = 2\n",
+			`2: got if, want primary expression`,
+			"(BadStmt)\n(AssignStmt Op== LHS=w RHS=2)",
+		},
+		{
+			"def f(a, 1):\n    return a\nw = 2\n",
+			`1: not an identifier`,
+			"(BadStmt)\n(AssignStmt Op== LHS=w RHS=2)",
+		},
+		{
+			"if f(a:\n    return a\nw = 2\n",
+			`1: got ':', want ','`,
+			"(BadStmt)\n(AssignStmt Op== LHS=w RHS=2)",
+		},
+	} {
+		f, err := syntax.Parse("foo.star", test.src, syntax.RecoverErrors)
+		list, ok := err.(syntax.ErrorList)
+		if !ok {
+			t.Errorf("%q: Parse returned %T (%v), want ErrorList", test.src, err, err)
+			continue
+		}
+		var errs []string
+		for _, e := range list {
+			errs = append(errs, fmt.Sprintf("%d: %s", e.Pos.Line, e.Msg))
+		}
+		if got := strings.Join(errs, "\n"); got != test.err {
+			t.Errorf("%q: errors:\n%s\nwant:\n%s", test.src, got, test.err)
+		}
+		var buf bytes.Buffer
+		for i, stmt := range f.Stmts {
+			if i > 0 {
+				buf.WriteByte('\n')
+			}
+			writeTree(&buf, reflect.ValueOf(stmt))
+		}
+		if got := buf.String(); got != test.tree {
+			t.Errorf("%q: tree:\n%s\nwant:\n%s", test.src, got, test.tree)
+		}
+	}
+}
+
+// TestRecoverErrorsFirst checks that, for each erroneous chunk of
+// testdata/errors.star, the parser in RecoverErrors mode terminates,
+// and reports first the same error as in the default mode.
+func TestRecoverErrorsFirst(t *testing.T) {
+	filename := starlarktest.DataFile("syntax", "testdata/errors.star")
+	for _, chunk := range chunkedfile.Read(filename, t) {
+		_, err := syntax.Parse(filename, chunk.Source, 0)
+		first, ok := err.(syntax.Error)
+		if !ok {
+			continue
+		}
+		f, err := syntax.Parse(filename, chunk.Source, syntax.RecoverErrors)
+		if f == nil {
+			t.Errorf("%s: RecoverErrors returned no tree", first.Pos)
+		}
+		list, ok := err.(syntax.ErrorList)
+		if !ok || len(list) == 0 || list[0].Error() != first.Error() {
+			t.Errorf("%s: RecoverErrors returned %v, want first error %v", first.Pos, err, first)
+		}
+	}
+}
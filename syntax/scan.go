@@ -30,11 +30,12 @@ const (
 	OUTDENT
 
 	// Tokens with values
-	IDENT  // x
-	INT    // 123
-	FLOAT  // 1.23e45
-	STRING // "foo" or 'foo' or '''foo''' or r'foo' or r"foo"
-	BYTES  // b"foo", etc
+	IDENT   // x
+	INT     // 123
+	FLOAT   // 1.23e45
+	STRING  // "foo" or 'foo' or '''foo''' or r'foo' or r"foo"
+	BYTES   // b"foo", etc
+	FSTRING // f"foo{x}", etc
 
 	// Punctuation
 	PLUS          // +
@@ -78,6 +79,7 @@ const (
 	LTLT_EQ       // <<=
 	GTGT_EQ       // >>=
 	STARSTAR      // **
+	ARROW         // ->
 
 	// Keywords
 	AND
@@ -126,7 +128,7 @@ func (tok Token) String() string { return tokenNames[tok] }
 // GoString is like String but quotes punctuation tokens.
 // Use Sprintf("%#v", tok) when constructing error messages.
 func (tok Token) GoString() string {
-	if tok >= PLUS && tok <= STARSTAR {
+	if tok >= PLUS && tok <= ARROW {
 		return "'" + tokenNames[tok] + "'"
 	}
 	return tokenNames[tok]
@@ -142,6 +144,7 @@ var tokenNames = [...]string{
 	INT:           "int literal",
 	FLOAT:         "float literal",
 	STRING:        "string literal",
+	FSTRING:       "f-string literal",
 	PLUS:          "+",
 	MINUS:         "-",
 	STAR:          "*",
@@ -183,6 +186,7 @@ var tokenNames = [...]string{
 	LTLT_EQ:       "<<=",
 	GTGT_EQ:       ">>=",
 	STARSTAR:      "**",
+	ARROW:         "->",
 	AND:           "and",
 	BREAK:         "break",
 	CONTINUE:      "continue",
@@ -349,6 +353,20 @@ type Error struct {
 
 func (e Error) Error() string { return e.Pos.String() + ": " + e.Msg }
 
+// An ErrorList is a list of errors, in order of position.
+// It is returned by the parser in RecoverErrors mode.
+type ErrorList []Error
+
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", list[0], len(list)-1)
+}
+
 // errorf is called to report an error.
 // errorf does not return: it panics.
 func (sc *scanner) error(pos Position, s string) {
@@ -694,6 +712,18 @@ start:
 			sc.readRune()
 			c = sc.peekRune()
 			return sc.scanString(val, c)
+		} else if c == 'f' && len(sc.rest) > 1 && (sc.rest[1] == '"' || sc.rest[1] == '\'') {
+			// f"..."
+			sc.readRune()
+			c = sc.peekRune()
+			return sc.scanString(val, c)
+		} else if len(sc.rest) > 2 && (c == 'r' && sc.rest[1] == 'f' || c == 'f' && sc.rest[1] == 'r') && (sc.rest[2] == '"' || sc.rest[2] == '\'') {
+			// rf"..."
+			// fr"..."
+			sc.readRune()
+			sc.readRune()
+			c = sc.peekRune()
+			return sc.scanString(val, c)
 		}
 
 		for isIdent(c) {
@@ -811,6 +841,10 @@ start:
 		case '+':
 			return PLUS
 		case '-':
+			if sc.peekRune() == '>' {
+				sc.readRune()
+				return ARROW
+			}
 			return MINUS
 		case '/':
 			if sc.peekRune() == '/' {
@@ -934,6 +968,12 @@ func (sc *scanner) scanString(val *tokenValue, quote rune) Token {
 	}
 	val.raw = raw.String()
 
+	// The literal parts and replacement fields of an f-string
+	// are decoded by the parser.
+	if prefix := val.raw[:strings.IndexAny(val.raw, `'"`)]; strings.Contains(prefix, "f") {
+		return FSTRING
+	}
+
 	s, _, isByte, err := unquote(val.raw)
 	if err != nil {
 		sc.error(start, err.Error())
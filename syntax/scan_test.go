@@ -45,6 +45,8 @@ func scan(src any) (tokens string, err error) {
 			fmt.Fprintf(&buf, "%e", val.float)
 		case STRING, BYTES:
 			buf.WriteString(Quote(val.string, tok == BYTES))
+		case FSTRING:
+			buf.WriteString(val.raw)
 		default:
 			buf.WriteString(tok.String())
 		}
@@ -239,6 +241,9 @@ pass`, "pass newline pass EOF"}, // consecutive newlines are consolidated
 		{`r"\w"`, `"\\w" EOF`},
 		{`r"\""`, `"\\\"" EOF`},
 		{`r"\'"`, `"\\'" EOF`},
+		{`f"a{b}c"`, `f"a{b}c" EOF`},
+		{`rf'\d{x}' fr"{y}"`, `rf'\d{x}' fr"{y}" EOF`},
+		{`f '{x}'`, `f "{x}" EOF`},
 		{`r'\w'`, `"\\w" EOF`},
 		{`r'\''`, `"\\'" EOF`},
 		{`r'\"'`, `"\\\"" EOF`},
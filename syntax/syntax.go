@@ -90,11 +90,13 @@ type Stmt interface {
 }
 
 func (*AssignStmt) stmt() {}
+func (*BadStmt) stmt()    {}
 func (*BranchStmt) stmt() {}
 func (*DefStmt) stmt()    {}
 func (*ExprStmt) stmt()   {}
 func (*ForStmt) stmt()    {}
 func (*WhileStmt) stmt()  {}
+func (*TryStmt) stmt()    {}
 func (*IfStmt) stmt()     {}
 func (*LoadStmt) stmt()   {}
 func (*ReturnStmt) stmt() {}
@@ -104,11 +106,14 @@ func (*ReturnStmt) stmt() {}
 //	x = 0
 //	x, y = y, x
 //	x += 1
+//	x: int = 0
 type AssignStmt struct {
 	commentsRef
 	OpPos Position
 	Op    Token // = EQ | {PLUS,MINUS,STAR,PERCENT}_EQ
 	LHS   Expr
+	Colon Position // position of ':' in annotated assignment "x: T = y"
+	Type  Expr     // type annotation T, or nil; see FileOptions.TypeAnnotations
 	RHS   Expr
 }
 
@@ -118,6 +123,16 @@ func (x *AssignStmt) Span() (start, end Position) {
 	return
 }
 
+// A BadStmt is a placeholder for a statement containing syntax errors,
+// created only when parsing in RecoverErrors mode.
+// From and To span the tokens that were skipped.
+type BadStmt struct {
+	commentsRef
+	From, To Position
+}
+
+func (x *BadStmt) Span() (start, end Position) { return x.From, x.To }
+
 // A DefStmt represents a function definition.
 type DefStmt struct {
 	commentsRef
@@ -128,6 +143,11 @@ type DefStmt struct {
 	Rparen Position
 	Body   []Stmt
 
+	// Type annotations; see FileOptions.TypeAnnotations.
+	ParamTypes []Expr   // type of each param (or nil element); nil if no param is annotated
+	Arrow      Position // position of "->", if ResultType != nil
+	ResultType Expr     // type of result, or nil
+
 	Function any // a *resolve.Function, set by resolver
 }
 
@@ -222,6 +242,7 @@ type Expr interface {
 	expr()
 }
 
+func (*BadExpr) expr()       {}
 func (*BinaryExpr) expr()    {}
 func (*CallExpr) expr()      {}
 func (*Comprehension) expr() {}
@@ -229,6 +250,7 @@ func (*CondExpr) expr()      {}
 func (*DictEntry) expr()     {}
 func (*DictExpr) expr()      {}
 func (*DotExpr) expr()       {}
+func (*FStringExpr) expr()   {}
 func (*Ident) expr()         {}
 func (*IndexExpr) expr()     {}
 func (*LambdaExpr) expr()    {}
@@ -239,6 +261,15 @@ func (*SliceExpr) expr()     {}
 func (*TupleExpr) expr()     {}
 func (*UnaryExpr) expr()     {}
 
+// A BadExpr is a placeholder for a missing or erroneous expression,
+// created only when parsing in RecoverErrors mode.
+type BadExpr struct {
+	commentsRef
+	From, To Position
+}
+
+func (x *BadExpr) Span() (start, end Position) { return x.From, x.To }
+
 // An Ident represents an identifier.
 type Ident struct {
 	commentsRef
@@ -265,6 +296,30 @@ func (x *Literal) Span() (start, end Position) {
 	return x.TokenPos, x.TokenPos.add(x.Raw)
 }
 
+// An FStringExpr represents a formatted string literal (f-string),
+// such as f"hello {name!r:>10}": a sequence of literal parts
+// separated by replacement fields.
+type FStringExpr struct {
+	commentsRef
+	TokenPos Position
+	Raw      string          // uninterpreted text
+	Literals []string        // decoded literal parts; len(Literals) == len(Fields)+1
+	Fields   []*FStringField // replacement fields
+}
+
+// An FStringField represents a replacement field {X!Conv:Spec} of an f-string.
+type FStringField struct {
+	Lbrace Position
+	X      Expr
+	Conv   string // "", "s", or "r"
+	Spec   string // format specifier, possibly empty
+	Rbrace Position
+}
+
+func (x *FStringExpr) Span() (start, end Position) {
+	return x.TokenPos, x.TokenPos.add(x.Raw)
+}
+
 // A ParenExpr represents a parenthesized expression: (X).
 type ParenExpr struct {
 	commentsRef
@@ -348,6 +403,29 @@ func (x *WhileStmt) Span() (start, end Position) {
 	return x.While, end
 }
 
+// A TryStmt represents a try statement:
+// try: Body except Name: Except finally: Finally.
+// At least one of the except and finally clauses is present.
+type TryStmt struct {
+	commentsRef
+	Try        Position
+	Body       []Stmt
+	ExceptPos  Position // position of 'except', if any
+	Name       *Ident   // variable bound to the caught error; may be nil
+	Except     []Stmt   // body of except clause
+	FinallyPos Position // position of 'finally', if any
+	Finally    []Stmt   // body of finally clause
+}
+
+func (x *TryStmt) Span() (start, end Position) {
+	body := x.Finally
+	if body == nil {
+		body = x.Except
+	}
+	_, end = body[len(body)-1].Span()
+	return x.Try, end
+}
+
 // A ForClause represents a for clause in a list comprehension: for Vars in X.
 type ForClause struct {
 	commentsRef
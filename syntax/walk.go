@@ -25,7 +25,7 @@ func Walk(n Node, f func(Node) bool) {
 	case *ExprStmt:
 		Walk(n.X, f)
 
-	case *BranchStmt:
+	case *BranchStmt, *BadStmt:
 		// no-op
 
 	case *IfStmt:
@@ -35,12 +35,21 @@ func Walk(n Node, f func(Node) bool) {
 
 	case *AssignStmt:
 		Walk(n.LHS, f)
+		if n.Type != nil {
+			Walk(n.Type, f)
+		}
 		Walk(n.RHS, f)
 
 	case *DefStmt:
 		Walk(n.Name, f)
-		for _, param := range n.Params {
+		for i, param := range n.Params {
 			Walk(param, f)
+			if n.ParamTypes != nil && n.ParamTypes[i] != nil {
+				Walk(n.ParamTypes[i], f)
+			}
+		}
+		if n.ResultType != nil {
+			Walk(n.ResultType, f)
 		}
 		walkStmts(n.Body, f)
 
@@ -53,6 +62,14 @@ func Walk(n Node, f func(Node) bool) {
 		Walk(n.Cond, f)
 		walkStmts(n.Body, f)
 
+	case *TryStmt:
+		walkStmts(n.Body, f)
+		if n.Name != nil {
+			Walk(n.Name, f)
+		}
+		walkStmts(n.Except, f)
+		walkStmts(n.Finally, f)
+
 	case *ReturnStmt:
 		if n.Result != nil {
 			Walk(n.Result, f)
@@ -67,9 +84,14 @@ func Walk(n Node, f func(Node) bool) {
 			Walk(to, f)
 		}
 
-	case *Ident, *Literal:
+	case *Ident, *Literal, *BadExpr:
 		// no-op
 
+	case *FStringExpr:
+		for _, field := range n.Fields {
+			Walk(field.X, f)
+		}
+
 	case *ListExpr:
 		for _, x := range n.List {
 			Walk(x, f)
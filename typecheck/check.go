@@ -0,0 +1,513 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package typecheck defines a static type checker for Starlark files
+// that contain type annotations, such as:
+//
+//	def greet(name: str, times: int = 1) -> list[str]:
+//	    greeting: str = "hello " + name
+//	    return [greeting] * times
+//
+// (Type annotations are enabled by syntax.FileOptions.TypeAnnotations.)
+//
+// The checker reports calls to annotated functions whose arguments do
+// not match the function's signature, and assignments and return
+// statements whose values do not match the annotated type. Built-in
+// functions implemented in Go may declare their signatures too; see
+// Config.Predeclared.
+//
+// The checker is deliberately permissive: the type of an expression
+// is inferred only from literals, annotations, and the results of calls
+// to functions with known signatures; all other expressions have type
+// Any, which is compatible with every type. It never changes the
+// run-time behavior of a program. (The interpreter checks the types of
+// parameters and results at run time if syntax.FileOptions.CheckTypes
+// is set; see Type for the type language common to both.)
+package typecheck // import "go.starlark.net/typecheck"
+
+import (
+	"fmt"
+	"sort"
+
+	"go.starlark.net/internal/types"
+	"go.starlark.net/resolve"
+	"go.starlark.net/syntax"
+)
+
+// A Config specifies the environment of the files to be checked.
+type Config struct {
+	// Predeclared maps the names of predeclared (and universal)
+	// values to their types, typically *Func types obtained from
+	// ParseSignature. Names not found in Predeclared are looked
+	// up in Universe; names not found in either have type Any.
+	Predeclared map[string]Type
+}
+
+// An Error describes a type error.
+type Error = types.Error
+
+// An ErrorList is a non-empty list of type errors, in order of position.
+type ErrorList = types.ErrorList
+
+// Check is equivalent to new(Config).Check(f).
+func Check(f *syntax.File) error {
+	return new(Config).Check(f)
+}
+
+// Check checks the type annotations of the specified file, which must
+// have been successfully resolved (see resolve.File), and reports type
+// errors, if any, as an ErrorList.
+func (cfg *Config) Check(f *syntax.File) error {
+	c := &checker{
+		cfg:   cfg,
+		vars:  make(map[*resolve.Binding]Type),
+		funcs: make(map[*syntax.DefStmt]*Func),
+	}
+	c.declare(f)
+	c.stmts(f.Stmts)
+	if c.errors == nil {
+		return nil
+	}
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		x, y := c.errors[i].Pos, c.errors[j].Pos
+		return x.Line < y.Line || x.Line == y.Line && x.Col < y.Col
+	})
+	return c.errors
+}
+
+// CheckAnnotations reports an error, as an ErrorList, for each type
+// annotation in f that does not denote a type.
+// Unlike Check, it does not require that f has been resolved.
+func CheckAnnotations(f *syntax.File) error { return types.CheckAnnotations(f) }
+
+type checker struct {
+	cfg    *Config
+	vars   map[*resolve.Binding]Type // declared type of each variable
+	funcs  map[*syntax.DefStmt]*Func // type of each function
+	fn     *Func                     // type of enclosing function, or nil at top level
+	errors ErrorList
+}
+
+func (c *checker) errorf(pos syntax.Position, format string, args ...any) {
+	c.errors = append(c.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, args...)})
+}
+
+// declare records the types of all functions, annotated parameters,
+// and annotated variables of the file, so that they may be used
+// before their declarations.
+func (c *checker) declare(f *syntax.File) {
+	syntax.Walk(f, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.DefStmt:
+			fn, errs := funcType(n)
+			c.errors = append(c.errors, errs...)
+			c.funcs[n] = fn
+			c.declareVar(n.Name, fn)
+			for i, param := range n.Params {
+				if n.ParamTypes == nil || n.ParamTypes[i] == nil {
+					continue
+				}
+				switch param := param.(type) {
+				case *syntax.Ident:
+					c.declareVar(param, paramOf(fn, param.Name).Type)
+				case *syntax.BinaryExpr:
+					id := param.X.(*syntax.Ident)
+					c.declareVar(id, paramOf(fn, id.Name).Type)
+				case *syntax.UnaryExpr:
+					if param.Op == syntax.STARSTAR {
+						c.declareVar(param.X.(*syntax.Ident), &Dict{Key: String, Value: fn.Kwargs})
+					} else {
+						c.declareVar(param.X.(*syntax.Ident), Tuple)
+					}
+				}
+			}
+
+		case *syntax.AssignStmt:
+			if n.Type != nil {
+				t, err := ParseType(n.Type)
+				if err != nil {
+					c.errors = append(c.errors, err.(Error))
+					t = Any
+				}
+				c.declareVar(n.LHS.(*syntax.Ident), t)
+			}
+		}
+		return true
+	})
+}
+
+// declareVar records the type of the variable bound by id.
+// The first declaration of a variable determines its type.
+func (c *checker) declareVar(id *syntax.Ident, t Type) {
+	if b, ok := id.Binding.(*resolve.Binding); ok {
+		if _, ok := c.vars[b]; !ok {
+			c.vars[b] = t
+		}
+	}
+}
+
+// paramOf returns the named parameter of fn, or nil.
+func paramOf(fn *Func, name string) *Param {
+	for i := range fn.Params {
+		if fn.Params[i].Name == name {
+			return &fn.Params[i]
+		}
+	}
+	return nil
+}
+
+func (c *checker) stmts(stmts []syntax.Stmt) {
+	for _, stmt := range stmts {
+		c.stmt(stmt)
+	}
+}
+
+func (c *checker) stmt(stmt syntax.Stmt) {
+	switch stmt := stmt.(type) {
+	case *syntax.ExprStmt:
+		c.expr(stmt.X)
+
+	case *syntax.AssignStmt:
+		t := c.expr(stmt.RHS)
+		if id, ok := stmt.LHS.(*syntax.Ident); ok && stmt.Op == syntax.EQ {
+			if want := c.varType(id); !AssignableTo(t, want) {
+				start, _ := stmt.RHS.Span()
+				c.errorf(start, "%s: got %s, want %s", id.Name, t, want)
+			}
+		} else {
+			c.expr(stmt.LHS)
+		}
+
+	case *syntax.DefStmt:
+		fn := c.funcs[stmt]
+		for _, param := range stmt.Params {
+			if bin, ok := param.(*syntax.BinaryExpr); ok {
+				t := c.expr(bin.Y)
+				p := paramOf(fn, bin.X.(*syntax.Ident).Name)
+				if !AssignableTo(t, p.Type) {
+					start, _ := bin.Y.Span()
+					c.errorf(start, "%s: default value of parameter %s: got %s, want %s", fn.Name, p.Name, t, p.Type)
+				}
+			}
+		}
+		outer := c.fn
+		c.fn = fn
+		c.stmts(stmt.Body)
+		c.fn = outer
+
+	case *syntax.ForStmt:
+		c.expr(stmt.X)
+		c.stmts(stmt.Body)
+
+	case *syntax.WhileStmt:
+		c.expr(stmt.Cond)
+		c.stmts(stmt.Body)
+
+	case *syntax.IfStmt:
+		c.expr(stmt.Cond)
+		c.stmts(stmt.True)
+		c.stmts(stmt.False)
+
+	case *syntax.TryStmt:
+		c.stmts(stmt.Body)
+		c.stmts(stmt.Except)
+		c.stmts(stmt.Finally)
+
+	case *syntax.ReturnStmt:
+		t := Type(NoneType)
+		pos := stmt.Return
+		if stmt.Result != nil {
+			t = c.expr(stmt.Result)
+			pos, _ = stmt.Result.Span()
+		}
+		if c.fn != nil && !AssignableTo(t, c.fn.Result) {
+			c.errorf(pos, "%s: for return value: got %s, want %s", c.fn.Name, t, c.fn.Result)
+		}
+	}
+}
+
+// varType returns the declared type of the variable denoted by id.
+func (c *checker) varType(id *syntax.Ident) Type {
+	b, ok := id.Binding.(*resolve.Binding)
+	if !ok {
+		return Any // unresolved
+	}
+	switch b.Scope {
+	case resolve.Predeclared, resolve.Universal:
+		if t, ok := c.cfg.Predeclared[id.Name]; ok {
+			return t
+		}
+		if t, ok := Universe[id.Name]; ok {
+			return t
+		}
+	default:
+		if t, ok := c.vars[b]; ok {
+			return t
+		}
+	}
+	return Any
+}
+
+// expr checks the expression e and returns its type.
+func (c *checker) expr(e syntax.Expr) Type {
+	switch e := e.(type) {
+	case *syntax.Ident:
+		return c.varType(e)
+
+	case *syntax.Literal:
+		switch e.Token {
+		case syntax.INT:
+			return Int
+		case syntax.FLOAT:
+			return Float
+		case syntax.STRING:
+			return String
+		case syntax.BYTES:
+			return Bytes
+		}
+
+	case *syntax.FStringExpr:
+		for _, field := range e.Fields {
+			c.expr(field.X)
+		}
+		return String
+
+	case *syntax.ParenExpr:
+		return c.expr(e.X)
+
+	case *syntax.ListExpr:
+		return &List{Elem: c.join(e.List)}
+
+	case *syntax.TupleExpr:
+		c.join(e.List)
+		return Tuple
+
+	case *syntax.DictExpr:
+		keys := make([]syntax.Expr, len(e.List))
+		values := make([]syntax.Expr, len(e.List))
+		for i, entry := range e.List {
+			entry := entry.(*syntax.DictEntry)
+			keys[i], values[i] = entry.Key, entry.Value
+		}
+		return &Dict{Key: c.join(keys), Value: c.join(values)}
+
+	case *syntax.Comprehension:
+		for _, clause := range e.Clauses {
+			switch clause := clause.(type) {
+			case *syntax.ForClause:
+				c.expr(clause.X)
+			case *syntax.IfClause:
+				c.expr(clause.Cond)
+			}
+		}
+		if e.Curly {
+			entry := e.Body.(*syntax.DictEntry)
+			return &Dict{Key: c.expr(entry.Key), Value: c.expr(entry.Value)}
+		}
+		return &List{Elem: c.expr(e.Body)}
+
+	case *syntax.CondExpr:
+		c.expr(e.Cond)
+		return c.join([]syntax.Expr{e.True, e.False})
+
+	case *syntax.UnaryExpr:
+		if e.X == nil {
+			return Any
+		}
+		x := c.expr(e.X)
+		switch e.Op {
+		case syntax.NOT:
+			return Bool
+		case syntax.MINUS, syntax.PLUS:
+			if x == Int || x == Float {
+				return x
+			}
+		}
+
+	case *syntax.BinaryExpr:
+		x, y := c.expr(e.X), c.expr(e.Y)
+		switch e.Op {
+		case syntax.EQL, syntax.NEQ, syntax.LT, syntax.GT, syntax.LE, syntax.GE, syntax.IN, syntax.NOT_IN:
+			return Bool
+		case syntax.PLUS:
+			if x == String && y == String {
+				return String
+			}
+			fallthrough
+		case syntax.MINUS, syntax.STAR:
+			if (x == Int || x == Float) && (y == Int || y == Float) {
+				if x == Float || y == Float {
+					return Float
+				}
+				return Int
+			}
+		case syntax.SLASH:
+			if (x == Int || x == Float) && (y == Int || y == Float) {
+				return Float
+			}
+		}
+
+	case *syntax.IndexExpr:
+		x := c.expr(e.X)
+		c.expr(e.Y)
+		switch x := x.(type) {
+		case *List:
+			return x.Elem
+		case *Dict:
+			return x.Value
+		case Named:
+			if x == String {
+				return String
+			}
+		}
+
+	case *syntax.SliceExpr:
+		x := c.expr(e.X)
+		for _, y := range []syntax.Expr{e.Lo, e.Hi, e.Step} {
+			if y != nil {
+				c.expr(y)
+			}
+		}
+		if _, ok := x.(*List); ok || x == String {
+			return x
+		}
+
+	case *syntax.DotExpr:
+		if rec, ok := c.expr(e.X).(*Record); ok {
+			for _, f := range rec.Fields {
+				if f.Name == e.Name.Name {
+					return f.Type
+				}
+			}
+		}
+
+	case *syntax.LambdaExpr:
+		c.expr(e.Body)
+		return Function
+
+	case *syntax.CallExpr:
+		return c.call(e)
+	}
+	return Any
+}
+
+// join checks each expression and returns their common type,
+// or Any if they have different types.
+func (c *checker) join(exprs []syntax.Expr) Type {
+	var t Type
+	for _, e := range exprs {
+		u := c.expr(e)
+		if t == nil {
+			t = u
+		} else if !types.Identical(t, u) {
+			t = Any
+		}
+	}
+	if t == nil {
+		return Any
+	}
+	return t
+}
+
+// call checks the arguments of a call against the callee's signature,
+// if known, and returns the type of the call's result.
+func (c *checker) call(call *syntax.CallExpr) Type {
+	fnType := c.expr(call.Fn)
+	fn, ok := fnType.(*Func)
+	if !ok {
+		for _, arg := range call.Args {
+			if bin, ok := arg.(*syntax.BinaryExpr); ok && bin.Op == syntax.EQ {
+				arg = bin.Y
+			} else if un, ok := arg.(*syntax.UnaryExpr); ok {
+				arg = un.X
+			}
+			c.expr(arg)
+		}
+		return Any
+	}
+
+	// check reports an error if the argument of type t is not assignable to the parameter.
+	check := func(arg syntax.Expr, t Type, param string, want Type) {
+		if !AssignableTo(t, want) {
+			start, _ := arg.Span()
+			c.errorf(start, "%s: for parameter %s: got %s, want %s", fn.Name, param, t, want)
+		}
+	}
+
+	bound := make([]bool, len(fn.Params))
+	npos := 0        // number of positional arguments
+	dynamic := false // call has *args or **kwargs arguments
+	for _, arg := range call.Args {
+		switch arg := arg.(type) {
+		case *syntax.UnaryExpr:
+			// *args or **kwargs
+			c.expr(arg.X)
+			dynamic = true
+
+		case *syntax.BinaryExpr:
+			if arg.Op == syntax.EQ {
+				// keyword argument
+				name := arg.X.(*syntax.Ident).Name
+				t := c.expr(arg.Y)
+				i := -1
+				for j, p := range fn.Params {
+					if p.Name == name {
+						i = j
+						break
+					}
+				}
+				if i < 0 {
+					if fn.Kwargs != nil {
+						check(arg.Y, t, name, fn.Kwargs)
+					} else {
+						c.errorf(arg.OpPos, "function %s got an unexpected keyword argument %s", fn.Name, name)
+					}
+				} else if bound[i] {
+					c.errorf(arg.OpPos, "function %s got multiple values for parameter %s", fn.Name, name)
+				} else {
+					bound[i] = true
+					check(arg.Y, t, name, fn.Params[i].Type)
+				}
+				continue
+			}
+			c.positional(fn, arg, npos, bound, check)
+			npos++
+
+		default:
+			c.positional(fn, arg, npos, bound, check)
+			npos++
+		}
+	}
+
+	if !dynamic {
+		for i, p := range fn.Params {
+			if !bound[i] && !p.Optional {
+				c.errorf(call.Rparen, "function %s missing argument for %s", fn.Name, p.Name)
+			}
+		}
+	}
+	return fn.Result
+}
+
+// positional checks the ith positional argument of a call to fn.
+func (c *checker) positional(fn *Func, arg syntax.Expr, i int, bound []bool, check func(syntax.Expr, Type, string, Type)) {
+	t := c.expr(arg)
+	if i < len(fn.Params) && !fn.Params[i].KwOnly {
+		bound[i] = true
+		check(arg, t, fn.Params[i].Name, fn.Params[i].Type)
+	} else if fn.Variadic != nil {
+		check(arg, t, "*args", fn.Variadic)
+	} else {
+		max := 0
+		for _, p := range fn.Params {
+			if !p.KwOnly {
+				max++
+			}
+		}
+		if i == max { // report only the first excess argument
+			start, _ := arg.Span()
+			c.errorf(start, "function %s accepts at most %d positional arguments", fn.Name, max)
+		}
+	}
+}
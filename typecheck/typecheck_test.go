@@ -0,0 +1,105 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typecheck_test
+
+import (
+	"reflect"
+	"testing"
+
+	"go.starlark.net/internal/chunkedfile"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarktest"
+	"go.starlark.net/syntax"
+	"go.starlark.net/typecheck"
+)
+
+func TestCheck(t *testing.T) {
+	cfg := &typecheck.Config{
+		Predeclared: map[string]typecheck.Type{
+			"glob": typecheck.MustParseSignature("glob(include: list[str], exclude: list[str] = [], *, allow_empty: bool = True) -> list[str]"),
+		},
+	}
+	isPredeclared := func(name string) bool { return name == "glob" || name == "struct" }
+	opts := &syntax.FileOptions{TypeAnnotations: true, FStrings: true, TopLevelControl: true, GlobalReassign: true}
+
+	filename := starlarktest.DataFile("typecheck", "testdata/check.star")
+	for _, chunk := range chunkedfile.Read(filename, t) {
+		f, err := opts.Parse(filename, chunk.Source, 0)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := resolve.File(f, isPredeclared, starlark.Universe.Has); err != nil {
+			t.Error(err)
+			continue
+		}
+		if err := cfg.Check(f); err != nil {
+			for _, err := range err.(typecheck.ErrorList) {
+				chunk.GotError(int(err.Pos.Line), err.Msg)
+			}
+		}
+		chunk.Done()
+	}
+}
+
+func TestParseType(t *testing.T) {
+	for _, test := range []struct{ src, want string }{
+		{"int", "int"},
+		{"str", "string"},
+		{"None", "NoneType"},
+		{"list", "list[Any]"},
+		{"list[str]", "list[string]"},
+		{"dict[str, list[int]]", "dict[string, list[int]]"},
+		{"int | str | None", "int | string | NoneType"},
+		{"(int | str) | int", "int | string"},
+		{"struct(name=str, age=int | None)", "struct(name=string, age=int | NoneType)"},
+		{"Thing", "Thing"},
+		{"list[int, str]", "invalid type list with 2 type arguments"},
+		{"struct(int)", "record type field must have form name=type"},
+		{"1", "invalid type annotation"},
+	} {
+		e, err := syntax.ParseExpr("<expr>", test.src, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got string
+		if typ, err := typecheck.ParseType(e); err != nil {
+			got = err.(typecheck.Error).Msg
+		} else {
+			got = typ.String()
+		}
+		if got != test.want {
+			t.Errorf("ParseType(%s) = %s, want %s", test.src, got, test.want)
+		}
+	}
+}
+
+func TestCheckAnnotations(t *testing.T) {
+	const src = `
+def f(x: list[int, int], y: int) -> 1:
+    z: struct(int) = 1
+`
+	opts := &syntax.FileOptions{TypeAnnotations: true}
+	f, err := opts.Parse("f.star", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = typecheck.CheckAnnotations(f)
+	var got []string
+	if err != nil {
+		for _, err := range err.(typecheck.ErrorList) {
+			got = append(got, err.Error())
+		}
+	}
+	want := []string{
+		"f.star:2:10: invalid type list with 2 type arguments",
+		"f.star:2:37: invalid type annotation",
+		"f.star:3:15: record type field must have form name=type",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors %q, want %q", got, want)
+	}
+}
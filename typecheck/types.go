@@ -0,0 +1,220 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typecheck
+
+// This file defines the types of the checker, which are those of
+// the interpreter's run-time checks, and the parser of signatures.
+
+import (
+	"fmt"
+
+	"go.starlark.net/internal/types"
+	"go.starlark.net/syntax"
+)
+
+// A Type is the static type of a Starlark value.
+//
+// A type annotation such as "list[str] | None" denotes a Type.
+// The type language consists of:
+//
+//	Any                   any value
+//	T                     values whose Value.Type() is T, e.g. int, string, struct
+//	str                   same as string
+//	None                  same as NoneType
+//	list[T]               lists whose elements are of type T
+//	dict[K, V]            dicts whose keys and values are of type K and V
+//	T | U                 values of type T or U
+//	struct(x=T, y=U)      values with fields x and y of type T and U (a record type)
+//
+// Any other name T denotes the values whose Type method returns T,
+// such as those of an application-defined type. Types of the forms
+// list and dict are equivalent to list[Any] and dict[Any, Any].
+// The names in a type annotation are not subject to name resolution.
+//
+// The concrete types are Named, *List, *Dict, *Union, *Record, and *Func.
+// (The type of a function is not expressible in an annotation.)
+type Type = types.Type
+
+type (
+	Named  = types.Named  // the set of values whose Value.Type() is the specified name
+	List   = types.List   // the type of a list whose elements are of type Elem
+	Dict   = types.Dict   // the type of a dict whose keys and values are of type Key and Value
+	Union  = types.Union  // the type of a value that is of one of the specified types
+	Record = types.Record // the type of a value with the specified fields, such as a struct
+	Field  = types.Field  // a named field of a Record
+	Func   = types.Func   // the type of a function, as declared by a def statement or signature
+	Param  = types.Param  // a named parameter of a Func
+)
+
+// Any is the type of a value about which nothing is known statically.
+// A value of any type may be used where Any is wanted, and vice versa.
+var Any = types.Any
+
+// Common named types.
+const (
+	Int      = types.Int
+	Float    = types.Float
+	String   = types.String
+	Bytes    = types.Bytes
+	Bool     = types.Bool
+	NoneType = types.NoneType
+	Tuple    = types.Tuple
+	Function = types.Function
+)
+
+// ParseType returns the type denoted by a type annotation.
+func ParseType(e syntax.Expr) (Type, error) { return types.ParseType(e) }
+
+// ParseSignature returns the type of a function with the specified
+// signature, which has the form of a def statement without the "def"
+// keyword and body. For example:
+//
+//	glob(include: list[str], exclude: list[str] = [], allow_empty: bool = True) -> list[str]
+//
+// The values of default parameters are not significant.
+// Missing annotations are treated as Any. Applications may use
+// ParseSignature to declare the types of their built-in functions;
+// see Config.Predeclared.
+func ParseSignature(sig string) (*Func, error) {
+	opts := &syntax.FileOptions{TypeAnnotations: true}
+	f, err := opts.Parse("<signature>", "def "+sig+": pass\n", 0)
+	if err != nil {
+		return nil, err
+	}
+	def, ok := f.Stmts[0].(*syntax.DefStmt)
+	if !ok || len(f.Stmts) > 1 {
+		return nil, fmt.Errorf("invalid signature: %s", sig)
+	}
+	fn, errs := funcType(def)
+	if errs != nil {
+		return nil, errs[0]
+	}
+	return fn, nil
+}
+
+// MustParseSignature is like ParseSignature but panics on error.
+func MustParseSignature(sig string) *Func {
+	fn, err := ParseSignature(sig)
+	if err != nil {
+		panic(err)
+	}
+	return fn
+}
+
+// funcType returns the type of the function defined by def,
+// and any errors in its type annotations.
+func funcType(def *syntax.DefStmt) (*Func, ErrorList) {
+	var errors ErrorList
+	annot := func(e syntax.Expr) Type {
+		if e == nil {
+			return Any
+		}
+		t, err := ParseType(e)
+		if err != nil {
+			errors = append(errors, err.(Error))
+			return Any
+		}
+		return t
+	}
+
+	fn := &Func{Name: def.Name.Name, Result: annot(def.ResultType)}
+	kwonly := false
+	for i, param := range def.Params {
+		var typ syntax.Expr
+		if def.ParamTypes != nil {
+			typ = def.ParamTypes[i]
+		}
+		switch param := param.(type) {
+		case *syntax.Ident:
+			fn.Params = append(fn.Params, Param{Name: param.Name, Type: annot(typ), KwOnly: kwonly})
+		case *syntax.BinaryExpr:
+			name := param.X.(*syntax.Ident).Name
+			fn.Params = append(fn.Params, Param{Name: name, Type: annot(typ), Optional: true, KwOnly: kwonly})
+		case *syntax.UnaryExpr:
+			kwonly = true
+			if param.Op == syntax.STARSTAR {
+				fn.Kwargs = annot(typ)
+			} else if param.X != nil {
+				fn.Variadic = annot(typ)
+			}
+		}
+	}
+	return fn, errors
+}
+
+// AssignableTo reports whether a value of type x may be used where a
+// value of type y is wanted. Since the static types of expressions are
+// imprecise, it is permissive: Any is assignable to and from every type,
+// an int is assignable to a float, and lists and dicts are covariant.
+func AssignableTo(x, y Type) bool {
+	if x == Any || y == Any {
+		return true
+	}
+	if u, ok := x.(*Union); ok {
+		for _, t := range u.Types {
+			if !AssignableTo(t, y) {
+				return false
+			}
+		}
+		return true
+	}
+	switch y := y.(type) {
+	case *Union:
+		for _, t := range y.Types {
+			if AssignableTo(x, t) {
+				return true
+			}
+		}
+		return false
+
+	case Named:
+		switch x := x.(type) {
+		case Named:
+			return x == y || x == Int && y == Float
+		case *Func:
+			return y == Function || y == "builtin_function_or_method"
+		case *List:
+			return y == "list"
+		case *Dict:
+			return y == "dict"
+		case *Record:
+			return y == "struct"
+		}
+
+	case *List:
+		if x, ok := x.(*List); ok {
+			return AssignableTo(x.Elem, y.Elem)
+		}
+
+	case *Dict:
+		if x, ok := x.(*Dict); ok {
+			return AssignableTo(x.Key, y.Key) && AssignableTo(x.Value, y.Value)
+		}
+
+	case *Record:
+		switch x := x.(type) {
+		case Named:
+			return x == "struct"
+		case *Record:
+			for _, fy := range y.Fields {
+				ok := false
+				for _, fx := range x.Fields {
+					if fx.Name == fy.Name {
+						ok = AssignableTo(fx.Type, fy.Type)
+						break
+					}
+				}
+				if !ok {
+					return false
+				}
+			}
+			return true
+		}
+
+	case *Func:
+		return x == y
+	}
+	return false
+}
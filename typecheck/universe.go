@@ -0,0 +1,48 @@
+// Copyright 2026 The Bazel Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package typecheck
+
+// Universe maps the names of the values of starlark.Universe to their types.
+// It contains signatures only for functions whose parameters and result
+// can be usefully described in the type language.
+var Universe = map[string]Type{
+	"None":  NoneType,
+	"True":  Bool,
+	"False": Bool,
+}
+
+func init() {
+	for _, sig := range []string{
+		"abs(x: int | float) -> int | float",
+		"all(x) -> bool",
+		"any(x) -> bool",
+		"bool(x = False) -> bool",
+		"bytes(x: str | bytes | list[int]) -> bytes",
+		"chr(i: int) -> str",
+		"dir(x) -> list[str]",
+		"enumerate(x, start: int = 0) -> list[tuple]",
+		"fail(*args, sep: str = ' ') -> None",
+		"float(x = 0.0) -> float",
+		"getattr(x, name: str, default = None)",
+		"hasattr(x, name: str) -> bool",
+		"hash(x: str | bytes) -> int",
+		"int(x = 0, base: int = 10) -> int",
+		"len(x) -> int",
+		"list(x = []) -> list",
+		"ord(s: str | bytes) -> int",
+		"print(*args, sep: str = ' ') -> None",
+		"range(*args: int) -> range",
+		"repr(x) -> str",
+		"reversed(x) -> list",
+		"sorted(x, key = None, reverse: bool = False) -> list",
+		"str(x = '') -> str",
+		"tuple(x = ()) -> tuple",
+		"type(x) -> str",
+		"zip(*args) -> list[tuple]",
+	} {
+		fn := MustParseSignature(sig)
+		Universe[fn.Name] = fn
+	}
+}